@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/db/migrations"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/api/queries"
+	"github.com/otterly-id/otterly/backend/internal/configs"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+)
+
+// runCommand runs a maintenance command instead of the server, e.g.
+//
+//	go run ./cmd create-admin -name "Admin" -email admin@otterly.id
+//	go run ./cmd migrate up
+func runCommand(args []string) {
+	var err error
+
+	switch args[0] {
+	case "create-admin":
+		err = createAdmin(args[1:])
+	case "migrate":
+		err = migrate(args[1:])
+	default:
+		err = fmt.Errorf("unknown command %q, available commands: create-admin, migrate", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// createAdmin creates the first ADMIN account. It refuses to run once an
+// admin exists, further admins are promoted with PUT /api/users/{id}/role.
+func createAdmin(args []string) error {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	name := flags.String("name", "", "user name, letters and spaces only")
+	fullName := flags.String("full-name", "", "full name")
+	email := flags.String("email", "", "email address")
+	password := flags.String("password", "", "password, read from standard input when empty")
+	flags.Parse(args)
+
+	if *password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	admin := &models.CreateAdminRequest{
+		Name:     *name,
+		FullName: *fullName,
+		Email:    *email,
+		Password: *password,
+	}
+
+	if err := configs.NewValidator().Struct(admin); err != nil {
+		return fmt.Errorf("invalid admin: %w", err)
+	}
+
+	hashedPassword, err := utils.HashPassword(admin.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	admin.Password = string(hashedPassword)
+
+	database, err := db.GetDBConnection(configs.NewViper())
+	if err != nil {
+		return err
+	}
+	defer db.CloseDBConnection()
+
+	user, err := database.CreateFirstAdmin(context.Background(), admin)
+	if errors.Is(err, queries.ErrAdminExists) {
+		return errors.New("an admin already exists, promote further admins with PUT /api/users/{id}/role")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create admin: %w", err)
+	}
+
+	fmt.Printf("Admin %s created with id %s\n", user.Email, user.ID)
+	return nil
+}
+
+const migrateUsage = "usage: migrate up | down [steps] | goto <version> | force <version> | status | lint"
+
+// migrate applies the migrations embedded in the binary. down reverts one
+// migration unless told how many, force records a version after a dirty
+// schema was fixed by hand, lint checks the migrations without a database.
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "lint" {
+		loaded, err := migrations.Load(migrations.FS)
+		if err != nil {
+			return err
+		}
+		if err := migrations.Lint(loaded); err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations checked, none is destructive\n", len(loaded))
+		return nil
+	}
+
+	connection, err := db.PostgreSQLConnection(configs.NewViper())
+	if err != nil {
+		return err
+	}
+	defer connection.Close()
+
+	migrator, err := db.NewMigrator(connection)
+	if err != nil {
+		return err
+	}
+	migrator.Logf = func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+	}
+	migrator.Warnf = migrator.Logf
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case (args[0] == "goto" || args[0] == "force") && len(args) == 2:
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "goto" {
+			err = migrator.Goto(ctx, uint(version))
+		} else {
+			err = migrator.Force(ctx, uint(version))
+		}
+	case args[0] == "status" && len(args) == 1:
+		// Printed below, like after every other subcommand.
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database is at version %d", status.Version)
+	if status.Dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Printf(", %d pending\n", len(status.Pending))
+	for _, migration := range status.Pending {
+		fmt.Printf("  %s\n", migration)
+	}
+
+	return nil
+}
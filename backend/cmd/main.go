@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/otterly-id/otterly/backend/db"
 	"github.com/otterly-id/otterly/backend/internal/configs"
 	"go.uber.org/zap"
@@ -26,28 +29,41 @@ import (
 // @name otterly_token
 // @description JWT token stored in httpOnly cookie for authentication
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT access token or personal access token sent as "Bearer <token>" in the Authorization header
+
 // @tag.name Auth
 // @tag.description Authentication operations
 
 // @tag.name Users
 // @tag.description User management operations
 
-// @tag.name Admin
-// @tag.description Admin-only operations
+// @tag.name Tokens
+// @tag.description Personal access token operations
+
+// @tag.name MFA
+// @tag.description Two-factor authentication operations
 
-// @tag.name Owner
-// @tag.description Owner-only operations
+// @tag.name Admin
+// @tag.description Operations requiring users:read-private, users:delete, users:manage-roles, users:impersonate or tokens:manage, granted to ADMIN by default
 
 // @tag.name Management
-// @tag.description Management operations (Admin or Owner)
+// @tag.description Operations requiring users:write, granted to ADMIN and OWNER by default
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
 	log := configs.NewLogger()
 	defer log.Sync()
 
 	viperConfig := configs.NewViper()
 	validate := configs.NewValidator()
 	cors := configs.NewCORS()
-	app := configs.NewChi(cors)
+	app := configs.NewChi(viperConfig, cors)
 	server := configs.NewServer(viperConfig, app)
 
 	db, err := db.GetDBConnection(viperConfig)
@@ -55,6 +71,31 @@ func main() {
 		log.Fatal("Failed to connect to database", zap.Error(err))
 	}
 
+	// Replicas starting together take turns, the ones after the first find
+	// nothing left to apply.
+	if viperConfig.GetBool("DB_AUTO_MIGRATE") {
+		migrator, err := db.Migrator()
+		if err != nil {
+			log.Fatal("Failed to load migrations", zap.Error(err))
+		}
+
+		migrator.Logf = log.Sugar().Infof
+		migrator.Warnf = log.Sugar().Warnf
+		if err := migrator.Up(context.Background()); err != nil {
+			log.Fatal("Failed to migrate database", zap.Error(err))
+		}
+	}
+
+	redis, err := configs.NewRedis(viperConfig)
+	if err != nil {
+		log.Fatal("Failed to connect to redis", zap.Error(err))
+	}
+
+	mailer, err := configs.NewMailer(viperConfig, log)
+	if err != nil {
+		log.Fatal("Failed to create mailer", zap.Error(err))
+	}
+
 	configs.Bootstrap(&configs.BootstrapConfig{
 		App:      app,
 		Log:      log,
@@ -62,5 +103,7 @@ func main() {
 		Config:   viperConfig,
 		Server:   server,
 		DB:       db,
+		Redis:    redis,
+		Mailer:   mailer,
 	})
 }
\ No newline at end of file
@@ -19,6 +19,17 @@ var (
 type Queries struct {
 	*queries.UserQueries
 	*queries.AuthQueries
+	*queries.RefreshTokenQueries
+	*queries.APITokenQueries
+	*queries.PasswordResetQueries
+	*queries.MFAQueries
+	*queries.IdentityQueries
+	*queries.PermissionQueries
+	*queries.AuditQueries
+
+	pool     *sqlx.DB
+	tx       *queries.Tx
+	timeouts queries.Timeouts
 }
 
 func PostgreSQLConnection(config *viper.Viper) (*sqlx.DB, error) {
@@ -58,13 +69,32 @@ func GetDBConnection(config *viper.Viper) (*Queries, error) {
 	}
 
 	setupConnectionPool(db, config)
+	dbInstance = newQueries(db, nil, queryTimeouts(config))
 
-	dbInstance = &Queries{
-		UserQueries: &queries.UserQueries{DB: db},
-		AuthQueries: &queries.AuthQueries{DB: db},
+	return dbInstance, nil
+}
+
+// newQueries binds every query type to the pool, or to tx when it is not nil.
+func newQueries(pool *sqlx.DB, tx *queries.Tx, timeouts queries.Timeouts) *Queries {
+	var db queries.DB = pool
+	if tx != nil {
+		db = tx
 	}
 
-	return dbInstance, nil
+	return &Queries{
+		UserQueries:          &queries.UserQueries{DB: db, Timeouts: timeouts},
+		AuthQueries:          &queries.AuthQueries{DB: db, Timeouts: timeouts},
+		RefreshTokenQueries:  &queries.RefreshTokenQueries{DB: db, Timeouts: timeouts},
+		APITokenQueries:      &queries.APITokenQueries{DB: db, Timeouts: timeouts},
+		PasswordResetQueries: &queries.PasswordResetQueries{DB: db, Timeouts: timeouts},
+		MFAQueries:           &queries.MFAQueries{DB: db, Timeouts: timeouts},
+		IdentityQueries:      &queries.IdentityQueries{DB: db, Timeouts: timeouts},
+		PermissionQueries:    &queries.PermissionQueries{DB: db, Timeouts: timeouts},
+		AuditQueries:         &queries.AuditQueries{DB: db, Timeouts: timeouts},
+		pool:                 pool,
+		tx:                   tx,
+		timeouts:             timeouts,
+	}
 }
 
 func setupConnectionPool(db *sqlx.DB, config *viper.Viper) {
@@ -77,12 +107,22 @@ func setupConnectionPool(db *sqlx.DB, config *viper.Viper) {
 	db.SetConnMaxLifetime(time.Duration(maxLifetime) * time.Second)
 }
 
+// queryTimeouts reads the deadlines of single queries, list queries and bulk
+// imports and exports, all in seconds.
+func queryTimeouts(config *viper.Viper) queries.Timeouts {
+	return queries.Timeouts{
+		Query: time.Duration(config.GetInt("DB_QUERY_TIMEOUT")) * time.Second,
+		List:  time.Duration(config.GetInt("DB_LIST_QUERY_TIMEOUT")) * time.Second,
+		Bulk:  time.Duration(config.GetInt("DB_BULK_QUERY_TIMEOUT")) * time.Second,
+	}
+}
+
 func CloseDBConnection() error {
 	mu.Lock()
 	defer mu.Unlock()
 
-	if dbInstance != nil && dbInstance.UserQueries != nil {
-		if err := dbInstance.UserQueries.DB.Close(); err != nil {
+	if dbInstance != nil && dbInstance.pool != nil {
+		if err := dbInstance.pool.Close(); err != nil {
 			return fmt.Errorf("failed to close database connection: %w", err)
 		}
 		dbInstance = nil
@@ -95,11 +135,11 @@ func HealthCheck() error {
 	mu.RLock()
 	defer mu.RUnlock()
 
-	if dbInstance == nil || dbInstance.UserQueries == nil {
+	if dbInstance == nil || dbInstance.pool == nil {
 		return fmt.Errorf("database connection not initialized")
 	}
 
-	if err := dbInstance.UserQueries.DB.Ping(); err != nil {
+	if err := dbInstance.pool.Ping(); err != nil {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
 
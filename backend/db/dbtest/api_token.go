@@ -0,0 +1,148 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func (m *Memory) CreateAPIToken(ctx context.Context, userID uuid.UUID, name, tokenPrefix string, tokenHash []byte, scopes models.Scopes, expiresAt *time.Time) (models.CreateAPITokenResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.CreateAPITokenResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token := &apiToken{
+		APITokenResponse: models.APITokenResponse{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Name:        name,
+			TokenPrefix: tokenPrefix,
+			Scopes:      slices.Clone(scopes),
+			ExpiresAt:   expiresAt,
+			CreatedAt:   timestamp(time.Now()),
+		},
+		tokenHash: bytes.Clone(tokenHash),
+	}
+	m.apiTokens = append(m.apiTokens, token)
+
+	return models.CreateAPITokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		ExpiresAt: token.ExpiresAt,
+		CreatedAt: token.CreatedAt,
+	}, nil
+}
+
+func (m *Memory) GetAPITokens(ctx context.Context, userID uuid.UUID) ([]models.APITokenResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return []models.APITokenResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []models.APITokenResponse{}
+	// Tokens are appended as they are created, newest first is backwards.
+	for _, token := range slices.Backward(m.apiTokens) {
+		if token.UserID == userID && token.revokedAt == nil {
+			tokens = append(tokens, token.APITokenResponse)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (m *Memory) GetAPITokenByHash(ctx context.Context, tokenHash []byte) (models.APIToken, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIToken{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.apiTokens {
+		if !bytes.Equal(token.tokenHash, tokenHash) {
+			continue
+		}
+
+		owner, err := m.activeUser(token.UserID)
+		if err != nil {
+			return models.APIToken{}, err
+		}
+
+		return models.APIToken{
+			ID:        token.ID,
+			UserID:    token.UserID,
+			Role:      owner.role,
+			Scopes:    token.Scopes,
+			ExpiresAt: token.ExpiresAt,
+			RevokedAt: token.revokedAt,
+		}, nil
+	}
+
+	return models.APIToken{}, sql.ErrNoRows
+}
+
+func (m *Memory) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.apiTokens {
+		if token.ID == id && (token.LastUsedAt == nil || token.LastUsedAt.Before(now.Add(-time.Minute))) {
+			token.LastUsedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) RevokeAPIToken(ctx context.Context, id, userID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.apiTokens {
+		if token.ID == id && token.UserID == userID && token.revokedAt == nil {
+			now := time.Now()
+			token.revokedAt = &now
+			return nil
+		}
+	}
+
+	return sql.ErrNoRows
+}
+
+func (m *Memory) RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.apiTokens {
+		if token.UserID == userID && token.revokedAt == nil {
+			token.revokedAt = &now
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func (u *user) account() models.AccountResponse {
+	return models.AccountResponse{
+		ID:              u.id,
+		Name:            u.name,
+		Email:           u.email,
+		EmailVerifiedAt: u.emailVerifiedAt,
+	}
+}
+
+func (m *Memory) Register(ctx context.Context, u *models.RegisterRequest) (models.RegisterResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.RegisterResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	registered := &user{
+		name:         u.Name,
+		email:        u.Email,
+		passwordHash: u.Password,
+		role:         models.RoleUser,
+	}
+	if err := m.insertUser(registered); err != nil {
+		return models.RegisterResponse{}, err
+	}
+
+	return models.RegisterResponse{
+		ID:        registered.id,
+		Name:      registered.name,
+		Email:     registered.email,
+		CreatedAt: timestamp(registered.createdAt),
+	}, nil
+}
+
+func (m *Memory) Login(ctx context.Context, email string) (models.LoginResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUserByEmail(email)
+	if err != nil {
+		return models.LoginResponse{}, err
+	}
+
+	return models.LoginResponse{
+		ID:              u.id,
+		Password:        u.passwordHash,
+		Email:           u.email,
+		Role:            u.role,
+		EmailVerifiedAt: u.emailVerifiedAt,
+	}, nil
+}
+
+func (m *Memory) GetAccountByEmail(ctx context.Context, email string) (models.AccountResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUserByEmail(email)
+	if err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	return u.account(), nil
+}
+
+func (m *Memory) VerifyEmail(ctx context.Context, id uuid.UUID, email string) (models.AccountResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	if u.email != email {
+		return models.AccountResponse{}, sql.ErrNoRows
+	}
+
+	if u.emailVerifiedAt == nil {
+		now := time.Now()
+		u.emailVerifiedAt = &now
+	}
+	touch(u)
+
+	return u.account(), nil
+}
+
+func (m *Memory) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return "", err
+	}
+
+	return u.passwordHash, nil
+}
+
+func (m *Memory) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return err
+	}
+
+	u.passwordHash = passwordHash
+	touch(u)
+
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, userID, familyID uuid.UUID, tokenHash []byte, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.insertRefreshToken(userID, familyID, tokenHash, expiresAt)
+	return nil
+}
+
+func (m *Memory) insertRefreshToken(userID, familyID uuid.UUID, tokenHash []byte, expiresAt time.Time) {
+	m.refreshTokens = append(m.refreshTokens, &refreshToken{
+		RefreshToken: models.RefreshToken{
+			ID:        uuid.New(),
+			UserID:    userID,
+			FamilyID:  familyID,
+			ExpiresAt: expiresAt,
+		},
+		tokenHash: bytes.Clone(tokenHash),
+	})
+}
+
+func (m *Memory) GetRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.refreshTokens {
+		if bytes.Equal(token.tokenHash, tokenHash) {
+			return token.RefreshToken, nil
+		}
+	}
+
+	return models.RefreshToken{}, sql.ErrNoRows
+}
+
+func (m *Memory) RotateRefreshToken(ctx context.Context, current models.RefreshToken, tokenHash []byte, expiresAt time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, token := range m.refreshTokens {
+		if token.ID != current.ID || token.UsedAt != nil || token.RevokedAt != nil {
+			continue
+		}
+
+		now := time.Now()
+		token.UsedAt = &now
+		m.insertRefreshToken(current.UserID, current.FamilyID, tokenHash, expiresAt)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (m *Memory) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	return m.revokeRefreshTokens(ctx, func(token *refreshToken) bool {
+		return token.FamilyID == familyID
+	})
+}
+
+func (m *Memory) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	return m.revokeRefreshTokens(ctx, func(token *refreshToken) bool {
+		return token.UserID == userID
+	})
+}
+
+func (m *Memory) revokeRefreshTokens(ctx context.Context, match func(*refreshToken) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash []byte, expiresAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.passwordResetTokens = append(m.passwordResetTokens, &passwordResetToken{
+		userID:    userID,
+		tokenHash: bytes.Clone(tokenHash),
+		expiresAt: expiresAt,
+	})
+
+	return nil
+}
+
+func (m *Memory) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string) (uuid.UUID, error) {
+	if err := ctx.Err(); err != nil {
+		return uuid.Nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	var reset *passwordResetToken
+	for _, token := range m.passwordResetTokens {
+		if bytes.Equal(token.tokenHash, tokenHash) && token.usedAt == nil && token.expiresAt.After(now) {
+			reset = token
+			break
+		}
+	}
+
+	if reset == nil {
+		return uuid.Nil, sql.ErrNoRows
+	}
+
+	u, err := m.activeUser(reset.userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	u.passwordHash = passwordHash
+	touch(u)
+
+	for _, token := range m.passwordResetTokens {
+		if token.userID == u.id && token.usedAt == nil {
+			token.usedAt = &now
+		}
+	}
+
+	return u.id, nil
+}
@@ -0,0 +1,156 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func (u *user) identityUser() models.IdentityUser {
+	return models.IdentityUser{
+		ID:              u.id,
+		Email:           u.email,
+		Role:            u.role,
+		EmailVerifiedAt: u.emailVerifiedAt,
+	}
+}
+
+func (m *Memory) LoginWithIdentity(ctx context.Context, provider, subject string) (models.IdentityUser, error) {
+	if err := ctx.Err(); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, i := range m.identities {
+		if i.provider != provider || i.subject != subject {
+			continue
+		}
+
+		u, err := m.activeUser(i.userID)
+		if err != nil {
+			return models.IdentityUser{}, err
+		}
+
+		i.lastLoginAt = time.Now()
+		return u.identityUser(), nil
+	}
+
+	return models.IdentityUser{}, sql.ErrNoRows
+}
+
+func (m *Memory) GetIdentityUserByEmail(ctx context.Context, email string) (models.IdentityUser, error) {
+	if err := ctx.Err(); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUserByEmail(email)
+	if err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return u.identityUser(), nil
+}
+
+func (m *Memory) GetIdentityUser(ctx context.Context, id uuid.UUID) (models.IdentityUser, error) {
+	if err := ctx.Err(); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return u.identityUser(), nil
+}
+
+func (m *Memory) CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insertIdentity(userID, provider, subject, email)
+}
+
+func (m *Memory) CreateUserWithIdentity(ctx context.Context, u *models.NewIdentityUser) (models.IdentityUser, error) {
+	if err := ctx.Err(); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkIdentityKeys(uuid.Nil, u.Provider, u.Subject); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	now := time.Now()
+	created := &user{
+		name:            u.Name,
+		fullName:        u.FullName,
+		email:           u.Email,
+		passwordHash:    u.PasswordHash,
+		role:            models.RoleUser,
+		emailVerifiedAt: &now,
+	}
+	if err := m.insertUser(created); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	if err := m.insertIdentity(created.id, u.Provider, u.Subject, u.Email); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return created.identityUser(), nil
+}
+
+// insertIdentity links an identity after checking the unique keys of
+// user_identities: a subject signs in to one user, a user has one identity
+// per provider.
+func (m *Memory) insertIdentity(userID uuid.UUID, provider, subject, email string) error {
+	if err := m.checkIdentityKeys(userID, provider, subject); err != nil {
+		return err
+	}
+
+	m.identities = append(m.identities, &identity{
+		userID:      userID,
+		provider:    provider,
+		subject:     subject,
+		email:       email,
+		lastLoginAt: time.Now(),
+	})
+
+	return nil
+}
+
+func (m *Memory) checkIdentityKeys(userID uuid.UUID, provider, subject string) error {
+	for _, i := range m.identities {
+		if i.provider != provider {
+			continue
+		}
+
+		if i.subject == subject {
+			return uniqueViolation("user_identities_provider_subject_key")
+		}
+
+		if i.userID == userID {
+			return uniqueViolation("user_identities_user_id_provider_key")
+		}
+	}
+
+	return nil
+}
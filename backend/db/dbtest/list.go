@@ -0,0 +1,115 @@
+package dbtest
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// listRow is an item of a list with the value of its sort column. Values are
+// compared as strings, see sortTime and sortFloat.
+type listRow[T any] struct {
+	item  T
+	id    uuid.UUID
+	value string
+}
+
+// sortTime formats t so that timestamps compare as strings.
+func sortTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// sortFloat formats f, which must not be negative, so that numbers compare as
+// strings.
+func sortFloat(f float64) string {
+	return fmt.Sprintf("%024.12f", f)
+}
+
+// newPage orders rows by value and id and cuts the page after the cursor of
+// params, as the keyset pagination of the queries package does. Total counts
+// every row, regardless of the cursor.
+func newPage[T any](params models.ListParams, rows []listRow[T]) models.Page[T] {
+	compare := func(a, b listRow[T]) int {
+		if c := strings.Compare(a.value, b.value); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.id[:], b.id[:])
+	}
+
+	slices.SortFunc(rows, func(a, b listRow[T]) int {
+		if params.Desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+
+	page := models.Page[T]{
+		Items: []T{},
+		Total: len(rows),
+	}
+
+	if params.Cursor != nil {
+		cursor := listRow[T]{id: params.Cursor.ID, value: params.Cursor.Value}
+		rows = slices.DeleteFunc(rows, func(row listRow[T]) bool {
+			c := compare(row, cursor)
+			return c == 0 || (c < 0) != params.Desc
+		})
+	}
+
+	for i, row := range rows {
+		if i == params.Limit {
+			last := rows[i-1]
+			page.NextCursor = &models.Cursor{Sort: params.SortKey(), Value: last.value, ID: last.id}
+			break
+		}
+		page.Items = append(page.Items, row.item)
+	}
+
+	return page
+}
+
+// matchUser applies the role, email and q filters of params, like the
+// filterUsers condition of the queries package.
+func matchUser(u *user, params models.ListParams) bool {
+	if role := params.Filter("role"); role != "" && string(u.role) != role {
+		return false
+	}
+
+	if email := params.Filter("email"); email != "" && !strings.EqualFold(u.email, email) {
+		return false
+	}
+
+	if search := params.Filter("q"); search != "" && !containsFold(u.name, search) && !containsFold(u.fullName, search) {
+		return false
+	}
+
+	return true
+}
+
+// userSortValue is the value of the sort column field of u.
+func userSortValue(u *user, field string) (string, error) {
+	switch field {
+	case "name":
+		return u.name, nil
+	case "email":
+		return u.email, nil
+	case "created_at":
+		return sortTime(u.createdAt), nil
+	case "deleted_at":
+		if u.deletedAt != nil {
+			return sortTime(*u.deletedAt), nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown sort field %q", field)
+}
+
+func compareUsers(a, b *user) int {
+	return cmp.Or(a.createdAt.Compare(b.createdAt), bytes.Compare(a.id[:], b.id[:]))
+}
@@ -0,0 +1,265 @@
+// Package dbtest keeps the data of the API in memory, for tests that exercise
+// controllers and routes without a PostgreSQL server. It follows the
+// constraints of the migrations that the handlers rely on: unique keys of
+// active users, cascades when a user is purged and the row version bumped on
+// every update.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// DefaultRolePermissions is the policy seeded by the migrations.
+var DefaultRolePermissions = map[models.UserRole][]string{
+	models.RoleAdmin: {
+		models.PermissionProfileRead,
+		models.PermissionProfileWrite,
+		models.PermissionTokensManage,
+		models.PermissionUsersDelete,
+		models.PermissionUsersImpersonate,
+		models.PermissionUsersManageRoles,
+		models.PermissionUsersRead,
+		models.PermissionUsersReadPrivate,
+		models.PermissionUsersWrite,
+	},
+	models.RoleOwner: {
+		models.PermissionProfileRead,
+		models.PermissionProfileWrite,
+		models.PermissionUsersRead,
+		models.PermissionUsersWrite,
+	},
+	models.RoleUser: {
+		models.PermissionProfileRead,
+		models.PermissionProfileWrite,
+		models.PermissionUsersRead,
+	},
+}
+
+type user struct {
+	id              uuid.UUID
+	name            string
+	fullName        string
+	email           string
+	phoneNumber     string
+	passwordHash    string
+	role            models.UserRole
+	emailVerifiedAt *time.Time
+	createdAt       time.Time
+	updatedAt       time.Time
+	deletedAt       *time.Time
+	version         int64
+}
+
+type refreshToken struct {
+	models.RefreshToken
+	tokenHash []byte
+}
+
+type passwordResetToken struct {
+	userID    uuid.UUID
+	tokenHash []byte
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+type apiToken struct {
+	models.APITokenResponse
+	tokenHash []byte
+	revokedAt *time.Time
+}
+
+type recoveryCode struct {
+	codeHash []byte
+	usedAt   *time.Time
+}
+
+type identity struct {
+	userID      uuid.UUID
+	provider    string
+	subject     string
+	email       string
+	lastLoginAt time.Time
+}
+
+// Memory is a thread-safe, in-memory db.Repository. The zero value is not
+// usable, create one with NewMemory.
+type Memory struct {
+	mu sync.Mutex
+
+	users               []*user
+	refreshTokens       []*refreshToken
+	passwordResetTokens []*passwordResetToken
+	apiTokens           []*apiToken
+	mfa                 map[uuid.UUID]*models.UserMFA
+	recoveryCodes       map[uuid.UUID][]*recoveryCode
+	identities          []*identity
+	rolePermissions     map[models.UserRole][]string
+	auditLogs           []models.AuditLog
+}
+
+var _ db.Repository = (*Memory)(nil)
+
+// NewMemory returns an empty repository with the default role permissions.
+func NewMemory() *Memory {
+	rolePermissions := map[models.UserRole][]string{}
+	for role, permissions := range DefaultRolePermissions {
+		rolePermissions[role] = slices.Clone(permissions)
+	}
+
+	return &Memory{
+		mfa:             map[uuid.UUID]*models.UserMFA{},
+		recoveryCodes:   map[uuid.UUID][]*recoveryCode{},
+		rolePermissions: rolePermissions,
+	}
+}
+
+// SetRolePermissions replaces the permissions granted to role.
+func (m *Memory) SetRolePermissions(role models.UserRole, permissions ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rolePermissions[role] = slices.Clone(permissions)
+}
+
+// AuditLogs returns every audit log entry written so far, oldest first.
+func (m *Memory) AuditLogs() []models.AuditLog {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.auditLogs)
+}
+
+func (m *Memory) GetRolePermissions(ctx context.Context) (map[models.UserRole][]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	permissions := map[models.UserRole][]string{}
+	for role, granted := range m.rolePermissions {
+		permissions[role] = slices.Sorted(slices.Values(granted))
+	}
+
+	return permissions, nil
+}
+
+func (m *Memory) CreateAuditLog(ctx context.Context, entry *models.AuditLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.insertAuditLog(*entry)
+	return nil
+}
+
+func (m *Memory) insertAuditLog(entry models.AuditLog) {
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now()
+	entry.Details = maps.Clone(entry.Details)
+	if entry.Details == nil {
+		entry.Details = models.AuditDetails{}
+	}
+
+	m.auditLogs = append(m.auditLogs, entry)
+}
+
+// activeUser returns the user with id unless it was soft deleted.
+func (m *Memory) activeUser(id uuid.UUID) (*user, error) {
+	for _, u := range m.users {
+		if u.id == id && u.deletedAt == nil {
+			return u, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m *Memory) activeUserByEmail(email string) (*user, error) {
+	for _, u := range m.users {
+		if u.email == email && u.deletedAt == nil {
+			return u, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// checkUserKeys fails like the partial unique indexes of users when another
+// active user, other than id, already has name or email.
+func (m *Memory) checkUserKeys(id uuid.UUID, name, email string) error {
+	for _, u := range m.users {
+		if u.id == id || u.deletedAt != nil {
+			continue
+		}
+
+		if u.name == name {
+			return uniqueViolation("users_name_key")
+		}
+
+		if u.email == email {
+			return uniqueViolation("users_email_key")
+		}
+	}
+
+	return nil
+}
+
+// insertUser adds a user after checking its unique keys.
+func (m *Memory) insertUser(u *user) error {
+	if err := m.checkUserKeys(uuid.Nil, u.name, u.email); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	u.id = uuid.New()
+	u.createdAt = now
+	u.updatedAt = now
+	u.version = 1
+
+	m.users = append(m.users, u)
+	return nil
+}
+
+// touch records an update of u, as the bump_version trigger does.
+func touch(u *user) {
+	u.updatedAt = time.Now()
+	u.version++
+}
+
+// uniqueViolation is the error the pgx driver returns for a duplicate key.
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		ConstraintName: constraint,
+	}
+}
+
+// timestamp formats t the way a timestamptz column scanned into a string is.
+func timestamp(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// containsFold reports whether substr is within s, ignoring case, like ILIKE
+// with a contains pattern.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
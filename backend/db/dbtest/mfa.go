@@ -0,0 +1,157 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func (m *Memory) GetMFA(ctx context.Context, userID uuid.UUID) (models.UserMFA, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserMFA{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[userID]
+	if !ok {
+		return models.UserMFA{}, sql.ErrNoRows
+	}
+
+	return *mfa, nil
+}
+
+func (m *Memory) EnrollMFA(ctx context.Context, userID uuid.UUID, secretEncrypted []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if mfa, ok := m.mfa[userID]; ok && mfa.ConfirmedAt != nil {
+		return false, nil
+	}
+
+	m.mfa[userID] = &models.UserMFA{
+		UserID:          userID,
+		SecretEncrypted: bytes.Clone(secretEncrypted),
+	}
+
+	return true, nil
+}
+
+func (m *Memory) ConfirmMFA(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[userID]
+	if !ok || mfa.ConfirmedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	mfa.ConfirmedAt = &now
+	mfa.LastUsedStep = step
+	m.replaceRecoveryCodes(userID, recoveryCodeHashes)
+
+	return true, nil
+}
+
+func (m *Memory) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mfa, ok := m.mfa[userID]
+	if !ok || mfa.ConfirmedAt == nil || mfa.LastUsedStep >= step {
+		return false, nil
+	}
+
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (m *Memory) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, code := range m.recoveryCodes[userID] {
+		if code.usedAt == nil && bytes.Equal(code.codeHash, codeHash) {
+			now := time.Now()
+			code.usedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Memory) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, code := range m.recoveryCodes[userID] {
+		if code.usedAt == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Memory) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodeHashes [][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replaceRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+func (m *Memory) replaceRecoveryCodes(userID uuid.UUID, recoveryCodeHashes [][]byte) {
+	codes := make([]*recoveryCode, 0, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes = append(codes, &recoveryCode{codeHash: bytes.Clone(hash)})
+	}
+
+	m.recoveryCodes[userID] = codes
+}
+
+func (m *Memory) DisableMFA(ctx context.Context, userID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.recoveryCodes, userID)
+	delete(m.mfa, userID)
+
+	return nil
+}
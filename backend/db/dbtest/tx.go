@@ -0,0 +1,94 @@
+package dbtest
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func (m *Memory) WithTx(ctx context.Context, fn func(tx db.Repository) error) error {
+	return m.WithTxOptions(ctx, db.TxOptions{}, fn)
+}
+
+// WithTxOptions runs fn on m and restores what m held before when fn returns
+// an error or panics. Nested calls restore their own changes only, like a
+// savepoint. Memory never fails to serialize, so fn runs once and opts are
+// ignored.
+//
+// Units of work are not isolated from each other: a rollback also undoes what
+// other goroutines changed while fn ran.
+func (m *Memory) WithTxOptions(ctx context.Context, opts db.TxOptions, fn func(tx db.Repository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	restore := m.snapshot()
+	m.mu.Unlock()
+
+	committed := false
+	// Also runs when fn panics, the panic continues once rolled back.
+	defer func() {
+		if !committed {
+			m.mu.Lock()
+			restore()
+			m.mu.Unlock()
+		}
+	}()
+
+	if err := fn(m); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// snapshot copies the data of m and returns a function that puts the copy
+// back. m.mu must be held to call both.
+func (m *Memory) snapshot() func() {
+	users := cloneRows(m.users)
+	refreshTokens := cloneRows(m.refreshTokens)
+	passwordResetTokens := cloneRows(m.passwordResetTokens)
+	apiTokens := cloneRows(m.apiTokens)
+	identities := cloneRows(m.identities)
+	auditLogs := slices.Clone(m.auditLogs)
+	rolePermissions := maps.Clone(m.rolePermissions)
+
+	mfa := make(map[uuid.UUID]*models.UserMFA, len(m.mfa))
+	for userID, enrollment := range m.mfa {
+		clone := *enrollment
+		mfa[userID] = &clone
+	}
+
+	recoveryCodes := make(map[uuid.UUID][]*recoveryCode, len(m.recoveryCodes))
+	for userID, codes := range m.recoveryCodes {
+		recoveryCodes[userID] = cloneRows(codes)
+	}
+
+	return func() {
+		m.users = users
+		m.refreshTokens = refreshTokens
+		m.passwordResetTokens = passwordResetTokens
+		m.apiTokens = apiTokens
+		m.identities = identities
+		m.auditLogs = auditLogs
+		m.rolePermissions = rolePermissions
+		m.mfa = mfa
+		m.recoveryCodes = recoveryCodes
+	}
+}
+
+func cloneRows[T any](rows []*T) []*T {
+	clones := make([]*T, len(rows))
+	for i, row := range rows {
+		clone := *row
+		clones[i] = &clone
+	}
+
+	return clones
+}
@@ -0,0 +1,127 @@
+package dbtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/db/dbtest"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+var errAborted = errors.New("aborted")
+
+func audit(ctx context.Context, t *testing.T, repository db.Repository, action string) {
+	t.Helper()
+
+	if err := repository.CreateAuditLog(ctx, &models.AuditLog{Action: action}); err != nil {
+		t.Fatalf("CreateAuditLog: %v", err)
+	}
+}
+
+func actions(m *dbtest.Memory) []string {
+	var actions []string
+	for _, entry := range m.AuditLogs() {
+		actions = append(actions, entry.Action)
+	}
+
+	return actions
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	m := dbtest.NewMemory()
+
+	if err := m.WithTx(ctx, func(tx db.Repository) error {
+		audit(ctx, t, tx, "committed")
+		return nil
+	}); err != nil {
+		t.Fatalf("WithTx = %v, want the unit of work committed", err)
+	}
+
+	if err := m.WithTx(ctx, func(tx db.Repository) error {
+		audit(ctx, t, tx, "rolled back")
+		return errAborted
+	}); !errors.Is(err, errAborted) {
+		t.Fatalf("WithTx = %v, want the error of fn", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("WithTx recovered the panic of fn")
+			}
+		}()
+
+		m.WithTx(ctx, func(tx db.Repository) error {
+			audit(ctx, t, tx, "panicked")
+			panic(errAborted)
+		})
+	}()
+
+	if got := actions(m); len(got) != 1 || got[0] != "committed" {
+		t.Fatalf("audit logs = %q, want only the committed entry", got)
+	}
+}
+
+func TestWithTxSavepoint(t *testing.T) {
+	ctx := context.Background()
+	m := dbtest.NewMemory()
+
+	err := m.WithTx(ctx, func(tx db.Repository) error {
+		audit(ctx, t, tx, "outer")
+
+		if err := tx.WithTx(ctx, func(tx db.Repository) error {
+			audit(ctx, t, tx, "released")
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.WithTx(ctx, func(tx db.Repository) error {
+			audit(ctx, t, tx, "rolled back")
+			return errAborted
+		}); !errors.Is(err, errAborted) {
+			t.Fatalf("nested WithTx = %v, want the error of fn", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	if got := actions(m); len(got) != 2 || got[0] != "outer" || got[1] != "released" {
+		t.Fatalf("audit logs = %q, want outer and released", got)
+	}
+
+	// Rolling back the unit of work also undoes its released savepoints.
+	err = m.WithTx(ctx, func(tx db.Repository) error {
+		return tx.WithTx(ctx, func(tx db.Repository) error {
+			audit(ctx, t, tx, "released")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	err = m.WithTx(ctx, func(tx db.Repository) error {
+		if err := tx.WithTx(ctx, func(tx db.Repository) error {
+			audit(ctx, t, tx, "released then rolled back")
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		return errAborted
+	})
+	if !errors.Is(err, errAborted) {
+		t.Fatalf("WithTx = %v, want the error of fn", err)
+	}
+
+	if got := actions(m); len(got) != 3 {
+		t.Fatalf("audit logs = %q, want outer and released twice", got)
+	}
+}
@@ -0,0 +1,638 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/api/queries"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+)
+
+func (u *user) response() models.UserResponse {
+	return models.UserResponse{
+		ID:          u.id,
+		Name:        u.name,
+		FullName:    u.fullName,
+		Email:       u.email,
+		PhoneNumber: u.phoneNumber,
+		Role:        u.role,
+		Version:     u.version,
+	}
+}
+
+func (u *user) createResponse() models.CreateUserResponse {
+	return models.CreateUserResponse{
+		ID:          u.id,
+		Name:        u.name,
+		FullName:    u.fullName,
+		Email:       u.email,
+		PhoneNumber: u.phoneNumber,
+		Role:        u.role,
+		CreatedAt:   timestamp(u.createdAt),
+	}
+}
+
+func (u *user) deletedResponse() models.DeletedUserResponse {
+	return models.DeletedUserResponse{
+		UserResponse: u.response(),
+		DeletedAt:    *u.deletedAt,
+	}
+}
+
+func (m *Memory) CreateUser(ctx context.Context, u *models.CreateUserRequest) (models.CreateUserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := &user{
+		name:         u.Name,
+		fullName:     u.FullName,
+		email:        u.Email,
+		phoneNumber:  u.PhoneNumber,
+		passwordHash: u.Password,
+		role:         models.UserRole(u.Role),
+	}
+	if err := m.insertUser(created); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	return created.createResponse(), nil
+}
+
+func (m *Memory) CreateFirstAdmin(ctx context.Context, u *models.CreateAdminRequest) (models.CreateUserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.countAdmins() > 0 {
+		return models.CreateUserResponse{}, queries.ErrAdminExists
+	}
+
+	now := time.Now()
+	admin := &user{
+		name:            u.Name,
+		fullName:        u.FullName,
+		email:           u.Email,
+		passwordHash:    u.Password,
+		role:            models.RoleAdmin,
+		emailVerifiedAt: &now,
+	}
+	if err := m.insertUser(admin); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	m.insertAuditLog(models.AuditLog{
+		Action:   models.AuditAdminCreated,
+		TargetID: &admin.id,
+		Details:  models.AuditDetails{"source": "cli"},
+	})
+
+	return admin.createResponse(), nil
+}
+
+// ImportUsers creates all rows or none of them, like the transaction of the
+// queries package: conflicting rows are returned in a
+// queries.ImportConflictError and with dryRun nothing is kept either.
+func (m *Memory) ImportUsers(ctx context.Context, rows []models.ImportUserRow, dryRun bool, audit models.AuditLog) ([]models.CreateUserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userCount, auditCount := len(m.users), len(m.auditLogs)
+	rollback := func() {
+		m.users = m.users[:userCount]
+		m.auditLogs = m.auditLogs[:auditCount]
+	}
+
+	users := make([]models.CreateUserResponse, 0, len(rows))
+	conflicts := map[int]string{}
+
+	for i, row := range rows {
+		imported := &user{
+			name:         row.Name,
+			fullName:     row.FullName,
+			email:        row.Email,
+			phoneNumber:  row.PhoneNumber,
+			passwordHash: row.PasswordHash,
+			role:         models.UserRole(row.Role),
+		}
+
+		if row.EmailVerified {
+			now := time.Now()
+			imported.emailVerifiedAt = &now
+		}
+
+		if conflict, ok := dberrors.As(m.insertUser(imported)); ok {
+			conflicts[i] = conflict.Field
+			continue
+		}
+
+		entry := audit
+		entry.TargetID = &imported.id
+		m.insertAuditLog(entry)
+
+		users = append(users, imported.createResponse())
+	}
+
+	if len(conflicts) > 0 {
+		rollback()
+		return nil, &queries.ImportConflictError{Conflicts: conflicts}
+	}
+
+	if dryRun {
+		rollback()
+	}
+
+	return users, nil
+}
+
+func (m *Memory) ExportUsers(ctx context.Context, params models.ListParams, fn func(models.ExportUser) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	users := []*user{}
+	for _, u := range m.users {
+		if u.deletedAt == nil && matchUser(u, params) {
+			users = append(users, u)
+		}
+	}
+
+	slices.SortFunc(users, compareUsers)
+
+	exported := make([]models.ExportUser, 0, len(users))
+	for _, u := range users {
+		exported = append(exported, models.ExportUser{
+			ID:            u.id,
+			Name:          u.name,
+			FullName:      u.fullName,
+			Email:         u.email,
+			PhoneNumber:   u.phoneNumber,
+			Role:          u.role,
+			EmailVerified: u.emailVerifiedAt != nil,
+			CreatedAt:     u.createdAt,
+		})
+	}
+	m.mu.Unlock()
+
+	// fn writes the response, it is called without holding the lock.
+	for _, user := range exported {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) GetUsers(ctx context.Context, params models.ListParams) (models.Page[models.UserResponse], error) {
+	if err := ctx.Err(); err != nil {
+		return models.Page[models.UserResponse]{}, err
+	}
+
+	if !slices.Contains(queries.UserSortFields, params.Sort) {
+		return models.Page[models.UserResponse]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := []listRow[models.UserResponse]{}
+	for _, u := range m.users {
+		if u.deletedAt != nil || !matchUser(u, params) {
+			continue
+		}
+
+		value, err := userSortValue(u, params.Sort)
+		if err != nil {
+			return models.Page[models.UserResponse]{}, err
+		}
+
+		rows = append(rows, listRow[models.UserResponse]{item: u.response(), id: u.id, value: value})
+	}
+
+	return newPage(params, rows), nil
+}
+
+// SearchUsers finds active users with every term of search in their name,
+// full name or email. The rank counts the fields each term occurs in, it
+// orders results like the ranking of the queries package without matching
+// its values.
+func (m *Memory) SearchUsers(ctx context.Context, search string, params models.ListParams) (models.Page[models.UserSearchResult], error) {
+	if err := ctx.Err(); err != nil {
+		return models.Page[models.UserSearchResult]{}, err
+	}
+
+	if !slices.Contains(queries.UserSearchSortFields, params.Sort) {
+		return models.Page[models.UserSearchResult]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	terms := utils.SearchTerms(search)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := []listRow[models.UserSearchResult]{}
+	for _, u := range m.users {
+		if u.deletedAt != nil {
+			continue
+		}
+
+		if role := params.Filter("role"); role != "" && string(u.role) != role {
+			continue
+		}
+
+		rank := searchRank(terms, u.name, u.fullName, u.email)
+		if rank == 0 {
+			continue
+		}
+
+		rows = append(rows, listRow[models.UserSearchResult]{
+			item:  models.UserSearchResult{UserResponse: u.response(), Rank: rank},
+			id:    u.id,
+			value: sortFloat(rank),
+		})
+	}
+
+	return newPage(params, rows), nil
+}
+
+// searchRank is the number of fields each term occurs in, or 0 when a term
+// occurs in none.
+func searchRank(terms []string, fields ...string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	rank := 0
+	for _, term := range terms {
+		found := 0
+		for _, field := range fields {
+			if containsFold(field, term) {
+				found++
+			}
+		}
+
+		if found == 0 {
+			return 0
+		}
+		rank += found
+	}
+
+	return float64(rank)
+}
+
+func (m *Memory) GetUser(ctx context.Context, id uuid.UUID) (models.UserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	return u.response(), nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, id uuid.UUID, u *models.UpdateUserRequest, version *int64) (models.UpdateUserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UpdateUserResponse{}, err
+	}
+
+	set := func(value *string) bool {
+		return value != nil && *value != ""
+	}
+
+	if !set(u.Name) && !set(u.FullName) && !set(u.Email) && !set(u.PhoneNumber) {
+		return models.UpdateUserResponse{}, fmt.Errorf("no fields to update")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, err := m.activeUser(id)
+	if err != nil {
+		return models.UpdateUserResponse{}, err
+	}
+
+	if version != nil && existing.version != *version {
+		return models.UpdateUserResponse{}, queries.ErrVersionMismatch
+	}
+
+	updated := *existing
+	if set(u.Name) {
+		updated.name = *u.Name
+	}
+	if set(u.FullName) {
+		updated.fullName = *u.FullName
+	}
+	if set(u.Email) {
+		if *u.Email != updated.email {
+			// A new address has to be verified again.
+			updated.emailVerifiedAt = nil
+		}
+		updated.email = *u.Email
+	}
+	if set(u.PhoneNumber) {
+		updated.phoneNumber = *u.PhoneNumber
+	}
+
+	if err := m.checkUserKeys(id, updated.name, updated.email); err != nil {
+		return models.UpdateUserResponse{}, err
+	}
+
+	touch(&updated)
+	*existing = updated
+
+	return models.UpdateUserResponse{
+		ID:          existing.id,
+		Name:        existing.name,
+		FullName:    existing.fullName,
+		Email:       existing.email,
+		PhoneNumber: existing.phoneNumber,
+		Role:        existing.role,
+		UpdatedAt:   timestamp(existing.updatedAt),
+		Version:     existing.version,
+	}, nil
+}
+
+func (m *Memory) UpdateUserRole(ctx context.Context, id uuid.UUID, role models.UserRole, audit *models.AuditLog) (models.UserRoleResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserRoleResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return models.UserRoleResponse{}, err
+	}
+
+	previous := u.role
+	if previous == role {
+		return models.UserRoleResponse{}, queries.ErrRoleUnchanged
+	}
+
+	if role != models.RoleAdmin {
+		if err := m.guardLastAdmin(u); err != nil {
+			return models.UserRoleResponse{}, err
+		}
+	}
+
+	u.role = role
+	touch(u)
+
+	audit.TargetID = &u.id
+	audit.Details = models.AuditDetails{"from": previous, "to": role}
+	m.insertAuditLog(*audit)
+
+	return models.UserRoleResponse{
+		ID:           u.id,
+		Role:         u.role,
+		PreviousRole: previous,
+		UpdatedAt:    timestamp(u.updatedAt),
+	}, nil
+}
+
+// guardLastAdmin fails with queries.ErrLastAdmin when u is the only active
+// admin left.
+func (m *Memory) guardLastAdmin(u *user) error {
+	if u.role == models.RoleAdmin && u.deletedAt == nil && m.countAdmins() <= 1 {
+		return queries.ErrLastAdmin
+	}
+
+	return nil
+}
+
+func (m *Memory) countAdmins() int {
+	admins := 0
+	for _, u := range m.users {
+		if u.role == models.RoleAdmin && u.deletedAt == nil {
+			admins++
+		}
+	}
+
+	return admins
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.activeUser(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.guardLastAdmin(u); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	u.deletedAt = &now
+	touch(u)
+
+	m.identities = slices.DeleteFunc(m.identities, func(i *identity) bool {
+		return i.userID == id
+	})
+
+	audit.TargetID = &id
+	m.insertAuditLog(*audit)
+
+	return nil
+}
+
+func (m *Memory) GetDeletedUsers(ctx context.Context, params models.ListParams) (models.Page[models.DeletedUserResponse], error) {
+	if err := ctx.Err(); err != nil {
+		return models.Page[models.DeletedUserResponse]{}, err
+	}
+
+	if !slices.Contains(queries.DeletedUserSortFields, params.Sort) {
+		return models.Page[models.DeletedUserResponse]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows := []listRow[models.DeletedUserResponse]{}
+	for _, u := range m.users {
+		if u.deletedAt == nil || !matchUser(u, params) {
+			continue
+		}
+
+		value, err := userSortValue(u, params.Sort)
+		if err != nil {
+			return models.Page[models.DeletedUserResponse]{}, err
+		}
+
+		rows = append(rows, listRow[models.DeletedUserResponse]{item: u.deletedResponse(), id: u.id, value: value})
+	}
+
+	return newPage(params, rows), nil
+}
+
+func (m *Memory) GetDeletedUser(ctx context.Context, id uuid.UUID) (models.DeletedUserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.DeletedUserResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.deletedUser(id)
+	if err != nil {
+		return models.DeletedUserResponse{}, err
+	}
+
+	return u.deletedResponse(), nil
+}
+
+func (m *Memory) deletedUser(id uuid.UUID) (*user, error) {
+	for _, u := range m.users {
+		if u.id == id && u.deletedAt != nil {
+			return u, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (m *Memory) RestoreUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) (models.UserResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.deletedUser(id)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	if err := m.checkUserKeys(id, u.name, u.email); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	u.deletedAt = nil
+	touch(u)
+
+	audit.TargetID = &u.id
+	m.insertAuditLog(*audit)
+
+	return u.response(), nil
+}
+
+func (m *Memory) PurgeUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, err := m.deletedUser(id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.guardLastAdmin(u); err != nil {
+		return err
+	}
+
+	m.removeUser(id)
+
+	audit.TargetID = &id
+	m.insertAuditLog(*audit)
+
+	return nil
+}
+
+func (m *Memory) PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := []*user{}
+	for _, u := range m.users {
+		if u.deletedAt != nil && u.deletedAt.Before(before) {
+			expired = append(expired, u)
+		}
+	}
+
+	slices.SortFunc(expired, func(a, b *user) int {
+		return a.deletedAt.Compare(*b.deletedAt)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, u := range expired {
+		m.removeUser(u.id)
+		m.insertAuditLog(models.AuditLog{
+			Action:   models.AuditUserPurged,
+			TargetID: &u.id,
+			Details:  models.AuditDetails{"source": "retention"},
+		})
+	}
+
+	return len(expired), nil
+}
+
+// removeUser deletes a user and cascades like the foreign keys referencing
+// users: tokens, two factor settings and identities go with it, audit log
+// entries it made lose their actor.
+func (m *Memory) removeUser(id uuid.UUID) {
+	m.users = slices.DeleteFunc(m.users, func(u *user) bool {
+		return u.id == id
+	})
+	m.refreshTokens = slices.DeleteFunc(m.refreshTokens, func(t *refreshToken) bool {
+		return t.UserID == id
+	})
+	m.passwordResetTokens = slices.DeleteFunc(m.passwordResetTokens, func(t *passwordResetToken) bool {
+		return t.userID == id
+	})
+	m.apiTokens = slices.DeleteFunc(m.apiTokens, func(t *apiToken) bool {
+		return t.UserID == id
+	})
+	m.identities = slices.DeleteFunc(m.identities, func(i *identity) bool {
+		return i.userID == id
+	})
+	delete(m.mfa, id)
+	delete(m.recoveryCodes, id)
+
+	for i, entry := range m.auditLogs {
+		if entry.ActorID != nil && *entry.ActorID == id {
+			m.auditLogs[i].ActorID = nil
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/otterly-id/otterly/backend/db/migrations"
+)
+
+// migrationsTable is where the applied version is kept. It has the layout the
+// migrate CLI uses, so the CLI and the binary can take turns on a database.
+const migrationsTable = "schema_migrations"
+
+// advisoryLockSalt is the salt the migrate CLI mixes into its advisory lock
+// id. Taking the same lock keeps the CLI and every replica of the backend
+// from migrating at the same time.
+const advisoryLockSalt uint32 = 1486364155
+
+// Migrator applies the migrations embedded in the binary.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []migrations.Migration
+
+	// Logf reports each migration as it is applied, it may be nil.
+	Logf func(format string, args ...any)
+	// Warnf reports what Up leaves alone instead of failing, it may be nil.
+	Warnf func(format string, args ...any)
+}
+
+// MigrationStatus is the state of the schema of a database.
+type MigrationStatus struct {
+	// Version is the last applied migration, 0 when there is none.
+	Version uint
+	// Dirty is set when a migration failed halfway outside of a
+	// transaction. Nothing is migrated until the schema is fixed by hand and
+	// the version forced.
+	Dirty   bool
+	Pending []migrations.Migration
+}
+
+// NewMigrator loads the embedded migrations. It refuses migrations that fail
+// migrations.Lint, so a destructive one never reaches a database.
+func NewMigrator(db *sqlx.DB) (*Migrator, error) {
+	loaded, err := migrations.Load(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrations.Lint(loaded); err != nil {
+		return nil, fmt.Errorf("destructive migrations: %w", err)
+	}
+
+	return &Migrator{db: db, migrations: loaded}, nil
+}
+
+// Migrator returns a Migrator on the connection pool.
+func (q *Queries) Migrator() (*Migrator, error) {
+	return NewMigrator(q.pool)
+}
+
+// Up applies every pending migration. A schema migrated by a newer release is
+// left as it is, so replicas of the previous release keep starting during a
+// rolling deploy.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		target, err := m.upTarget(current)
+		if err != nil {
+			return err
+		}
+
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// Down reverts the last steps migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of steps %d", steps)
+	}
+
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		target, err := m.downTarget(current, steps)
+		if err != nil {
+			return err
+		}
+
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// Goto applies or reverts migrations until version is the last one applied.
+// Version 0 reverts every migration.
+func (m *Migrator) Goto(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if err := m.known(current); err != nil {
+			return err
+		}
+
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Force records version as applied and clean without running any migration,
+// after a dirty schema has been fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("no migration with version %d", version)
+	}
+
+	return m.locked(ctx, func(conn *sqlx.Conn) error {
+		return setVersion(ctx, conn, version)
+	})
+}
+
+// Status reads the applied version and the migrations still to apply.
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	var exists bool
+	if err := m.db.GetContext(ctx, &exists, "SELECT to_regclass($1) IS NOT NULL", migrationsTable); err != nil {
+		return MigrationStatus{}, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	var status MigrationStatus
+	if exists {
+		var err error
+		status.Version, status.Dirty, err = readVersion(ctx, m.db)
+		if err != nil {
+			return MigrationStatus{}, err
+		}
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > status.Version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+
+	return status, nil
+}
+
+// current reads the applied version, failing when the schema is dirty.
+func (m *Migrator) current(ctx context.Context, conn *sqlx.Conn) (uint, error) {
+	version, dirty, err := readVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d, fix the schema and run migrate force with the version it matches", version)
+	}
+
+	return version, nil
+}
+
+// known fails for an applied version this binary does not know, i.e. one of
+// a newer release.
+func (m *Migrator) known(version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("database is at version %d, which is not one of the embedded migrations", version)
+	}
+
+	return nil
+}
+
+// upTarget returns the version Up migrates a schema at current to: the last
+// migration, or current when it is newer than every migration.
+func (m *Migrator) upTarget(current uint) (uint, error) {
+	latest := m.migrations[len(m.migrations)-1].Version
+	if current > latest {
+		if m.Warnf != nil {
+			m.Warnf("Database is at version %d, newer than the last embedded migration %d, nothing to apply", current, latest)
+		}
+		return current, nil
+	}
+
+	if err := m.known(current); err != nil {
+		return 0, err
+	}
+
+	return latest, nil
+}
+
+// downTarget returns the version a schema at current is left at once Down
+// reverted steps migrations, 0 when there are not that many.
+func (m *Migrator) downTarget(current uint, steps int) (uint, error) {
+	if err := m.known(current); err != nil {
+		return 0, err
+	}
+
+	target := m.index(current) - steps
+	if target < 0 {
+		return 0, nil
+	}
+
+	return m.migrations[target].Version, nil
+}
+
+// step is a migration file to run and the version it leaves the schema at.
+type step struct {
+	migration  migrations.Migration
+	statements string
+	version    uint
+}
+
+// migrate runs the migrations between current and target one at a time.
+func (m *Migrator) migrate(ctx context.Context, conn *sqlx.Conn, current, target uint) error {
+	for _, step := range m.steps(current, target) {
+		if err := m.apply(ctx, conn, step); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// steps lists the files migrate runs to get from current to target, in order:
+// the up files of the migrations after current, or the down files of the
+// migrations from current back to the one after target.
+func (m *Migrator) steps(current, target uint) []step {
+	var steps []step
+
+	for current < target {
+		next := m.migrations[m.index(current)+1]
+		steps = append(steps, step{migration: next, statements: next.Up, version: next.Version})
+		current = next.Version
+	}
+
+	for current > target {
+		i := m.index(current)
+
+		var previous uint
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+
+		steps = append(steps, step{migration: m.migrations[i], statements: m.migrations[i].Down, version: previous})
+		current = previous
+	}
+
+	return steps
+}
+
+// apply runs one migration file and records the version it leaves the schema
+// at in the same transaction, so a failed migration leaves neither changes nor
+// a dirty version behind.
+func (m *Migrator) apply(ctx context.Context, conn *sqlx.Conn, step step) error {
+	migration, version := step.migration, step.version
+
+	direction := "up"
+	if version < migration.Version {
+		direction = "down"
+	}
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, step.statements); err != nil {
+		return fmt.Errorf("migration %s.%s.sql failed: %w", migration, direction, err)
+	}
+
+	if err := setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s.%s.sql: %w", migration, direction, err)
+	}
+
+	if m.Logf != nil {
+		m.Logf("Applied %s.%s.sql", migration, direction)
+	}
+
+	return nil
+}
+
+// index returns the position of version in the migrations, -1 for version 0
+// and for unknown versions.
+func (m *Migrator) index(version uint) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// locked runs fn on a connection holding the migration lock, waiting for
+// whoever holds it now.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sqlx.Conn) error) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	lockID, err := migrationLockID(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		// Releases the lock and resets what the migrations set for the
+		// session, like the time zone, before the connection goes back
+		// to the pool. A connection that could not be reset is closed.
+		if _, err := conn.ExecContext(context.Background(), "DISCARD ALL"); err != nil {
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+migrationsTable+` (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`); err != nil {
+		return fmt.Errorf("failed to create %s table: %w", migrationsTable, err)
+	}
+
+	return fn(conn)
+}
+
+// migrationLockID returns the lock id the migrate CLI uses for the database
+// and schema conn is connected to.
+func migrationLockID(ctx context.Context, conn *sqlx.Conn) (int64, error) {
+	var database, schema string
+	if err := conn.QueryRowxContext(ctx, "SELECT current_database(), current_schema()").Scan(&database, &schema); err != nil {
+		return 0, fmt.Errorf("failed to read database name: %w", err)
+	}
+
+	return advisoryLockID(database, schema, migrationsTable), nil
+}
+
+// advisoryLockID derives a lock id the way the migrate CLI does: the checksum
+// of names followed by database, multiplied by advisoryLockSalt.
+func advisoryLockID(database string, names ...string) int64 {
+	sum := crc32.ChecksumIEEE([]byte(strings.Join(append(slices.Clone(names), database), "\x00")))
+
+	return int64(sum * advisoryLockSalt)
+}
+
+func readVersion(ctx context.Context, db sqlx.QueryerContext) (uint, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+
+	err := db.QueryRowxContext(ctx, "SELECT version, dirty FROM "+migrationsTable+" LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return uint(version), dirty, nil
+}
+
+// setVersion records version as applied and clean, 0 records that no
+// migration is.
+func setVersion(ctx context.Context, db sqlx.ExecerContext, version uint) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM "+migrationsTable); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (version, dirty) VALUES ($1, false)", int64(version)); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/otterly-id/otterly/backend/db/migrations"
+)
+
+// testMigrator has versions with gaps between them, like migrations numbered
+// by date.
+func testMigrator() *Migrator {
+	m := &Migrator{}
+	for _, version := range []uint{1, 2, 5, 8} {
+		m.migrations = append(m.migrations, migrations.Migration{
+			Version: version,
+			Name:    fmt.Sprintf("step_%d", version),
+			Up:      fmt.Sprintf("up %d", version),
+			Down:    fmt.Sprintf("down %d", version),
+		})
+	}
+
+	return m
+}
+
+func TestMigrateSteps(t *testing.T) {
+	tests := []struct {
+		current, target uint
+		want            []string
+	}{
+		{0, 8, []string{"up 1 -> 1", "up 2 -> 2", "up 5 -> 5", "up 8 -> 8"}},
+		{2, 5, []string{"up 5 -> 5"}},
+		{5, 5, nil},
+		{8, 2, []string{"down 8 -> 5", "down 5 -> 2"}},
+		{2, 0, []string{"down 2 -> 1", "down 1 -> 0"}},
+	}
+
+	m := testMigrator()
+	for _, tt := range tests {
+		var got []string
+		for _, step := range m.steps(tt.current, tt.target) {
+			got = append(got, fmt.Sprintf("%s -> %d", step.statements, step.version))
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("steps(%d, %d) = %q, want %q", tt.current, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestDownTarget(t *testing.T) {
+	tests := []struct {
+		current uint
+		steps   int
+		want    uint
+	}{
+		{8, 1, 5},
+		{8, 3, 1},
+		{8, 4, 0},
+		{5, 10, 0},
+		{0, 1, 0},
+	}
+
+	m := testMigrator()
+	for _, tt := range tests {
+		if got, err := m.downTarget(tt.current, tt.steps); err != nil || got != tt.want {
+			t.Errorf("downTarget(%d, %d) = %d, %v, want %d", tt.current, tt.steps, got, err, tt.want)
+		}
+	}
+
+	if _, err := m.downTarget(9, 1); err == nil {
+		t.Errorf("downTarget(9, 1) succeeded, want an error for a version of a newer release")
+	}
+}
+
+func TestUpTarget(t *testing.T) {
+	m := testMigrator()
+
+	var warnings []string
+	m.Warnf = func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	for _, current := range []uint{0, 2, 8} {
+		if got, err := m.upTarget(current); err != nil || got != 8 {
+			t.Errorf("upTarget(%d) = %d, %v, want 8", current, got, err)
+		}
+	}
+
+	if len(warnings) != 0 {
+		t.Fatalf("warnings = %q, want none", warnings)
+	}
+
+	// A newer release migrated the schema, there is nothing to apply.
+	if got, err := m.upTarget(9); err != nil || got != 9 {
+		t.Errorf("upTarget(9) = %d, %v, want 9", got, err)
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "version 9") {
+		t.Fatalf("warnings = %q, want one about version 9", warnings)
+	}
+
+	// A version between the migrations is not from a newer release.
+	if _, err := m.upTarget(3); err == nil {
+		t.Errorf("upTarget(3) succeeded, want an error for an unknown version")
+	}
+}
+
+func TestUnknownVersions(t *testing.T) {
+	ctx := context.Background()
+	m := testMigrator()
+
+	// Both are refused before connecting to the database.
+	if err := m.Goto(ctx, 3); err == nil || !strings.Contains(err.Error(), "no migration with version 3") {
+		t.Errorf("Goto(3) = %v, want an error about version 3", err)
+	}
+
+	if err := m.Force(ctx, 9); err == nil || !strings.Contains(err.Error(), "no migration with version 9") {
+		t.Errorf("Force(9) = %v, want an error about version 9", err)
+	}
+
+	if err := m.Down(ctx, 0); err == nil {
+		t.Errorf("Down(0) succeeded, want an error for the number of steps")
+	}
+}
+
+func TestAdvisoryLockID(t *testing.T) {
+	tests := []struct {
+		database string
+		names    []string
+		want     int64
+	}{
+		// Ids the migrate CLI derives, see GenerateAdvisoryLockId.
+		{"database_name", nil, 1764327054},
+		{"database_name", []string{"schema_name_1"}, 2453313553},
+		// The lock of the default schema of the otterly database.
+		{"otterly", []string{"public", migrationsTable}, 3337459195},
+	}
+
+	for _, tt := range tests {
+		if got := advisoryLockID(tt.database, tt.names...); got != tt.want {
+			t.Errorf("advisoryLockID(%q, %q) = %d, want %d", tt.database, tt.names, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+-- Create refresh tokens table
+CREATE TABLE refresh_tokens (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	family_id UUID NOT NULL,
+	token_hash BYTEA NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ DEFAULT NULL,
+	revoked_at TIMESTAMPTZ DEFAULT NULL,
+
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Speed up family revocation and per-user lookups
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
@@ -0,0 +1,18 @@
+-- Create personal access tokens table
+CREATE TABLE api_tokens (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name VARCHAR (100) NOT NULL,
+	token_prefix VARCHAR (16) NOT NULL,
+	token_hash BYTEA NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ DEFAULT NULL,
+	last_used_at TIMESTAMPTZ DEFAULT NULL,
+	revoked_at TIMESTAMPTZ DEFAULT NULL,
+
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Speed up listing the tokens of a user
+CREATE INDEX api_tokens_user_id_idx ON api_tokens (user_id);
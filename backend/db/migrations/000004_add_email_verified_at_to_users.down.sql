@@ -0,0 +1,2 @@
+-- Delete columns
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,2 @@
+-- Track when the user confirmed their email address
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ DEFAULT NULL;
@@ -0,0 +1,14 @@
+-- Create password reset tokens table
+CREATE TABLE password_reset_tokens (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	token_hash BYTEA NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ DEFAULT NULL,
+
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Speed up invalidating the outstanding tokens of a user
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);
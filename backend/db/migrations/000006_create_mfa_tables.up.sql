@@ -0,0 +1,23 @@
+-- Create TOTP enrollments table, the secret is encrypted by the application
+CREATE TABLE user_mfa (
+	user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+
+	secret_encrypted BYTEA NOT NULL,
+	confirmed_at TIMESTAMPTZ DEFAULT NULL,
+	last_used_step BIGINT NOT NULL DEFAULT 0,
+
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create recovery codes table
+CREATE TABLE mfa_recovery_codes (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash BYTEA NOT NULL,
+	used_at TIMESTAMPTZ DEFAULT NULL,
+
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+
+	UNIQUE (user_id, code_hash)
+);
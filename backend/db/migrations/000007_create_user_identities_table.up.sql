@@ -0,0 +1,15 @@
+-- Create external identities table, linking OpenID Connect subjects to users
+CREATE TABLE user_identities (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	provider VARCHAR (50) NOT NULL,
+	subject VARCHAR (255) NOT NULL,
+	email VARCHAR (254),
+
+	created_at TIMESTAMPTZ DEFAULT NOW(),
+	last_login_at TIMESTAMPTZ DEFAULT NULL,
+
+	UNIQUE (provider, subject),
+	UNIQUE (user_id, provider)
+);
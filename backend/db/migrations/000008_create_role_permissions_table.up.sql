@@ -0,0 +1,23 @@
+-- Create role permissions table, granting named permissions to roles
+CREATE TABLE role_permissions (
+	role user_role NOT NULL,
+	permission VARCHAR (100) NOT NULL,
+
+	PRIMARY KEY (role, permission)
+);
+
+-- Seed the default policy
+INSERT INTO role_permissions (role, permission) VALUES
+	('ADMIN', 'profile:read'),
+	('ADMIN', 'profile:write'),
+	('ADMIN', 'users:read'),
+	('ADMIN', 'users:write'),
+	('ADMIN', 'users:delete'),
+	('ADMIN', 'tokens:manage'),
+	('OWNER', 'profile:read'),
+	('OWNER', 'profile:write'),
+	('OWNER', 'users:read'),
+	('OWNER', 'users:write'),
+	('USER', 'profile:read'),
+	('USER', 'profile:write'),
+	('USER', 'users:read');
@@ -0,0 +1,4 @@
+-- Let admins read the email and phone number of other users
+INSERT INTO role_permissions (role, permission) VALUES
+	('ADMIN', 'users:read-private')
+ON CONFLICT DO NOTHING;
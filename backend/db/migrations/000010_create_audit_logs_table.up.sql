@@ -0,0 +1,16 @@
+-- Create audit logs table, recording privileged changes
+CREATE TABLE audit_logs (
+	id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+
+	actor_id UUID REFERENCES users (id) ON DELETE SET NULL,
+	action VARCHAR (100) NOT NULL,
+	target_id UUID,
+	details JSONB NOT NULL DEFAULT '{}',
+	ip_address VARCHAR (45),
+
+	created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Create indexes
+CREATE INDEX audit_logs_target_id_idx ON audit_logs (target_id);
+CREATE INDEX audit_logs_actor_id_idx ON audit_logs (actor_id);
@@ -0,0 +1,4 @@
+-- Let admins change the role of users
+INSERT INTO role_permissions (role, permission) VALUES
+	('ADMIN', 'users:manage-roles')
+ON CONFLICT DO NOTHING;
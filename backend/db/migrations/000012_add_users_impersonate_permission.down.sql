@@ -0,0 +1,2 @@
+-- Revoke the permission from every role
+DELETE FROM role_permissions WHERE permission = 'users:impersonate';
@@ -0,0 +1,4 @@
+-- Let admins act as other users
+INSERT INTO role_permissions (role, permission) VALUES
+	('ADMIN', 'users:impersonate')
+ON CONFLICT DO NOTHING;
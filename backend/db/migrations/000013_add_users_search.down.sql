@@ -0,0 +1,11 @@
+-- Delete indexes
+DROP INDEX IF EXISTS users_email_trgm_idx;
+DROP INDEX IF EXISTS users_full_name_trgm_idx;
+DROP INDEX IF EXISTS users_name_trgm_idx;
+DROP INDEX IF EXISTS users_search_vector_idx;
+
+-- Delete search document
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
+
+-- Disable trigram matching
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,15 @@
+-- Enable trigram matching
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Add search document, names rank above the email address
+ALTER TABLE users ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('simple'::regconfig, COALESCE(name, '')), 'A') ||
+	setweight(to_tsvector('simple'::regconfig, COALESCE(full_name, '')), 'B') ||
+	setweight(to_tsvector('simple'::regconfig, COALESCE(email, '')), 'C')
+) STORED;
+
+-- Create indexes
+CREATE INDEX users_search_vector_idx ON users USING GIN (search_vector);
+CREATE INDEX users_name_trgm_idx ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX users_full_name_trgm_idx ON users USING GIN (full_name gin_trgm_ops);
+CREATE INDEX users_email_trgm_idx ON users USING GIN (email gin_trgm_ops);
@@ -0,0 +1,8 @@
+-- Delete indexes
+DROP INDEX IF EXISTS users_deleted_at_idx;
+DROP INDEX IF EXISTS users_email_key;
+DROP INDEX IF EXISTS users_name_key;
+
+-- Restore unique constraints over every user
+ALTER TABLE users ADD CONSTRAINT users_name_key UNIQUE (name);
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,10 @@
+-- Only active users need a unique name and email address, so the owner of a
+-- deleted account can register again
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_name_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+
+CREATE UNIQUE INDEX users_name_key ON users (name) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
+
+-- Create index for listing and purging deleted users
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Delete trigger and function
+DROP TRIGGER IF EXISTS bump_version ON users;
+DROP FUNCTION IF EXISTS trigger_bump_version();
+
+-- Delete column
+ALTER TABLE users DROP COLUMN IF EXISTS version;
@@ -0,0 +1,17 @@
+-- Add a version, bumped on every change, for optimistic concurrency control
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- Create a reusable function to increment the 'version' column
+CREATE OR REPLACE FUNCTION trigger_bump_version()
+RETURNS TRIGGER AS $$
+BEGIN
+  NEW.version = OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Create a trigger to automatically call the function before any update on the users table
+CREATE TRIGGER bump_version
+BEFORE UPDATE ON users
+FOR EACH ROW
+EXECUTE FUNCTION trigger_bump_version();
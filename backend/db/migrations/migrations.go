@@ -0,0 +1,235 @@
+// Package migrations embeds the SQL migrations of the database, so the backend
+// binary can apply them without the migrate CLI or the source tree.
+//
+// Migrations are pairs of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, the layout `migrate create -seq` generates.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%06d_%s", m.Version, m.Name)
+}
+
+var filename = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the root of fsys, ordered by version. Every
+// version needs both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := filename.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// AllowDestructive is the comment that exempts an up migration from Lint, for
+// the rare change that removes data on purpose.
+const AllowDestructive = "-- lint:allow-destructive"
+
+var (
+	// destructive matches statements that throw away a table and its rows
+	// no matter what the rest of the statement says.
+	destructive = regexp.MustCompile(`(?is)^(DROP\s+(TABLE|SCHEMA|DATABASE|MATERIALIZED\s+VIEW|SEQUENCE)|TRUNCATE)\b`)
+	alterTable  = regexp.MustCompile(`(?i)^ALTER\s+TABLE\b`)
+	// alterDrop matches what ALTER TABLE drops, the COLUMN keyword is
+	// optional.
+	alterDrop  = regexp.MustCompile(`(?i)\bDROP\s+(?:IF\s+EXISTS\s+)?(\w+)`)
+	deleteFrom = regexp.MustCompile(`(?is)^DELETE\s+FROM\b`)
+	where      = regexp.MustCompile(`(?i)\bWHERE\b`)
+)
+
+// droppable are what ALTER TABLE may drop without losing rows or columns.
+var droppable = map[string]bool{
+	"CONSTRAINT": true,
+	"DEFAULT":    true,
+	"NOT":        true,
+	"IDENTITY":   true,
+	"EXPRESSION": true,
+}
+
+// Lint rejects up migrations that drop tables or columns, or delete every row
+// of a table. Applying them would lose data that no down migration brings
+// back. Down migrations are not checked, undoing a change is what they do.
+func Lint(migrations []Migration) error {
+	var errs []error
+	for _, migration := range migrations {
+		if strings.Contains(migration.Up, AllowDestructive) {
+			continue
+		}
+
+		for _, statement := range statements(migration.Up) {
+			if reason := destructiveReason(statement); reason != "" {
+				errs = append(errs, fmt.Errorf("%s.up.sql: %s: %q", migration, reason, firstLine(statement)))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func destructiveReason(statement string) string {
+	switch {
+	case destructive.MatchString(statement):
+		return "drops a table and its data"
+	case deleteFrom.MatchString(statement) && !where.MatchString(statement):
+		return "deletes every row of a table"
+	}
+
+	if !alterTable.MatchString(statement) {
+		return ""
+	}
+
+	for _, match := range alterDrop.FindAllStringSubmatch(statement, -1) {
+		if !droppable[strings.ToUpper(match[1])] {
+			return "drops a column and its data"
+		}
+	}
+
+	return ""
+}
+
+// statements splits sql into its statements, without comments or the
+// contents of string literals and dollar quoted function bodies, which could
+// hide a semicolon or look like a statement themselves.
+func statements(sql string) []string {
+	var (
+		result  []string
+		current strings.Builder
+	)
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			result = append(result, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end
+			current.WriteByte('\n')
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end + 3
+			current.WriteByte(' ')
+		case sql[i] == '\'':
+			end := strings.IndexByte(sql[i+1:], '\'')
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end + 1
+			current.WriteString("''")
+		case sql[i] == '$':
+			tag := dollarTag(sql[i:])
+			if tag == "" {
+				current.WriteByte(sql[i])
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += len(tag) + end + len(tag) - 1
+			current.WriteString("$$")
+		case sql[i] == ';':
+			flush()
+		default:
+			current.WriteByte(sql[i])
+		}
+	}
+	flush()
+
+	return result
+}
+
+var dollarQuote = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// dollarTag returns the opening $tag$ of a dollar quoted string at the start
+// of sql, if there is one.
+func dollarTag(sql string) string {
+	return dollarQuote.FindString(sql)
+}
+
+func firstLine(statement string) string {
+	line, _, _ := strings.Cut(statement, "\n")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,71 @@
+package migrations_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/otterly-id/otterly/backend/db/migrations"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := migrations.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for i, migration := range loaded {
+		if migration.Version != uint(i+1) {
+			t.Fatalf("migration %d is %s, versions must count up from 1", i, migration)
+		}
+	}
+
+	if err := migrations.Lint(loaded); err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+}
+
+func TestLoadRequiresBothDirections(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_create_otters.up.sql":   {Data: []byte("CREATE TABLE otters (id INT);")},
+		"000001_create_otters.down.sql": {Data: []byte("DROP TABLE otters;")},
+		"000002_add_name.up.sql":        {Data: []byte("ALTER TABLE otters ADD COLUMN name TEXT;")},
+	}
+
+	if _, err := migrations.Load(fsys); err == nil || !strings.Contains(err.Error(), "000002_add_name") {
+		t.Fatalf("Load = %v, want an error about the missing down file of 000002_add_name", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name        string
+		up          string
+		destructive bool
+	}{
+		{"create", "CREATE TABLE otters (id INT);", false},
+		{"drop constraint", "ALTER TABLE otters DROP CONSTRAINT IF EXISTS otters_name_key;", false},
+		{"drop default", "ALTER TABLE otters ALTER COLUMN name DROP DEFAULT, ALTER COLUMN id DROP NOT NULL;", false},
+		{"drop index", "DROP INDEX IF EXISTS otters_name_idx;", false},
+		{"delete where", "DELETE FROM role_permissions WHERE permission = 'users:read';", false},
+		{"in a string", "COMMENT ON TABLE otters IS 'DROP TABLE otters; fine';", false},
+		{"in a comment", "-- DROP TABLE otters;\nCREATE TABLE rafts (id INT);", false},
+		{"in a function body", "CREATE FUNCTION f() RETURNS void AS $$ BEGIN TRUNCATE otters; END; $$ LANGUAGE plpgsql;", false},
+		{"drop table", "DROP TABLE IF EXISTS otters;", true},
+		{"lower case", "create table rafts (id int);\ndrop table otters;", true},
+		{"truncate", "TRUNCATE otters;", true},
+		{"delete all", "DELETE FROM otters;", true},
+		{"drop column", "ALTER TABLE otters DROP COLUMN name;", true},
+		{"drop column without keyword", "ALTER TABLE otters ADD COLUMN age INT, DROP IF EXISTS name;", true},
+		{"allowed", migrations.AllowDestructive + "\nDROP TABLE otters;", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := migrations.Lint([]migrations.Migration{{Version: 1, Name: "change_otters", Up: tt.up, Down: "SELECT 1;"}})
+			if destructive := err != nil; destructive != tt.destructive {
+				t.Fatalf("Lint(%q) = %v, want destructive %v", tt.up, err, tt.destructive)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// UserRepository stores user accounts as managed through /api/users: listing,
+// search, bulk import and export, roles and the soft delete lifecycle.
+// Methods return sql.ErrNoRows when the user does not exist.
+type UserRepository interface {
+	CreateUser(ctx context.Context, u *models.CreateUserRequest) (models.CreateUserResponse, error)
+	CreateFirstAdmin(ctx context.Context, u *models.CreateAdminRequest) (models.CreateUserResponse, error)
+	ImportUsers(ctx context.Context, rows []models.ImportUserRow, dryRun bool, audit models.AuditLog) ([]models.CreateUserResponse, error)
+	ExportUsers(ctx context.Context, params models.ListParams, fn func(models.ExportUser) error) error
+	GetUsers(ctx context.Context, params models.ListParams) (models.Page[models.UserResponse], error)
+	SearchUsers(ctx context.Context, search string, params models.ListParams) (models.Page[models.UserSearchResult], error)
+	GetUser(ctx context.Context, id uuid.UUID) (models.UserResponse, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, u *models.UpdateUserRequest, version *int64) (models.UpdateUserResponse, error)
+	UpdateUserRole(ctx context.Context, id uuid.UUID, role models.UserRole, audit *models.AuditLog) (models.UserRoleResponse, error)
+	DeleteUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error
+	GetDeletedUsers(ctx context.Context, params models.ListParams) (models.Page[models.DeletedUserResponse], error)
+	GetDeletedUser(ctx context.Context, id uuid.UUID) (models.DeletedUserResponse, error)
+	RestoreUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) (models.UserResponse, error)
+	PurgeUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error
+	PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int, error)
+	CreateAuditLog(ctx context.Context, entry *models.AuditLog) error
+}
+
+// AuthRepository stores what signing in relies on: credentials, sessions, API
+// tokens, two factor authentication, external identities and the permissions
+// of each role.
+type AuthRepository interface {
+	Register(ctx context.Context, u *models.RegisterRequest) (models.RegisterResponse, error)
+	Login(ctx context.Context, email string) (models.LoginResponse, error)
+	GetAccountByEmail(ctx context.Context, email string) (models.AccountResponse, error)
+	VerifyEmail(ctx context.Context, id uuid.UUID, email string) (models.AccountResponse, error)
+	GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error
+
+	CreateRefreshToken(ctx context.Context, userID, familyID uuid.UUID, tokenHash []byte, expiresAt time.Time) error
+	GetRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, current models.RefreshToken, tokenHash []byte, expiresAt time.Time) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+
+	CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash []byte, expiresAt time.Time) error
+	ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string) (uuid.UUID, error)
+
+	CreateAPIToken(ctx context.Context, userID uuid.UUID, name, tokenPrefix string, tokenHash []byte, scopes models.Scopes, expiresAt *time.Time) (models.CreateAPITokenResponse, error)
+	GetAPITokens(ctx context.Context, userID uuid.UUID) ([]models.APITokenResponse, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash []byte) (models.APIToken, error)
+	TouchAPIToken(ctx context.Context, id uuid.UUID) error
+	RevokeAPIToken(ctx context.Context, id, userID uuid.UUID) error
+	RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) error
+
+	GetMFA(ctx context.Context, userID uuid.UUID) (models.UserMFA, error)
+	EnrollMFA(ctx context.Context, userID uuid.UUID, secretEncrypted []byte) (bool, error)
+	ConfirmMFA(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) (bool, error)
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodeHashes [][]byte) error
+	DisableMFA(ctx context.Context, userID uuid.UUID) error
+
+	LoginWithIdentity(ctx context.Context, provider, subject string) (models.IdentityUser, error)
+	GetIdentityUserByEmail(ctx context.Context, email string) (models.IdentityUser, error)
+	GetIdentityUser(ctx context.Context, id uuid.UUID) (models.IdentityUser, error)
+	CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) error
+	CreateUserWithIdentity(ctx context.Context, u *models.NewIdentityUser) (models.IdentityUser, error)
+
+	GetRolePermissions(ctx context.Context) (map[models.UserRole][]string, error)
+}
+
+// Repository is everything the API reads and writes. Queries implements it on
+// PostgreSQL, dbtest.Memory in memory for tests.
+type Repository interface {
+	UserRepository
+	AuthRepository
+
+	// WithTx and WithTxOptions run fn as one unit of work: the changes made
+	// through the Repository passed to fn are kept when it returns nil and
+	// undone when it returns an error or panics. fn may run more than once.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+	WithTxOptions(ctx context.Context, opts TxOptions, fn func(tx Repository) error) error
+}
+
+var _ Repository = (*Queries)(nil)
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/otterly-id/otterly/backend/internal/api/queries"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+)
+
+// defaultTxAttempts is how often a unit of work runs before a serialization
+// failure is returned to the caller.
+const defaultTxAttempts = 3
+
+// TxOptions configure a unit of work started with Repository.WithTxOptions.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction, the zero value
+	// is the database default, read committed.
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxAttempts bounds how often the unit of work runs when the database
+	// aborts it to keep transactions serializable, or to break a deadlock.
+	// Zero means defaultTxAttempts.
+	MaxAttempts int
+}
+
+// WithTx runs fn in a transaction with the default options. See
+// WithTxOptions.
+func (q *Queries) WithTx(ctx context.Context, fn func(tx Repository) error) error {
+	return q.WithTxOptions(ctx, TxOptions{}, fn)
+}
+
+// WithTxOptions runs fn as one unit of work: every query made through the
+// Repository passed to fn runs in the same transaction, which is committed when
+// fn returns nil and rolled back when it returns an error or panics. Queries
+// that start a transaction of their own use a savepoint of it instead.
+//
+// fn may run more than once, when the transaction fails to serialize or
+// deadlocks, so it must not have side effects outside the database. Called on
+// the Queries of a unit of work, fn runs in a savepoint and is never retried:
+// only the outermost unit of work can start over.
+func (q *Queries) WithTxOptions(ctx context.Context, opts TxOptions, fn func(tx Repository) error) error {
+	if q.tx != nil {
+		return q.runTx(ctx, q.tx, nil, fn)
+	}
+
+	txOptions := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+
+	return retryTx(ctx, opts.MaxAttempts, func() error {
+		return q.runTx(ctx, q.pool, txOptions, fn)
+	})
+}
+
+// retryTx calls run until it succeeds, fails with an error that is not
+// retryable or was called attempts times, zero meaning defaultTxAttempts.
+func retryTx(ctx context.Context, attempts int, run func() error) error {
+	if attempts <= 0 {
+		attempts = defaultTxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt == attempts || !isRetryable(err) {
+			return err
+		}
+
+		// Back off a little longer each time, so the transactions that
+		// conflicted do not collide again right away.
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (q *Queries) runTx(ctx context.Context, db queries.DB, opts *sql.TxOptions, fn func(tx Repository) error) error {
+	tx, err := queries.BeginTx(ctx, db, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Also runs when fn panics, the panic continues once rolled back.
+	defer tx.Rollback()
+
+	if err := fn(newQueries(q.pool, tx, q.timeouts)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// isRetryable reports whether err aborted a transaction that may succeed when
+// run again: a serialization failure or a deadlock.
+func isRetryable(err error) bool {
+	return errors.Is(dberrors.Translate(err), dberrors.ErrSerializationFailure)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRetryTx(t *testing.T) {
+	serialization := &pgconn.PgError{Code: "40001"}
+	deadlock := fmt.Errorf("failed to commit transaction: %w", &pgconn.PgError{Code: "40P01"})
+	unique := &pgconn.PgError{Code: "23505"}
+
+	tests := []struct {
+		name     string
+		attempts int
+		errs     []error
+		calls    int
+		err      error
+	}{
+		{"commits at once", 0, []error{nil}, 1, nil},
+		{"retries a serialization failure", 0, []error{serialization, nil}, 2, nil},
+		{"retries a deadlock", 0, []error{deadlock, serialization, nil}, 3, nil},
+		{"gives up after the default attempts", 0, []error{serialization, serialization, serialization, nil}, defaultTxAttempts, serialization},
+		{"gives up after max attempts", 2, []error{serialization, serialization, nil}, 2, serialization},
+		{"returns other errors", 0, []error{unique, nil}, 1, unique},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryTx(context.Background(), tt.attempts, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			if !errors.Is(err, tt.err) || calls != tt.calls {
+				t.Fatalf("retryTx = %v after %d calls, want %v after %d", err, calls, tt.err, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryTxStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	serialization := &pgconn.PgError{Code: "40001"}
+
+	calls := 0
+	err := retryTx(ctx, 5, func() error {
+		calls++
+		cancel()
+		return serialization
+	})
+
+	if !errors.Is(err, serialization) || calls != 1 {
+		t.Fatalf("retryTx = %v after %d calls, want the serialization failure after 1", err, calls)
+	}
+}
@@ -1,41 +1,80 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/otterly-id/otterly/backend/db"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/api/queries"
 	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
 	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+	"github.com/otterly-id/otterly/backend/internal/store"
 	"github.com/otterly-id/otterly/backend/internal/utils"
 	"go.uber.org/zap"
 )
 
+const (
+	accessTokenCookie  = "otterly_token"
+	refreshTokenCookie = "otterly_refresh_token"
+	refreshTokenPath   = "/api/auth"
+)
+
+type AuthSettings struct {
+	AppURL                    string
+	RequireEmailVerification  bool
+	EmailVerificationDuration time.Duration
+	PasswordResetDuration     time.Duration
+	MFATokenDuration          time.Duration
+	// ImpersonationDuration is the lifetime of impersonation tokens, which
+	// signing a user out everywhere has to outlast.
+	ImpersonationDuration time.Duration
+	LoginThrottle         LoginThrottleSettings
+}
+
 type AuthController struct {
 	Log             *zap.Logger
 	Validate        *validator.Validate
 	ResponseHandler *helpers.ResponseHandler
-	DB              *db.Queries
+	DB              db.Repository
 	JWTManager      *utils.JWTManager
+	Revocations     store.RevocationStore
+	Mailer          mailer.Mailer
+	Settings        AuthSettings
+	throttle        *loginThrottle
 }
 
-func NewAuthController(logger *zap.Logger, validator *validator.Validate, db *db.Queries, jwtManager *utils.JWTManager) *AuthController {
+func NewAuthController(logger *zap.Logger, validator *validator.Validate, db db.Repository, jwtManager *utils.JWTManager, revocations store.RevocationStore, attempts store.AttemptStore, mailer mailer.Mailer, settings AuthSettings) *AuthController {
 	return &AuthController{
 		Log:             logger,
 		Validate:        validator,
 		ResponseHandler: helpers.NewHandler(logger),
 		DB:              db,
 		JWTManager:      jwtManager,
+		Revocations:     revocations,
+		Mailer:          mailer,
+		Settings:        settings,
+		throttle: &loginThrottle{
+			attempts: attempts,
+			settings: settings.LoginThrottle,
+			log:      logger,
+		},
 	}
 }
 
 // Register func register new user.
 // @Summary      Register
-// @Description  Register new user.
+// @Description  Register new user and send an email verification link.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
@@ -43,6 +82,7 @@ func NewAuthController(logger *zap.Logger, validator *validator.Validate, db *db
 // @Success      200  {object}  models.SuccessResponse[models.RegisterResponse]
 // @Failure      400  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse{errors=models.FieldError}
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/auth/register [post]
 func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
@@ -65,25 +105,47 @@ func (ac *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 	}
 	newUser.Password = string(hashedPassword)
 
-	user, err := ac.DB.Register(newUser)
+	// The account and its audit entry are written together, neither exists
+	// without the other.
+	ipAddress := clientIP(r)
+	var user models.RegisterResponse
+	err = ac.DB.WithTx(r.Context(), func(tx db.Repository) error {
+		var err error
+		user, err = tx.Register(r.Context(), newUser)
+		if err != nil {
+			return err
+		}
+
+		return tx.CreateAuditLog(r.Context(), &models.AuditLog{
+			ActorID:   &user.ID,
+			Action:    models.AuditUserRegistered,
+			TargetID:  &user.ID,
+			IPAddress: &ipAddress,
+		})
+	})
 	if err != nil {
 		ac.ResponseHandler.CreateItemError(w, r, err, "user")
 		return
 	}
 
+	ac.queueVerificationEmail(r.Context(), user.ID, user.Name, user.Email)
+
 	ac.ResponseHandler.Success(w, r, http.StatusCreated, "User registered successfully", user)
 }
 
 // Login func login with credentials.
 // @Summary      Login
-// @Description  Login using email and password.
+// @Description  Login using email and password. The tokens are set as cookies and the access token is also returned for clients that authenticate with a bearer token, the refresh token only when asked for with X-Token-Delivery: body. Accounts with two-factor authentication get an mfa_token instead, to be sent to /api/auth/mfa/verify. Repeated failures slow down further attempts and lock the account or client address out for a while.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
+// @Param        X-Token-Delivery header string false "Set to body to also receive the refresh token in the response body"
 // @Param        request body   models.LoginRequest true "Login request"
-// @Success      200  {object}  models.SuccessResponse[models.RoleResponse]
+// @Success      200  {object}  models.SuccessResponse[models.TokenResponse]
 // @Failure      400  {object}  models.FailureResponse[string]
-// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      429  {object}  models.FailureResponse[string]
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/auth/login [post]
 func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
@@ -99,38 +161,303 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	foundUser, err := ac.DB.Login(user.Email)
+	keys := newLoginKeys(r, user.Email)
+
+	if lockedFor := ac.throttle.lockedFor(r.Context(), keys); lockedFor > 0 {
+		ac.ResponseHandler.TooManyAttemptsError(w, r, lockedFor)
+		return
+	}
+
+	ac.throttle.delay(r.Context(), keys)
+
+	// Unknown emails and wrong passwords are answered the same way and take
+	// about the same time, so the response does not reveal which accounts exist.
+	foundUser, err := ac.DB.Login(r.Context(), user.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ac.ResponseHandler.CredentialCheckError(w, r, err)
+		return
+	}
 	if err != nil {
-		ac.ResponseHandler.NotFoundError(w, r, err, "User")
+		utils.SimulatePasswordCompare(user.Password)
+		ac.throttle.registerFailure(r.Context(), keys)
+		ac.ResponseHandler.AuthenticationFailedError(w, r, err)
 		return
 	}
 
 	if ok := utils.ComparePassword(user.Password, foundUser.Password); !ok {
+		ac.throttle.registerFailure(r.Context(), keys)
 		ac.ResponseHandler.AuthenticationFailedError(w, r, fmt.Errorf("invalid credentials provided"))
 		return
 	}
 
-	token, duration, err := ac.JWTManager.GenerateToken(foundUser.ID.String(), foundUser.Email, foundUser.Role)
+	ac.throttle.registerSuccess(r.Context(), keys)
+
+	if ac.Settings.RequireEmailVerification && foundUser.EmailVerifiedAt == nil {
+		ac.ResponseHandler.EmailNotVerifiedError(w, r)
+		return
+	}
+
+	tokenResponse, err := beginSession(r.Context(), w, ac.DB, ac.JWTManager, ac.Settings.MFATokenDuration, foundUser.ID, foundUser.Email, foundUser.Role, wantsBodyTokens(r))
 	if err != nil {
 		ac.ResponseHandler.TokenGenerationError(w, r, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "otterly_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(duration),
+	if tokenResponse.MFARequired {
+		ac.ResponseHandler.Success(w, r, http.StatusOK, "Two-factor authentication required", tokenResponse)
+		return
+	}
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Login successful", tokenResponse)
+}
+
+// VerifyEmail func confirm email address.
+// @Summary      Verify Email
+// @Description  Confirm the email address of an account using the token sent by email.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body   models.VerifyEmailRequest true "Verify email request"
+// @Success      200  {object}  models.SuccessResponse[models.AccountResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/verify-email [post]
+func (ac *AuthController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	request := &models.VerifyEmailRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	claims, err := ac.JWTManager.ValidateActionToken(request.Token, utils.PurposeEmailVerification)
+	if err != nil {
+		ac.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		ac.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	account, err := ac.DB.VerifyEmail(r.Context(), userID, claims.Email)
+	if err != nil {
+		ac.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Email verified successfully", account)
+}
+
+// ResendVerification func resend email verification link.
+// @Summary      Resend Verification Email
+// @Description  Send a new verification link. The response is the same whether or not the email belongs to an unverified account.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body   models.ResendVerificationRequest true "Resend verification request"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Router       /api/auth/verify-email/resend [post]
+func (ac *AuthController) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	request := &models.ResendVerificationRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	account, err := ac.DB.GetAccountByEmail(r.Context(), request.Email)
+	if err == nil && account.EmailVerifiedAt == nil {
+		ac.queueVerificationEmail(r.Context(), account.ID, account.Name, account.Email)
+	}
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "If the account exists and is not verified yet, a verification email has been sent", nil)
+}
+
+// ForgotPassword func request a password reset link.
+// @Summary      Forgot Password
+// @Description  Send a single-use password reset link. The response is the same whether or not the email belongs to an account.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body   models.ForgotPasswordRequest true "Forgot password request"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Router       /api/auth/forgot-password [post]
+func (ac *AuthController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	request := &models.ForgotPasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	// The lookup and the email are sent in the background so the response
+	// time does not tell whether the account exists.
+	inBackground(r.Context(), func(ctx context.Context) {
+		account, err := ac.DB.GetAccountByEmail(ctx, request.Email)
+		if err != nil {
+			return
+		}
+
+		if err := ac.sendPasswordResetEmail(ctx, account.ID, account.Name, account.Email); err != nil {
+			ac.Log.Error("Failed to send password reset email",
+				zap.String("user_id", account.ID.String()),
+				zap.Error(err))
+		}
 	})
 
-	roleResponse := models.RoleResponse{
-		Role: foundUser.Role,
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "If the account exists, a password reset email has been sent", nil)
+}
+
+// ResetPassword func set a new password using a reset token.
+// @Summary      Reset Password
+// @Description  Set a new password using the token sent by email. The token can only be used once and every session of the account is signed out.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body   models.ResetPasswordRequest true "Reset password request"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/reset-password [post]
+func (ac *AuthController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	request := &models.ResetPasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(request.Password)
+	if err != nil {
+		ac.ResponseHandler.HashPasswordError(w, r, err)
+		return
+	}
+
+	userID, err := ac.DB.ResetPassword(r.Context(), utils.HashToken(request.Token), hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ac.ResponseHandler.InvalidActionTokenError(w, r, err)
+			return
+		}
+		ac.ResponseHandler.UpdateItemError(w, r, err, "password")
+		return
+	}
+
+	if err := revokeUserSessions(r.Context(), ac.DB, ac.Revocations, accessTokenLifetime(ac.JWTManager, ac.Settings.ImpersonationDuration), userID); err != nil {
+		ac.ResponseHandler.SessionRevocationError(w, r, err)
+		return
 	}
 
-	ac.ResponseHandler.Success(w, r, http.StatusOK, "Login successful", roleResponse)
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Password reset successfully", nil)
+}
+
+// Refresh func rotate refresh token.
+// @Summary      Refresh
+// @Description  Exchange a refresh token for a new access token. The refresh token is rotated on every use and replaying a used token revokes the whole session. New tokens are only returned in the body when the refresh token was sent in the body.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body   models.RefreshTokenRequest false "Refresh request, optional when the refresh cookie is sent"
+// @Success      200  {object}  models.SuccessResponse[models.TokenResponse]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/refresh [post]
+func (ac *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	presentedToken, fromCookie, err := ac.getRefreshToken(r)
+	if err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if presentedToken == "" {
+		ac.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	storedToken, err := ac.DB.GetRefreshToken(r.Context(), utils.HashToken(presentedToken))
+	if err != nil {
+		ac.ResponseHandler.InvalidRefreshTokenError(w, r, err)
+		return
+	}
+
+	if storedToken.UsedAt != nil || storedToken.RevokedAt != nil {
+		ac.revokeReusedRefreshToken(w, r, storedToken)
+		return
+	}
+
+	if storedToken.ExpiresAt.Before(time.Now()) {
+		ac.ResponseHandler.InvalidRefreshTokenError(w, r, fmt.Errorf("refresh token expired"))
+		return
+	}
+
+	user, err := ac.DB.GetUser(r.Context(), storedToken.UserID)
+	if err != nil {
+		ac.ResponseHandler.InvalidRefreshTokenError(w, r, err)
+		return
+	}
+
+	refreshToken, refreshHash, refreshExpiresAt, err := ac.JWTManager.GenerateRefreshToken()
+	if err != nil {
+		ac.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	rotated, err := ac.DB.RotateRefreshToken(r.Context(), storedToken, refreshHash, refreshExpiresAt)
+	if err != nil {
+		ac.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	if !rotated {
+		ac.revokeReusedRefreshToken(w, r, storedToken)
+		return
+	}
+
+	token, duration, err := ac.JWTManager.GenerateToken(user.ID.String(), user.Email, user.Role)
+	if err != nil {
+		ac.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	setSessionCookies(w, token, duration, refreshToken, refreshExpiresAt)
+
+	tokenResponse := models.TokenResponse{
+		Role: user.Role,
+	}
+
+	if !fromCookie {
+		tokenResponse.AccessToken = token
+		tokenResponse.TokenType = "Bearer"
+		tokenResponse.ExpiresIn = int(duration.Seconds())
+		tokenResponse.RefreshToken = refreshToken
+	}
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Token refreshed successfully", tokenResponse)
 }
 
 // GetAuthenticatedUser func get current authenticated user.
@@ -140,7 +467,11 @@ func (ac *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        If-None-Match header string false "ETag of a copy the caller already has"
 // @Success      200  {object}  models.SuccessResponse[models.UserResponse]
+// @Header       200  {string}  ETag "Version of the user, for If-Match"
+// @Success      304  "The user has not changed"
 // @Failure      400  {object}  models.FailureResponse[string]
 // @Failure      401  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
@@ -153,42 +484,412 @@ func (ac *AuthController) GetAuthenticatedUser(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	user, err := ac.DB.GetUser(userInfo.ID)
+	user, err := ac.DB.GetUser(r.Context(), userInfo.ID)
 	if err != nil {
 		ac.ResponseHandler.NotFoundError(w, r, err, "User")
 		return
 	}
 
+	if ac.ResponseHandler.NotModified(w, r, user.Version) {
+		return
+	}
+
+	helpers.SetETag(w, user.Version)
 	ac.ResponseHandler.Success(w, r, http.StatusOK, "User found", user)
 }
 
+// UpdateProfile func update current authenticated user.
+// @Summary      Update Profile
+// @Description  Edit the profile of the current authenticated user. Changing the email address marks it as unverified and sends a new verification link, it is not allowed while impersonating. Send the ETag of GET /api/auth/me as If-Match to only apply the change if the profile was not changed since, otherwise 412 is returned.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        If-Match header string false "ETag the change is based on"
+// @Param        request body   models.UpdateUserRequest true "Update profile request"
+// @Success      200  {object}  models.SuccessResponse[models.UpdateUserResponse]
+// @Header       200  {string}  ETag "New version of the user"
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse{errors=models.FieldError}
+// @Failure      412  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/me [patch]
+func (ac *AuthController) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		ac.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	version, err := helpers.IfMatch(r)
+	if err != nil {
+		ac.ResponseHandler.InvalidPreconditionError(w, r, err)
+		return
+	}
+
+	request := &models.UpdateUserRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	// The email address signs in and resets the password, so it is a
+	// credential like the password itself.
+	if request.Email != nil && userInfo.IsImpersonated() {
+		ac.ResponseHandler.ImpersonationForbiddenError(w, r)
+		return
+	}
+
+	currentUser, err := ac.DB.GetUser(r.Context(), userInfo.ID)
+	if err != nil {
+		ac.ResponseHandler.NotFoundError(w, r, err, "User")
+		return
+	}
+
+	user, err := ac.DB.UpdateUser(r.Context(), userInfo.ID, request, version)
+	if err != nil {
+		if errors.Is(err, queries.ErrVersionMismatch) {
+			ac.ResponseHandler.PreconditionFailedError(w, r, "User")
+			return
+		}
+		ac.ResponseHandler.UpdateItemError(w, r, err, "User")
+		return
+	}
+
+	if user.Email != currentUser.Email {
+		ac.queueVerificationEmail(r.Context(), user.ID, user.Name, user.Email)
+	}
+
+	helpers.SetETag(w, user.Version)
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Profile updated successfully", user)
+}
+
+// ChangePassword func change password of current authenticated user.
+// @Summary      Change Password
+// @Description  Change the password of the current authenticated user. Every other session is signed out and the current one receives new tokens.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        X-Token-Delivery header string false "Set to body to also receive the refresh token in the response body"
+// @Param        request body   models.ChangePasswordRequest true "Change password request"
+// @Success      200  {object}  models.SuccessResponse[models.TokenResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/me/password [post]
+func (ac *AuthController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		ac.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	request := &models.ChangePasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	user, err := ac.DB.GetUser(r.Context(), userInfo.ID)
+	if err != nil {
+		ac.ResponseHandler.NotFoundError(w, r, err, "User")
+		return
+	}
+
+	if err := ac.verifyPassword(r.Context(), userInfo.ID, request.CurrentPassword); err != nil {
+		ac.ResponseHandler.AuthenticationFailedError(w, r, err)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		ac.ResponseHandler.HashPasswordError(w, r, err)
+		return
+	}
+
+	if err := ac.DB.UpdatePassword(r.Context(), userInfo.ID, hashedPassword); err != nil {
+		ac.ResponseHandler.UpdateItemError(w, r, err, "password")
+		return
+	}
+
+	if err := revokeUserSessions(r.Context(), ac.DB, ac.Revocations, accessTokenLifetime(ac.JWTManager, ac.Settings.ImpersonationDuration), userInfo.ID); err != nil {
+		ac.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	tokenResponse, err := startSession(r.Context(), w, ac.DB, ac.JWTManager, user.ID, user.Email, user.Role, wantsBodyTokens(r))
+	if err != nil {
+		ac.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Password changed successfully", tokenResponse)
+}
+
+// DeleteAccount func delete current authenticated user.
+// @Summary      Delete Account
+// @Description  Delete the account of the current authenticated user after confirming the password. Every session is signed out and every personal access token revoked. The last admin cannot delete their account.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        request body   models.DeleteAccountRequest true "Delete account request"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/me [delete]
+func (ac *AuthController) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		ac.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	request := &models.DeleteAccountRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		ac.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := ac.Validate.Struct(request); err != nil {
+		ac.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	if err := ac.verifyPassword(r.Context(), userInfo.ID, request.Password); err != nil {
+		ac.ResponseHandler.AuthenticationFailedError(w, r, err)
+		return
+	}
+
+	ipAddress := clientIP(r)
+	if err := ac.DB.DeleteUser(r.Context(), userInfo.ID, &models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserDeleted,
+		IPAddress: &ipAddress,
+	}); err != nil {
+		if errors.Is(err, queries.ErrLastAdmin) {
+			ac.ResponseHandler.LastAdminError(w, r)
+			return
+		}
+		ac.ResponseHandler.DeleteItemError(w, r, err, "User")
+		return
+	}
+
+	if err := revokeUserAccess(r.Context(), ac.DB, ac.Revocations, accessTokenLifetime(ac.JWTManager, ac.Settings.ImpersonationDuration), userInfo.ID); err != nil {
+		ac.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	clearSessionCookies(w)
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Account deleted successfully", nil)
+}
+
 // Logout func logs out the current user.
 // @Summary      Logout
-// @Description  Logout the current authenticated user by removing the JWT cookie.
+// @Description  Logout the current authenticated user by revoking the access and refresh tokens and removing the session cookies.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
 // @Success      200  {object}  models.SuccessResponseWithoutData
 // @Failure      401  {object}  models.FailureResponse[string]
 // @Router       /api/auth/logout [post]
 func (ac *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
-	_, ok := middlewares.GetUserFromContext(r.Context())
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
 	if !ok {
 		ac.ResponseHandler.AuthenticationRequiredError(w, r)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "otterly_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Unix(0, 0),
-		MaxAge:   -1,
-	})
+	if err := ac.Revocations.RevokeToken(r.Context(), userInfo.TokenID, userInfo.ExpiresAt); err != nil {
+		ac.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	// Impersonation tokens come without cookies, the ones sent belong to the
+	// admin's own session.
+	if userInfo.IsImpersonated() {
+		ac.ResponseHandler.Success(w, r, http.StatusOK, "Logout successful", nil)
+		return
+	}
+
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil && cookie.Value != "" {
+		if storedToken, err := ac.DB.GetRefreshToken(r.Context(), utils.HashToken(cookie.Value)); err == nil {
+			if err := ac.DB.RevokeRefreshTokenFamily(r.Context(), storedToken.FamilyID); err != nil {
+				ac.Log.Error("Failed to revoke refresh token family",
+					zap.String("family_id", storedToken.FamilyID.String()),
+					zap.Error(err))
+			}
+		}
+	}
+
+	clearSessionCookies(w)
 
 	ac.ResponseHandler.Success(w, r, http.StatusOK, "Logout successful", nil)
 }
+
+// JWKS func list public signing keys.
+// @Summary      JSON Web Key Set
+// @Description  Public keys used to verify Otterly access tokens, selected by the kid token header.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  models.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (ac *AuthController) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := json.NewEncoder(w).Encode(ac.JWTManager.JWKS()); err != nil {
+		ac.Log.Error("Failed to encode JWKS", zap.Error(err))
+	}
+}
+
+// LogoutAll func logs out the current user on every device.
+// @Summary      Logout All Devices
+// @Description  Revoke every access token, refresh token and personal access token issued to the current authenticated user.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/logout-all [post]
+func (ac *AuthController) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		ac.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if err := revokeUserAccess(r.Context(), ac.DB, ac.Revocations, accessTokenLifetime(ac.JWTManager, ac.Settings.ImpersonationDuration), userInfo.ID); err != nil {
+		ac.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	clearSessionCookies(w)
+
+	ac.ResponseHandler.Success(w, r, http.StatusOK, "Logged out from all devices", nil)
+}
+
+func (ac *AuthController) verifyPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	passwordHash, err := ac.DB.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if ok := utils.ComparePassword(password, passwordHash); !ok {
+		return fmt.Errorf("invalid credentials provided")
+	}
+
+	return nil
+}
+
+// queueVerificationEmail sends the user a verification link in the background,
+// so a slow mail server does not hold up the response.
+func (ac *AuthController) queueVerificationEmail(ctx context.Context, userID uuid.UUID, name, email string) {
+	inBackground(ctx, func(ctx context.Context) {
+		if err := ac.sendVerificationEmail(ctx, userID, name, email); err != nil {
+			ac.Log.Error("Failed to send verification email",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+		}
+	})
+}
+
+func (ac *AuthController) sendVerificationEmail(ctx context.Context, userID uuid.UUID, name, email string) error {
+	link, err := verificationLink(ac.JWTManager, ac.Settings.AppURL, userID, email, ac.Settings.EmailVerificationDuration)
+	if err != nil {
+		return err
+	}
+
+	return ac.Mailer.Send(ctx, mailer.VerificationMessage(email, name, link))
+}
+
+// verificationLink returns the link to verify email as the address of the
+// user, valid for duration.
+func verificationLink(jwtManager *utils.JWTManager, appURL string, userID uuid.UUID, email string, duration time.Duration) (string, error) {
+	token, err := jwtManager.GenerateActionToken(userID, email, utils.PurposeEmailVerification, duration)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/verify-email?token=%s", appURL, url.QueryEscape(token)), nil
+}
+
+func (ac *AuthController) sendPasswordResetEmail(ctx context.Context, userID uuid.UUID, name, email string) error {
+	link, err := passwordResetLink(ctx, ac.DB, ac.Settings.AppURL, userID, ac.Settings.PasswordResetDuration)
+	if err != nil {
+		return err
+	}
+
+	return ac.Mailer.Send(ctx, mailer.PasswordResetMessage(email, name, link))
+}
+
+// passwordResetLink stores a new password reset token of the user, valid for
+// duration, and returns the link to choose a password with it.
+func passwordResetLink(ctx context.Context, db db.AuthRepository, appURL string, userID uuid.UUID, duration time.Duration) (string, error) {
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := db.CreatePasswordResetToken(ctx, userID, utils.HashToken(token), time.Now().Add(duration)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/reset-password?token=%s", appURL, url.QueryEscape(token)), nil
+}
+
+func (ac *AuthController) getRefreshToken(r *http.Request) (string, bool, error) {
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, true, nil
+	}
+
+	request := &models.RefreshTokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil && !errors.Is(err, io.EOF) {
+		return "", false, err
+	}
+
+	return request.RefreshToken, false, nil
+}
+
+func (ac *AuthController) revokeReusedRefreshToken(w http.ResponseWriter, r *http.Request, token models.RefreshToken) {
+	ac.Log.Warn("Refresh token reuse detected, revoking token family",
+		zap.String("user_id", token.UserID.String()),
+		zap.String("family_id", token.FamilyID.String()))
+
+	if err := ac.DB.RevokeRefreshTokenFamily(r.Context(), token.FamilyID); err != nil {
+		ac.Log.Error("Failed to revoke refresh token family",
+			zap.String("family_id", token.FamilyID.String()),
+			zap.Error(err))
+	}
+
+	clearSessionCookies(w)
+	ac.ResponseHandler.InvalidRefreshTokenError(w, r, fmt.Errorf("refresh token reuse detected"))
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/store"
+	"go.uber.org/zap"
+)
+
+// LoginThrottleSettings controls how failed logins are slowed down and when
+// an account or a client address gets locked out temporarily.
+type LoginThrottleSettings struct {
+	MaxAccountFailures int
+	MaxIPFailures      int
+	FailureWindow      time.Duration
+	LockoutDuration    time.Duration
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+}
+
+type loginThrottle struct {
+	attempts store.AttemptStore
+	settings LoginThrottleSettings
+	log      *zap.Logger
+}
+
+type loginKeys struct {
+	account string
+	ip      string
+}
+
+func newLoginKeys(r *http.Request, email string) loginKeys {
+	return loginKeys{
+		account: "login:account:" + strings.ToLower(strings.TrimSpace(email)),
+		ip:      "login:ip:" + clientIP(r),
+	}
+}
+
+// newMFAKeys counts second factor failures apart from password failures, so
+// guessing codes is limited even for a caller that knows the password.
+func newMFAKeys(r *http.Request, userID uuid.UUID) loginKeys {
+	return loginKeys{
+		account: "mfa:account:" + userID.String(),
+		ip:      "mfa:ip:" + clientIP(r),
+	}
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// lockedFor reports how long the account or the client address stays locked.
+// Store errors are logged and treated as not locked, so an unavailable store
+// does not lock every user out.
+func (t *loginThrottle) lockedFor(ctx context.Context, keys loginKeys) time.Duration {
+	var lockedFor time.Duration
+
+	for _, key := range []string{keys.account, keys.ip} {
+		duration, err := t.attempts.LockedFor(ctx, key)
+		if err != nil {
+			t.log.Error("Failed to check login lockout", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		lockedFor = max(lockedFor, duration)
+	}
+
+	return lockedFor
+}
+
+// delay waits longer with every recent failure, doubling from BaseDelay up to
+// MaxDelay.
+func (t *loginThrottle) delay(ctx context.Context, keys loginKeys) {
+	failures := 0
+
+	for _, key := range []string{keys.account, keys.ip} {
+		count, err := t.attempts.Failures(ctx, key)
+		if err != nil {
+			t.log.Error("Failed to read failed logins", zap.String("key", key), zap.Error(err))
+			continue
+		}
+		failures = max(failures, count)
+	}
+
+	if failures == 0 {
+		return
+	}
+
+	delay := t.settings.MaxDelay
+	if failures < 32 {
+		delay = min(t.settings.BaseDelay<<(failures-1), t.settings.MaxDelay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func (t *loginThrottle) registerFailure(ctx context.Context, keys loginKeys) {
+	t.registerKeyFailure(ctx, keys.account, t.settings.MaxAccountFailures)
+	t.registerKeyFailure(ctx, keys.ip, t.settings.MaxIPFailures)
+}
+
+func (t *loginThrottle) registerKeyFailure(ctx context.Context, key string, limit int) {
+	count, err := t.attempts.RegisterFailure(ctx, key, t.settings.FailureWindow)
+	if err != nil {
+		t.log.Error("Failed to register failed login", zap.String("key", key), zap.Error(err))
+		return
+	}
+
+	if limit <= 0 || count < limit {
+		return
+	}
+
+	if err := t.attempts.Lock(ctx, key, t.settings.LockoutDuration); err != nil {
+		t.log.Error("Failed to lock out login", zap.String("key", key), zap.Error(err))
+		return
+	}
+
+	t.log.Warn("Login locked out after repeated failures",
+		zap.String("key", key),
+		zap.Int("failures", count))
+}
+
+// registerSuccess forgets the failures of the account. Failures of the client
+// address are kept so one valid account cannot be used to reset the counter
+// while guessing others.
+func (t *loginThrottle) registerSuccess(ctx context.Context, keys loginKeys) {
+	if err := t.attempts.Reset(ctx, keys.account); err != nil {
+		t.log.Error("Failed to reset failed logins", zap.String("key", keys.account), zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+)
+
+// mailTimeout bounds how long the emails sent in the background of a request
+// may take, together.
+const mailTimeout = 30 * time.Second
+
+// inBackground runs fn once the request is answered, with a context that
+// keeps the values of ctx but not its cancellation and ends after mailTimeout.
+func inBackground(ctx context.Context, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), mailTimeout)
+
+	go func() {
+		defer cancel()
+		fn(ctx)
+	}()
+}
+
+// sendWithin sends message, giving up after mailTimeout.
+func sendWithin(ctx context.Context, m mailer.Mailer, message mailer.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	return m.Send(ctx, message)
+}
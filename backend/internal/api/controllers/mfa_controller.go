@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
+	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/store"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+const recoveryCodeCount = 10
+
+type MFASettings struct {
+	Issuer        string
+	RequiredRoles []models.UserRole
+	Throttle      LoginThrottleSettings
+}
+
+type MFAController struct {
+	Log             *zap.Logger
+	Validate        *validator.Validate
+	ResponseHandler *helpers.ResponseHandler
+	DB              db.Repository
+	JWTManager      *utils.JWTManager
+	Secrets         *utils.SecretBox
+	Settings        MFASettings
+	throttle        *loginThrottle
+}
+
+func NewMFAController(logger *zap.Logger, validator *validator.Validate, db db.Repository, jwtManager *utils.JWTManager, attempts store.AttemptStore, secrets *utils.SecretBox, settings MFASettings) *MFAController {
+	return &MFAController{
+		Log:             logger,
+		Validate:        validator,
+		ResponseHandler: helpers.NewHandler(logger),
+		DB:              db,
+		JWTManager:      jwtManager,
+		Secrets:         secrets,
+		Settings:        settings,
+		throttle: &loginThrottle{
+			attempts: attempts,
+			settings: settings.Throttle,
+			log:      logger,
+		},
+	}
+}
+
+// GetStatus func get two-factor authentication status.
+// @Summary      Two-Factor Status
+// @Description  Show whether two-factor authentication is enabled for the current user and whether their role requires it.
+// @Tags         MFA
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Success      200  {object}  models.SuccessResponse[models.MFAStatusResponse]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa [get]
+func (mc *MFAController) GetStatus(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		mc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	status := models.MFAStatusResponse{
+		Required: slices.Contains(mc.Settings.RequiredRoles, userInfo.Role),
+	}
+
+	mfa, err := mc.DB.GetMFA(r.Context(), userInfo.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	if err == nil && mfa.ConfirmedAt != nil {
+		remaining, err := mc.DB.CountRecoveryCodes(r.Context(), userInfo.ID)
+		if err != nil {
+			mc.ResponseHandler.MFAError(w, r, err)
+			return
+		}
+
+		status.Enabled = true
+		status.ConfirmedAt = mfa.ConfirmedAt
+		status.RecoveryCodesRemaining = remaining
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Two-factor status found", status)
+}
+
+// Enroll func start two-factor enrollment.
+// @Summary      Enroll Two-Factor
+// @Description  Generate a TOTP secret and the otpauth:// provisioning URI to show as a QR code. The enrollment becomes active once a code is confirmed.
+// @Tags         MFA
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Success      200  {object}  models.SuccessResponse[models.MFAEnrollResponse]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa/enroll [post]
+func (mc *MFAController) Enroll(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		mc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	user, err := mc.DB.GetUser(r.Context(), userInfo.ID)
+	if err != nil {
+		mc.ResponseHandler.NotFoundError(w, r, err, "User")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	encryptedSecret, err := mc.Secrets.Seal([]byte(secret))
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	enrolled, err := mc.DB.EnrollMFA(r.Context(), userInfo.ID, encryptedSecret)
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	if !enrolled {
+		mc.ResponseHandler.MFAStateError(w, r, "Two-factor authentication is already enabled")
+		return
+	}
+
+	response := models.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(secret, mc.Settings.Issuer, user.Email),
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Scan the code with your authenticator app and confirm it", response)
+}
+
+// Confirm func confirm two-factor enrollment.
+// @Summary      Confirm Two-Factor
+// @Description  Activate two-factor authentication with a code from the authenticator app. The recovery codes are only shown once.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        request body   models.MFACodeRequest true "Confirm request"
+// @Success      200  {object}  models.SuccessResponse[models.MFARecoveryCodesResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa/confirm [post]
+func (mc *MFAController) Confirm(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		mc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	request := &models.MFACodeRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		mc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := mc.Validate.Struct(request); err != nil {
+		mc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	mfa, err := mc.DB.GetMFA(r.Context(), userInfo.ID)
+	if err != nil || mfa.ConfirmedAt != nil {
+		mc.ResponseHandler.MFAStateError(w, r, "There is no pending two-factor enrollment")
+		return
+	}
+
+	secret, err := mc.Secrets.Open(mfa.SecretEncrypted)
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(string(secret), request.Code, time.Now())
+	if !ok {
+		mc.ResponseHandler.InvalidMFACodeError(w, r, fmt.Errorf("invalid enrollment code"))
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	confirmed, err := mc.DB.ConfirmMFA(r.Context(), userInfo.ID, step, hashes)
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	if !confirmed {
+		mc.ResponseHandler.MFAStateError(w, r, "There is no pending two-factor enrollment")
+		return
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Two-factor authentication enabled", models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes func replace two-factor recovery codes.
+// @Summary      Regenerate Recovery Codes
+// @Description  Replace every recovery code with a new set after confirming a code from the authenticator app.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        request body   models.MFACodeRequest true "Regenerate request"
+// @Success      200  {object}  models.SuccessResponse[models.MFARecoveryCodesResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      429  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa/recovery-codes [post]
+func (mc *MFAController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		mc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	request := &models.MFACodeRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		mc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := mc.Validate.Struct(request); err != nil {
+		mc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	if !mc.checkSecondFactor(w, r, userInfo.ID, request.Code) {
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	if err := mc.DB.ReplaceRecoveryCodes(r.Context(), userInfo.ID, hashes); err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Recovery codes regenerated", models.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable func turn off two-factor authentication.
+// @Summary      Disable Two-Factor
+// @Description  Turn off two-factor authentication after confirming the password and a code. Not allowed for roles that require it.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        request body   models.MFADisableRequest true "Disable request"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      429  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa [delete]
+func (mc *MFAController) Disable(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		mc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if slices.Contains(mc.Settings.RequiredRoles, userInfo.Role) {
+		mc.ResponseHandler.MFARequiredError(w, r)
+		return
+	}
+
+	request := &models.MFADisableRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		mc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := mc.Validate.Struct(request); err != nil {
+		mc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	passwordHash, err := mc.DB.GetPasswordHash(r.Context(), userInfo.ID)
+	if err != nil || !utils.ComparePassword(request.Password, passwordHash) {
+		mc.ResponseHandler.AuthenticationFailedError(w, r, fmt.Errorf("invalid credentials provided"))
+		return
+	}
+
+	if !mc.checkSecondFactor(w, r, userInfo.ID, request.Code) {
+		return
+	}
+
+	if err := mc.DB.DisableMFA(r.Context(), userInfo.ID); err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// Verify func finish login with a second factor.
+// @Summary      Verify Two-Factor
+// @Description  Finish a login that returned mfa_required by sending the MFA token with a code from the authenticator app or an unused recovery code.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Param        X-Token-Delivery header string false "Set to body to also receive the refresh token in the response body"
+// @Param        request body   models.MFAVerifyRequest true "Verify request"
+// @Success      200  {object}  models.SuccessResponse[models.TokenResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      429  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/auth/mfa/verify [post]
+func (mc *MFAController) Verify(w http.ResponseWriter, r *http.Request) {
+	request := &models.MFAVerifyRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		mc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := mc.Validate.Struct(request); err != nil {
+		mc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	claims, err := mc.JWTManager.ValidateActionToken(request.MFAToken, utils.PurposeMFALogin)
+	if err != nil {
+		mc.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		mc.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	user, err := mc.DB.GetUser(r.Context(), userID)
+	if err != nil {
+		mc.ResponseHandler.InvalidActionTokenError(w, r, err)
+		return
+	}
+
+	if !mc.checkSecondFactor(w, r, userID, request.Code) {
+		return
+	}
+
+	tokenResponse, err := startSession(r.Context(), w, mc.DB, mc.JWTManager, user.ID, user.Email, user.Role, wantsBodyTokens(r))
+	if err != nil {
+		mc.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	mc.ResponseHandler.Success(w, r, http.StatusOK, "Login successful", tokenResponse)
+}
+
+// checkSecondFactor writes the error response and returns false unless code is
+// a valid second factor for an enabled enrollment. Wrong codes count towards
+// the same lockout wherever a code is asked for.
+func (mc *MFAController) checkSecondFactor(w http.ResponseWriter, r *http.Request, userID uuid.UUID, code string) bool {
+	keys := newMFAKeys(r, userID)
+
+	if lockedFor := mc.throttle.lockedFor(r.Context(), keys); lockedFor > 0 {
+		mc.ResponseHandler.TooManyAttemptsError(w, r, lockedFor)
+		return false
+	}
+
+	mc.throttle.delay(r.Context(), keys)
+
+	valid, err := verifySecondFactor(r.Context(), mc.DB, mc.Secrets, userID, code)
+	if errors.Is(err, sql.ErrNoRows) {
+		mc.ResponseHandler.MFAStateError(w, r, "Two-factor authentication is not enabled")
+		return false
+	}
+
+	if err != nil {
+		mc.ResponseHandler.MFAError(w, r, err)
+		return false
+	}
+
+	if !valid {
+		mc.throttle.registerFailure(r.Context(), keys)
+		mc.ResponseHandler.InvalidMFACodeError(w, r, fmt.Errorf("invalid second factor"))
+		return false
+	}
+
+	mc.throttle.registerSuccess(r.Context(), keys)
+
+	return true
+}
+
+// verifySecondFactor accepts a current TOTP code that was not used before or
+// an unused recovery code. It returns sql.ErrNoRows when the user has no
+// enabled enrollment.
+func verifySecondFactor(ctx context.Context, db db.AuthRepository, secrets *utils.SecretBox, userID uuid.UUID, code string) (bool, error) {
+	mfa, err := db.GetMFA(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if mfa.ConfirmedAt == nil {
+		return false, sql.ErrNoRows
+	}
+
+	if len(code) != 6 {
+		return db.UseRecoveryCode(ctx, userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	secret, err := secrets.Open(mfa.SecretEncrypted)
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := utils.ValidateTOTP(string(secret), code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return db.UseTOTPStep(ctx, userID, step)
+}
+
+func generateRecoveryCodes() ([]string, [][]byte, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([][]byte, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+
+	return codes, hashes, nil
+}
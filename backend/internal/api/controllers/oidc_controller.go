@@ -0,0 +1,468 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
+	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/oidc"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcStateCookie = "otterly_oidc_state"
+	oidcStatePath   = "/api/auth/oidc"
+	oidcStateMaxAge = 10 * time.Minute
+)
+
+var (
+	errUnverifiedEmail = errors.New("provider did not verify the email address")
+	errAccountExists   = errors.New("an unverified account already uses the email address")
+	errIdentityInUse   = errors.New("identity is linked to another account")
+
+	nonNameCharacters = regexp.MustCompile(`[^a-zA-Z\s]+`)
+)
+
+type OIDCSettings struct {
+	AppURL           string
+	MFATokenDuration time.Duration
+}
+
+// oidcFlow is kept in a short-lived cookie between the redirect to the
+// provider and the callback. The link token is signed, so a tampered cookie
+// cannot attach an identity to someone else's account.
+type oidcFlow struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkToken    string `json:"link_token,omitempty"`
+}
+
+type OIDCController struct {
+	Log             *zap.Logger
+	ResponseHandler *helpers.ResponseHandler
+	DB              db.AuthRepository
+	JWTManager      *utils.JWTManager
+	Providers       map[string]*oidc.Provider
+	Settings        OIDCSettings
+}
+
+func NewOIDCController(logger *zap.Logger, db db.AuthRepository, jwtManager *utils.JWTManager, providers map[string]*oidc.Provider, settings OIDCSettings) *OIDCController {
+	return &OIDCController{
+		Log:             logger,
+		ResponseHandler: helpers.NewHandler(logger),
+		DB:              db,
+		JWTManager:      jwtManager,
+		Providers:       providers,
+		Settings:        settings,
+	}
+}
+
+// Login func start login with an external provider.
+// @Summary      Login With Provider
+// @Description  Redirect the browser to the OpenID Connect provider. After the callback the session cookies are set and the browser is sent back to the app.
+// @Tags         Auth
+// @Param        provider path string true "Provider name" Enums(google)
+// @Success      302
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      502  {object}  models.FailureResponse[string]
+// @Router       /api/auth/oidc/{provider}/login [get]
+func (oc *OIDCController) Login(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := oc.Providers[name]
+	if !ok {
+		oc.ResponseHandler.NotFoundError(w, r, fmt.Errorf("unknown provider %q", name), "Provider")
+		return
+	}
+
+	authURL, err := oc.startFlow(w, r, name, provider, "")
+	if err != nil {
+		oc.ResponseHandler.IdentityProviderError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Link func link an external provider to the current user.
+// @Summary      Link Provider
+// @Description  Start linking an OpenID Connect identity to the current account. Send the browser to the returned URL, the callback links the identity and redirects back to the app.
+// @Tags         Auth
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        provider path string true "Provider name" Enums(google)
+// @Success      200  {object}  models.SuccessResponse[models.OIDCLinkResponse]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      502  {object}  models.FailureResponse[string]
+// @Router       /api/auth/oidc/{provider}/link [post]
+func (oc *OIDCController) Link(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		oc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := oc.Providers[name]
+	if !ok {
+		oc.ResponseHandler.NotFoundError(w, r, fmt.Errorf("unknown provider %q", name), "Provider")
+		return
+	}
+
+	linkToken, err := oc.JWTManager.GenerateActionToken(userInfo.ID, "", utils.PurposeIdentityLink, oidcStateMaxAge)
+	if err != nil {
+		oc.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	authURL, err := oc.startFlow(w, r, name, provider, linkToken)
+	if err != nil {
+		oc.ResponseHandler.IdentityProviderError(w, r, err)
+		return
+	}
+
+	oc.ResponseHandler.Success(w, r, http.StatusOK, "Continue at the identity provider", models.OIDCLinkResponse{AuthorizationURL: authURL})
+}
+
+// Callback func finish login with an external provider.
+// @Summary      Provider Callback
+// @Description  Redirect target registered at the OpenID Connect provider. Verifies state, PKCE and the ID token, then signs the user in or links the identity. Errors are reported to the app as an error query parameter.
+// @Tags         Auth
+// @Param        provider path  string true  "Provider name" Enums(google)
+// @Param        code     query string false "Authorization code"
+// @Param        state    query string true  "State sent with the authorization request"
+// @Success      302
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Router       /api/auth/oidc/{provider}/callback [get]
+func (oc *OIDCController) Callback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+
+	provider, ok := oc.Providers[name]
+	if !ok {
+		oc.ResponseHandler.NotFoundError(w, r, fmt.Errorf("unknown provider %q", name), "Provider")
+		return
+	}
+
+	flow, err := oc.readFlow(r)
+	clearOIDCStateCookie(w)
+	if err != nil {
+		oc.redirectError(w, r, "invalid_state", err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if flow.Provider != name || subtle.ConstantTimeCompare([]byte(flow.State), []byte(query.Get("state"))) != 1 {
+		oc.redirectError(w, r, "invalid_state", fmt.Errorf("state mismatch"))
+		return
+	}
+
+	if providerError := query.Get("error"); providerError != "" {
+		oc.redirectError(w, r, "access_denied", fmt.Errorf("provider returned %s", providerError))
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), query.Get("code"), flow.CodeVerifier)
+	if err != nil {
+		oc.redirectError(w, r, "login_failed", err)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), token.IDToken, flow.Nonce)
+	if err != nil {
+		oc.redirectError(w, r, "login_failed", err)
+		return
+	}
+
+	if flow.LinkToken != "" {
+		if err := oc.linkIdentity(r.Context(), name, flow.LinkToken, claims); err != nil {
+			oc.redirectError(w, r, identityErrorCode(err), err)
+			return
+		}
+
+		oc.redirect(w, r, "/", url.Values{"linked": {name}})
+		return
+	}
+
+	user, err := oc.resolveUser(r.Context(), name, claims)
+	if err != nil {
+		oc.redirectError(w, r, identityErrorCode(err), err)
+		return
+	}
+
+	tokenResponse, err := beginSession(r.Context(), w, oc.DB, oc.JWTManager, oc.Settings.MFATokenDuration, user.ID, user.Email, user.Role, false)
+	if err != nil {
+		oc.redirectError(w, r, "login_failed", err)
+		return
+	}
+
+	if tokenResponse.MFARequired {
+		// A fragment never reaches servers or logs on the way to the app.
+		http.Redirect(w, r, oc.Settings.AppURL+"/login/mfa#mfa_token="+url.QueryEscape(tokenResponse.MFAToken), http.StatusFound)
+		return
+	}
+
+	oc.redirect(w, r, "/", nil)
+}
+
+func (oc *OIDCController) startFlow(w http.ResponseWriter, r *http.Request, name string, provider *oidc.Provider, linkToken string) (string, error) {
+	flow := oidcFlow{
+		Provider:  name,
+		LinkToken: linkToken,
+	}
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.CodeVerifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			return "", err
+		}
+		*value = random
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), flow.State, flow.Nonce, flow.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+
+	// Lax, the callback is a top-level navigation coming from the provider.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(encoded),
+		Path:     oidcStatePath,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcStateMaxAge.Seconds()),
+	})
+
+	return authURL, nil
+}
+
+func (oc *OIDCController) readFlow(r *http.Request) (oidcFlow, error) {
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return oidcFlow{}, fmt.Errorf("missing state cookie: %w", err)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return oidcFlow{}, fmt.Errorf("invalid state cookie: %w", err)
+	}
+
+	var flow oidcFlow
+	if err := json.Unmarshal(decoded, &flow); err != nil {
+		return oidcFlow{}, fmt.Errorf("invalid state cookie: %w", err)
+	}
+
+	return flow, nil
+}
+
+// resolveUser finds the account for an external identity. Unknown identities
+// are linked to the account with the same email address when both sides have
+// verified it, otherwise a new account is created.
+func (oc *OIDCController) resolveUser(ctx context.Context, provider string, claims *oidc.Claims) (models.IdentityUser, error) {
+	user, err := oc.DB.LoginWithIdentity(ctx, provider, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.IdentityUser{}, err
+	}
+
+	if !bool(claims.EmailVerified) || claims.Email == "" {
+		return models.IdentityUser{}, errUnverifiedEmail
+	}
+
+	existing, err := oc.DB.GetIdentityUserByEmail(ctx, claims.Email)
+	if err == nil {
+		// Linking to an unverified account would let whoever registered the
+		// address first keep access through their password.
+		if existing.EmailVerifiedAt == nil {
+			return models.IdentityUser{}, errAccountExists
+		}
+
+		if err := oc.DB.CreateIdentity(ctx, existing.ID, provider, claims.Subject, claims.Email); err != nil {
+			if dberrors.IsUniqueViolation(err, "") {
+				return models.IdentityUser{}, errIdentityInUse
+			}
+			return models.IdentityUser{}, err
+		}
+
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.IdentityUser{}, err
+	}
+
+	return oc.createUser(ctx, provider, claims)
+}
+
+func (oc *OIDCController) linkIdentity(ctx context.Context, provider, linkToken string, claims *oidc.Claims) error {
+	linkClaims, err := oc.JWTManager.ValidateActionToken(linkToken, utils.PurposeIdentityLink)
+	if err != nil {
+		return err
+	}
+
+	userID, err := uuid.Parse(linkClaims.Subject)
+	if err != nil {
+		return err
+	}
+
+	if _, err := oc.DB.GetIdentityUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := oc.DB.CreateIdentity(ctx, userID, provider, claims.Subject, claims.Email); err != nil {
+		if dberrors.IsUniqueViolation(err, "") {
+			return errIdentityInUse
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (oc *OIDCController) createUser(ctx context.Context, provider string, claims *oidc.Claims) (models.IdentityUser, error) {
+	// Accounts created through a provider get a random password, a password
+	// can be set later through the forgot-password flow.
+	password, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	newUser := &models.NewIdentityUser{
+		Name:         identityUserName(claims),
+		FullName:     claims.Name,
+		Email:        claims.Email,
+		PasswordHash: passwordHash,
+		Provider:     provider,
+		Subject:      claims.Subject,
+	}
+
+	baseName := newUser.Name
+	for attempt := 0; ; attempt++ {
+		user, err := oc.DB.CreateUserWithIdentity(ctx, newUser)
+		if err == nil {
+			return user, nil
+		}
+
+		if attempt == 3 || !dberrors.IsUniqueViolation(err, "users_name_key") {
+			return models.IdentityUser{}, err
+		}
+
+		suffix, err := randomLetters(5)
+		if err != nil {
+			return models.IdentityUser{}, err
+		}
+		newUser.Name = baseName + " " + suffix
+	}
+}
+
+func (oc *OIDCController) redirect(w http.ResponseWriter, r *http.Request, path string, query url.Values) {
+	target := oc.Settings.AppURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (oc *OIDCController) redirectError(w http.ResponseWriter, r *http.Request, code string, err error) {
+	oc.Log.Warn("OpenID Connect login failed",
+		zap.String("url", r.URL.Path),
+		zap.String("method", r.Method),
+		zap.String("error_code", code),
+		zap.Error(err))
+
+	oc.redirect(w, r, "/login", url.Values{"error": {code}})
+}
+
+func clearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     oidcStatePath,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+}
+
+func identityErrorCode(err error) string {
+	switch {
+	case errors.Is(err, errUnverifiedEmail):
+		return "unverified_email"
+	case errors.Is(err, errAccountExists):
+		return "account_exists"
+	case errors.Is(err, errIdentityInUse):
+		return "identity_in_use"
+	default:
+		return "login_failed"
+	}
+}
+
+// identityUserName turns the provider profile into a name that passes the
+// alpha_space rule used for registration.
+func identityUserName(claims *oidc.Claims) string {
+	candidates := []string{claims.Name, strings.Split(claims.Email, "@")[0]}
+
+	for _, candidate := range candidates {
+		name := strings.Join(strings.Fields(nonNameCharacters.ReplaceAllString(candidate, " ")), " ")
+		if len(name) > 44 {
+			name = strings.TrimSpace(name[:44])
+		}
+		if len(name) >= 2 {
+			return name
+		}
+	}
+
+	return "Otterly User"
+}
+
+func randomLetters(n int) (string, error) {
+	token, err := utils.GenerateOpaqueToken(n * 2)
+	if err != nil {
+		return "", err
+	}
+
+	letters := strings.ToLower(nonNameCharacters.ReplaceAllString(token, ""))
+	for len(letters) < n {
+		letters += "x"
+	}
+
+	return letters[:n], nil
+}
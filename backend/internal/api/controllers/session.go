@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/store"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+)
+
+// accessTokenLifetime is the longest an access token stays valid: the one of
+// a session, or of an impersonation when that is longer. Revoking every token
+// of a user has to last that long.
+func accessTokenLifetime(jwtManager *utils.JWTManager, impersonationDuration time.Duration) time.Duration {
+	return max(jwtManager.TokenDuration(), impersonationDuration)
+}
+
+// revokeUserSessions invalidates every access token issued to the user so far
+// and revokes all of their refresh tokens, signing them out on every device.
+// lifetime is the accessTokenLifetime.
+func revokeUserSessions(ctx context.Context, db db.AuthRepository, revocations store.RevocationStore, lifetime time.Duration, userID uuid.UUID) error {
+	if err := revokeAccessTokens(ctx, revocations, lifetime, userID); err != nil {
+		return err
+	}
+
+	if err := db.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// revokeAccessTokens invalidates every access token issued to the user so
+// far. They are not stored, so it is not part of a unit of work: call it once
+// the refresh tokens were revoked.
+func revokeAccessTokens(ctx context.Context, revocations store.RevocationStore, lifetime time.Duration, userID uuid.UUID) error {
+	if err := revocations.RevokeUser(ctx, userID, time.Now(), lifetime); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// revokeUserAccess signs the user out everywhere like revokeUserSessions and
+// also revokes their personal access tokens.
+func revokeUserAccess(ctx context.Context, db db.AuthRepository, revocations store.RevocationStore, lifetime time.Duration, userID uuid.UUID) error {
+	if err := revokeUserSessions(ctx, db, revocations, lifetime, userID); err != nil {
+		return err
+	}
+
+	if err := db.RevokeUserAPITokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke api tokens: %w", err)
+	}
+
+	return nil
+}
+
+// tokenDeliveryHeader is how clients that keep their tokens themselves, rather
+// than in cookies, ask for the refresh token in the response body.
+const tokenDeliveryHeader = "X-Token-Delivery"
+
+// wantsBodyTokens reports whether the client asked for the refresh token in
+// the body. Browsers keep it in the HttpOnly cookie, out of reach of scripts.
+func wantsBodyTokens(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(tokenDeliveryHeader), "body")
+}
+
+// beginSession is called once the first factor was verified. Users with two
+// factor authentication enabled only get an MFA token, to be exchanged for a
+// session at /api/auth/mfa/verify.
+func beginSession(ctx context.Context, w http.ResponseWriter, db db.AuthRepository, jwtManager *utils.JWTManager, mfaTokenDuration time.Duration, userID uuid.UUID, email string, role models.UserRole, bodyTokens bool) (models.TokenResponse, error) {
+	mfa, err := db.GetMFA(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.TokenResponse{}, fmt.Errorf("failed to check two-factor enrollment: %w", err)
+	}
+
+	if err == nil && mfa.ConfirmedAt != nil {
+		mfaToken, err := jwtManager.GenerateActionToken(userID, email, utils.PurposeMFALogin, mfaTokenDuration)
+		if err != nil {
+			return models.TokenResponse{}, err
+		}
+
+		return models.TokenResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresIn:   int(mfaTokenDuration.Seconds()),
+		}, nil
+	}
+
+	return startSession(ctx, w, db, jwtManager, userID, email, role, bodyTokens)
+}
+
+// startSession issues a new access token and a refresh token in a new family
+// and sets them as cookies. The access token is also returned for bearer
+// clients, the refresh token only when bodyTokens is set.
+func startSession(ctx context.Context, w http.ResponseWriter, db db.AuthRepository, jwtManager *utils.JWTManager, userID uuid.UUID, email string, role models.UserRole, bodyTokens bool) (models.TokenResponse, error) {
+	token, duration, err := jwtManager.GenerateToken(userID.String(), email, role)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	refreshToken, refreshHash, refreshExpiresAt, err := jwtManager.GenerateRefreshToken()
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	if err := db.CreateRefreshToken(ctx, userID, uuid.New(), refreshHash, refreshExpiresAt); err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	setSessionCookies(w, token, duration, refreshToken, refreshExpiresAt)
+
+	tokenResponse := models.TokenResponse{
+		Role:        role,
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(duration.Seconds()),
+	}
+
+	if bodyTokens {
+		tokenResponse.RefreshToken = refreshToken
+	}
+
+	return tokenResponse, nil
+}
+
+func setSessionCookies(w http.ResponseWriter, accessToken string, accessDuration time.Duration, refreshToken string, refreshExpiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(accessDuration.Seconds()),
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    refreshToken,
+		Path:     refreshTokenPath,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(time.Until(refreshExpiresAt).Seconds()),
+	})
+}
+
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    "",
+		Path:     refreshTokenPath,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+	})
+}
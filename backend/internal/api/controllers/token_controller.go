@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
+	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/policy"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+type TokenController struct {
+	Log             *zap.Logger
+	Validate        *validator.Validate
+	ResponseHandler *helpers.ResponseHandler
+	DB              db.Repository
+	Permissions     *policy.RolePermissions
+}
+
+func NewTokenController(logger *zap.Logger, validator *validator.Validate, db db.Repository, permissions *policy.RolePermissions) *TokenController {
+	return &TokenController{
+		Log:             logger,
+		Validate:        validator,
+		ResponseHandler: helpers.NewHandler(logger),
+		DB:              db,
+		Permissions:     permissions,
+	}
+}
+
+// CreateToken func create personal access token.
+// @Summary      Create API Token
+// @Description  Create a personal access token for the current user. Holders of tokens:manage may pass user_id to create a token for a service account, which is recorded in the audit log. The token is only returned once.
+// @Tags         Tokens
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        request body   models.CreateAPITokenRequest true "Create API token request"
+// @Success      201  {object}  models.SuccessResponse[models.CreateAPITokenResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/tokens [post]
+func (tc *TokenController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		tc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	newToken := &models.CreateAPITokenRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(newToken); err != nil {
+		tc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := tc.Validate.Struct(newToken); err != nil {
+		tc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	ownerID, ok := tc.resolveOwner(w, r, userInfo, newToken.UserID)
+	if !ok {
+		return
+	}
+
+	owner, err := tc.DB.GetUser(r.Context(), ownerID)
+	if err != nil {
+		tc.ResponseHandler.NotFoundError(w, r, err, "User")
+		return
+	}
+
+	granted, err := tc.Permissions.Resolve(r.Context(), owner.Role)
+	if err != nil {
+		tc.ResponseHandler.PermissionResolutionError(w, r, err)
+		return
+	}
+
+	for _, scope := range newToken.Scopes {
+		if !slices.Contains(granted, scope) {
+			tc.ResponseHandler.ScopeNotGrantableError(w, r, scope, owner.Role)
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if newToken.ExpiresInDays > 0 {
+		expiry := time.Now().AddDate(0, 0, newToken.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	token, prefix, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		tc.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	created, err := tc.DB.CreateAPIToken(r.Context(), ownerID, newToken.Name, prefix, hash, models.Scopes(newToken.Scopes), expiresAt)
+	if err != nil {
+		tc.ResponseHandler.CreateItemError(w, r, err, "API token")
+		return
+	}
+	created.Token = token
+
+	if ownerID != userInfo.ID {
+		ipAddress := clientIP(r)
+		if err := tc.DB.CreateAuditLog(r.Context(), &models.AuditLog{
+			ActorID:  &userInfo.ID,
+			Action:   models.AuditAPITokenCreated,
+			TargetID: &ownerID,
+			Details: models.AuditDetails{
+				"token_id": created.ID,
+				"name":     created.Name,
+				"scopes":   created.Scopes,
+			},
+			IPAddress: &ipAddress,
+		}); err != nil {
+			// A token nobody can trace back to its creator must not stay
+			// usable.
+			if revokeErr := tc.DB.RevokeAPIToken(r.Context(), created.ID, ownerID); revokeErr != nil {
+				tc.Log.Error("Failed to revoke unaudited api token",
+					zap.String("token_id", created.ID.String()),
+					zap.Error(revokeErr))
+			}
+			tc.ResponseHandler.CreateItemError(w, r, err, "Audit log")
+			return
+		}
+	}
+
+	tc.ResponseHandler.Success(w, r, http.StatusCreated, "API token created successfully", created)
+}
+
+// GetTokens func get personal access tokens.
+// @Summary      Get API Tokens
+// @Description  List the active personal access tokens of the current user. Holders of tokens:manage may pass user_id to list the tokens of another user.
+// @Tags         Tokens
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        user_id query string false "User ID (requires tokens:manage)"
+// @Success      200  {object}  models.SuccessResponse[[]models.APITokenResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/tokens [get]
+func (tc *TokenController) GetTokens(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		tc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	requestedOwner, ok := tc.parseUserIDQuery(w, r)
+	if !ok {
+		return
+	}
+
+	ownerID, ok := tc.resolveOwner(w, r, userInfo, requestedOwner)
+	if !ok {
+		return
+	}
+
+	tokens, err := tc.DB.GetAPITokens(r.Context(), ownerID)
+	if err != nil {
+		tc.ResponseHandler.NotFoundError(w, r, err, "API tokens")
+		return
+	}
+
+	tc.ResponseHandler.Success(w, r, http.StatusOK, "API tokens found", tokens)
+}
+
+// RevokeToken func revoke personal access token.
+// @Summary      Revoke API Token
+// @Description  Revoke a personal access token of the current user. Holders of tokens:manage may pass user_id to revoke the token of another user.
+// @Tags         Tokens
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "Token ID"
+// @Param        user_id query string false "User ID (requires tokens:manage)"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      401  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/tokens/{id} [delete]
+func (tc *TokenController) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		tc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		tc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	requestedOwner, ok := tc.parseUserIDQuery(w, r)
+	if !ok {
+		return
+	}
+
+	ownerID, ok := tc.resolveOwner(w, r, userInfo, requestedOwner)
+	if !ok {
+		return
+	}
+
+	if err := tc.DB.RevokeAPIToken(r.Context(), parsedId, ownerID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			tc.ResponseHandler.NotFoundError(w, r, err, "API token")
+			return
+		}
+		tc.ResponseHandler.DeleteItemError(w, r, err, "API token")
+		return
+	}
+
+	tc.ResponseHandler.Success(w, r, http.StatusOK, "API token revoked successfully", nil)
+}
+
+// resolveOwner returns whose tokens the request acts on. Only holders of
+// tokens:manage may act on behalf of another user, e.g. a service account.
+func (tc *TokenController) resolveOwner(w http.ResponseWriter, r *http.Request, userInfo *middlewares.UserInfo, requested *uuid.UUID) (uuid.UUID, bool) {
+	if requested == nil || *requested == userInfo.ID {
+		return userInfo.ID, true
+	}
+
+	if !userInfo.HasPermission(models.PermissionTokensManage) {
+		tc.ResponseHandler.InsufficientPermissionsError(w, r)
+		return uuid.Nil, false
+	}
+
+	return *requested, true
+}
+
+func (tc *TokenController) parseUserIDQuery(w http.ResponseWriter, r *http.Request) (*uuid.UUID, bool) {
+	value := r.URL.Query().Get("user_id")
+	if value == "" {
+		return nil, true
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		tc.ResponseHandler.InvalidIDError(w, r, err)
+		return nil, false
+	}
+
+	return &userID, true
+}
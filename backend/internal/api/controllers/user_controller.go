@@ -1,46 +1,142 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/otterly-id/otterly/backend/db"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/api/queries"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
 	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+	"github.com/otterly-id/otterly/backend/internal/policy"
+	"github.com/otterly-id/otterly/backend/internal/store"
 	"github.com/otterly-id/otterly/backend/internal/utils"
 	"go.uber.org/zap"
 )
 
+var (
+	// viewPrivateProfile decides who sees the email and phone number of a user.
+	viewPrivateProfile = policy.SelfOr(models.PermissionUsersReadPrivate)
+
+	// editUser lets users edit their own record with profile:write, other
+	// records require users:write.
+	editUser = policy.AnyOf(
+		policy.AllOf(policy.Self(), policy.Permission(models.PermissionProfileWrite)),
+		policy.Permission(models.PermissionUsersWrite),
+	)
+)
+
+// userListSpec is accepted by GET /api/users.
+var userListSpec = helpers.ListSpec{
+	SortFields:   queries.UserSortFields,
+	SortTypes:    queries.UserSortTypes,
+	DefaultSort:  "created_at",
+	Filters:      []string{"role", "email", "q"},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// userSearchSpec is accepted by GET /api/users/search.
+var userSearchSpec = helpers.ListSpec{
+	SortFields:   queries.UserSearchSortFields,
+	SortTypes:    queries.UserSearchSortTypes,
+	DefaultSort:  "-relevance",
+	Filters:      []string{"role", "q"},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// deletedUserListSpec is accepted by GET /api/users/deleted.
+var deletedUserListSpec = helpers.ListSpec{
+	SortFields:   queries.DeletedUserSortFields,
+	SortTypes:    queries.DeletedUserSortTypes,
+	DefaultSort:  "-deleted_at",
+	Filters:      []string{"role", "email", "q"},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// userExportFilters are accepted by GET /api/users/export.
+var userExportFilters = []string{"role", "email", "q"}
+
+const (
+	// userImportMaxRows and userImportMaxBytes bound a single import.
+	userImportMaxRows  = 1000
+	userImportMaxBytes = 5 << 20
+)
+
+type UserSettings struct {
+	AppURL                string
+	ImpersonationDuration time.Duration
+	// EmailVerificationDuration is how long the link sent when an address is
+	// changed stays valid.
+	EmailVerificationDuration time.Duration
+	// InviteDuration is how long the password link sent to imported users
+	// without a password stays valid.
+	InviteDuration time.Duration
+	// PurgeRetention is how long deleted users are kept before the purge job
+	// removes them, zero if they are kept forever.
+	PurgeRetention time.Duration
+}
+
 type UserController struct {
 	Log             *zap.Logger
 	Validate        *validator.Validate
 	ResponseHandler *helpers.ResponseHandler
-	DB              *db.Queries
+	DB              db.Repository
+	JWTManager      *utils.JWTManager
+	Revocations     store.RevocationStore
+	Permissions     *policy.RolePermissions
+	Mailer          mailer.Mailer
+	Settings        UserSettings
 }
 
-func NewUserController(logger *zap.Logger, validator *validator.Validate, db *db.Queries) *UserController {
+func NewUserController(logger *zap.Logger, validator *validator.Validate, db db.Repository, jwtManager *utils.JWTManager, revocations store.RevocationStore, permissions *policy.RolePermissions, mailer mailer.Mailer, settings UserSettings) *UserController {
 	return &UserController{
 		Log:             logger,
 		Validate:        validator,
 		ResponseHandler: helpers.NewHandler(logger),
 		DB:              db,
+		JWTManager:      jwtManager,
+		Revocations:     revocations,
+		Permissions:     permissions,
+		Mailer:          mailer,
+		Settings:        settings,
 	}
 }
 
 // CreateUser func create single user.
 // @Summary      Create User
 // @Description  Add new user data.
-// @Tags         Users
+// @Tags         Users, Management
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
 // @Param        request body   models.CreateUserRequest true "Create user request"
 // @Success      200  {object}  models.SuccessResponse[models.CreateUserResponse]
 // @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse{errors=models.FieldError}
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/users [post]
 func (uc *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +152,10 @@ func (uc *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !uc.authorizeRole(w, r, models.UserRole(newUser.Role)) {
+		return
+	}
+
 	hashedPassword, err := utils.HashPassword(newUser.Password)
 	if err != nil {
 		uc.ResponseHandler.HashPasswordError(w, r, err)
@@ -63,7 +163,7 @@ func (uc *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	newUser.Password = string(hashedPassword)
 
-	user, err := uc.DB.CreateUser(newUser)
+	user, err := uc.DB.CreateUser(r.Context(), newUser)
 	if err != nil {
 		uc.ResponseHandler.CreateItemError(w, r, err, "user")
 		return
@@ -72,37 +172,308 @@ func (uc *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	uc.ResponseHandler.Success(w, r, http.StatusCreated, "User created successfully", user)
 }
 
+// ImportUsers func create users in bulk.
+// @Summary      Import Users
+// @Description  Create up to 1000 users at once from a CSV file with a header row (text/csv) or a JSON array (application/json), both using the fields of models.ImportUserRow. Every row is validated like a single created user and all of them are created in one transaction, so either every user is created or none. Failures are reported per row, counted from 1 without the header. password_hash takes a bcrypt hash, users imported with one are marked as verified and get no email, users without one are emailed a link to choose their password. With dry_run the import is checked, including conflicts with existing users, without creating anything.
+// @Tags         Users, Management
+// @Accept       json
+// @Accept       text/csv
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        dry_run query   bool                   false "Only check the import"
+// @Param        request body    []models.ImportUserRow true  "Users to import"
+// @Success      200  {object}  models.SuccessResponse[models.ImportUsersResponse]
+// @Success      201  {object}  models.SuccessResponse[models.ImportUsersResponse]
+// @Failure      400  {object}  models.FailureResponse[[]models.ImportRowError]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/import [post]
+func (uc *UserController) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			uc.ResponseHandler.InvalidQueryError(w, r, errors.New("dry_run must be true or false"))
+			return
+		}
+		dryRun = parsed
+	}
+
+	rows, err := decodeUserImport(w, r)
+	if err != nil {
+		uc.ResponseHandler.InvalidImportError(w, r, err)
+		return
+	}
+
+	rowErrors, err := uc.validateUserImport(r.Context(), userInfo, rows)
+	if err != nil {
+		uc.ResponseHandler.PermissionResolutionError(w, r, err)
+		return
+	}
+
+	if len(rowErrors) > 0 {
+		uc.ResponseHandler.ImportRowsError(w, r, rowErrors)
+		return
+	}
+
+	// Invited users share one hash of a random password nobody knows, until
+	// they choose their own.
+	invited := []int{}
+	for i := range rows {
+		if rows[i].PasswordHash != "" {
+			rows[i].EmailVerified = true
+			continue
+		}
+
+		if len(invited) == 0 {
+			password, err := utils.GenerateOpaqueToken(32)
+			if err != nil {
+				uc.ResponseHandler.HashPasswordError(w, r, err)
+				return
+			}
+
+			hash, err := utils.HashPassword(password)
+			if err != nil {
+				uc.ResponseHandler.HashPasswordError(w, r, err)
+				return
+			}
+			rows[i].PasswordHash = hash
+		} else {
+			rows[i].PasswordHash = rows[invited[0]].PasswordHash
+		}
+
+		invited = append(invited, i)
+	}
+
+	ipAddress := clientIP(r)
+	users, err := uc.DB.ImportUsers(r.Context(), rows, dryRun, models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserImported,
+		Details:   models.AuditDetails{"source": "import"},
+		IPAddress: &ipAddress,
+	})
+	if err != nil {
+		var conflict *queries.ImportConflictError
+		if errors.As(err, &conflict) {
+			uc.ResponseHandler.ImportRowsError(w, r, importConflictErrors(conflict))
+			return
+		}
+		uc.ResponseHandler.CreateItemError(w, r, err, "users")
+		return
+	}
+
+	response := models.ImportUsersResponse{
+		DryRun:  dryRun,
+		Created: len(users),
+		Invited: len(invited),
+	}
+
+	if dryRun {
+		uc.ResponseHandler.Success(w, r, http.StatusOK, "Import checked, nothing was created", response)
+		return
+	}
+
+	response.Users = users
+
+	uc.Log.Info("Users imported",
+		zap.String("actor_id", userInfo.ID.String()),
+		zap.Int("created", len(users)),
+		zap.Int("invited", len(invited)))
+
+	if len(invited) > 0 {
+		invitees := make([]models.CreateUserResponse, len(invited))
+		for i, index := range invited {
+			invitees[i] = users[index]
+		}
+
+		inBackground(r.Context(), func(ctx context.Context) { uc.sendInvites(ctx, invitees) })
+	}
+
+	uc.ResponseHandler.Success(w, r, http.StatusCreated, "Users imported successfully", response)
+}
+
+// ExportUsers func export users.
+// @Summary      Export Users
+// @Description  Download every active user matching the filters, oldest first, as CSV with a header row or as newline delimited JSON. The export is streamed, so it works for any number of users. Password hashes are never exported. CSV values starting with =, +, -, @, a tab or a carriage return are prefixed with ' so spreadsheets do not run them as formulas.
+// @Tags         Users, Admin
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        format query string false "Export format (default csv)" Enums(csv, ndjson)
+// @Param        role   query string false "Filter by role" Enums(ADMIN, OWNER, USER)
+// @Param        email  query string false "Filter by email address"
+// @Param        q      query string false "Search in name and full name"
+// @Success      200  {file}    file
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Router       /api/users/export [get]
+func (uc *UserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := models.ListParams{Filters: helpers.ParseListFilters(query, userExportFilters)}
+
+	if role := params.Filter("role"); role != "" && !models.IsValidRole(role) {
+		uc.ResponseHandler.InvalidQueryError(w, r, fmt.Errorf("unknown role %q", role))
+		return
+	}
+
+	switch format := query.Get("format"); format {
+	case "", "csv":
+		uc.ResponseHandler.Stream(w, r, "text/csv; charset=utf-8", "users.csv", func(out io.Writer) error {
+			writer := csv.NewWriter(out)
+			if err := writer.Write(models.ExportUserColumns); err != nil {
+				return err
+			}
+
+			if err := uc.DB.ExportUsers(r.Context(), params, func(user models.ExportUser) error {
+				return writer.Write(user.Record())
+			}); err != nil {
+				return err
+			}
+
+			writer.Flush()
+			return writer.Error()
+		})
+	case "ndjson":
+		uc.ResponseHandler.Stream(w, r, "application/x-ndjson", "users.ndjson", func(out io.Writer) error {
+			encoder := json.NewEncoder(out)
+			return uc.DB.ExportUsers(r.Context(), params, func(user models.ExportUser) error {
+				return encoder.Encode(user)
+			})
+		})
+	default:
+		uc.ResponseHandler.InvalidQueryError(w, r, errors.New("format must be csv or ndjson"))
+	}
+}
+
 // GetUsers func get all users.
 // @Summary      Get All Users
-// @Description  Get all users data.
+// @Description  Get a page of users. Pass meta.next_cursor as cursor to get the next page. Email and phone number of other users are only included for holders of users:read-private, otherwise each entry is a models.PublicUserResponse. Filtering or sorting by email also requires users:read-private.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
-// @Success      200  {object}  models.SuccessResponse[[]models.UserResponse]
+// @Security     BearerAuth
+// @Param        limit  query string false "Page size, 1 to 100 (default 20)"
+// @Param        cursor query string false "Cursor of the next page, from meta.next_cursor"
+// @Param        sort   query string false "Sort field: name, email or created_at, prefixed with - for descending order (default created_at)"
+// @Param        role   query string false "Filter by role" Enums(ADMIN, OWNER, USER)
+// @Param        email  query string false "Filter by email address"
+// @Param        q      query string false "Search in name and full name"
+// @Success      200  {object}  models.SuccessListResponse[[]models.UserResponse]
 // @Failure      400  {object}  models.FailureResponse[string]
-// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/users [get]
 func (uc *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := uc.DB.GetUsers()
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	params, err := helpers.ParseListParams(r.URL.Query(), userListSpec)
+	if err != nil {
+		uc.ResponseHandler.InvalidQueryError(w, r, err)
+		return
+	}
+
+	if role := params.Filter("role"); role != "" && !models.IsValidRole(role) {
+		uc.ResponseHandler.InvalidQueryError(w, r, fmt.Errorf("unknown role %q", role))
+		return
+	}
+
+	// Filtering or ordering by email would reveal addresses that the
+	// projection hides.
+	if (params.Filter("email") != "" || params.Sort == "email") && !userInfo.HasPermission(models.PermissionUsersReadPrivate) {
+		uc.ResponseHandler.InsufficientPermissionsError(w, r)
+		return
+	}
+
+	page, err := uc.DB.GetUsers(r.Context(), params)
 	if err != nil {
-		uc.ResponseHandler.NotFoundError(w, r, err, "Users")
+		uc.ResponseHandler.ListItemsError(w, r, err, "Users")
 		return
 	}
 
-	uc.ResponseHandler.Success(w, r, http.StatusOK, "Users found", users)
+	projected := make([]any, len(page.Items))
+	for i, user := range page.Items {
+		projected[i] = projectUser(userInfo, user)
+	}
+
+	uc.ResponseHandler.SuccessWithMeta(w, r, http.StatusOK, "Users found", projected, helpers.ListMeta(params, page))
+}
+
+// SearchUsers func search users.
+// @Summary      Search Users
+// @Description  Find users by whole, partial or misspelled words of their name, full name or email, ranked by relevance. Matches are highlighted with <mark> tags in HTML escaped copies of the fields. Pages like the users list.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        q      query string true  "Search text"
+// @Param        limit  query string false "Page size, 1 to 100 (default 20)"
+// @Param        cursor query string false "Cursor of the next page, from meta.next_cursor"
+// @Param        role   query string false "Filter by role" Enums(ADMIN, OWNER, USER)
+// @Success      200  {object}  models.SuccessListResponse[[]models.UserSearchResult]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/search [get]
+func (uc *UserController) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := helpers.ParseListParams(r.URL.Query(), userSearchSpec)
+	if err != nil {
+		uc.ResponseHandler.InvalidQueryError(w, r, err)
+		return
+	}
+
+	search := params.Filter("q")
+	if search == "" || len(search) > 100 {
+		uc.ResponseHandler.InvalidQueryError(w, r, errors.New("q must be between 1 and 100 characters"))
+		return
+	}
+
+	if role := params.Filter("role"); role != "" && !models.IsValidRole(role) {
+		uc.ResponseHandler.InvalidQueryError(w, r, fmt.Errorf("unknown role %q", role))
+		return
+	}
+
+	page, err := uc.DB.SearchUsers(r.Context(), search, params)
+	if err != nil {
+		uc.ResponseHandler.ListItemsError(w, r, err, "Users")
+		return
+	}
+
+	terms := utils.SearchTerms(search)
+	for i := range page.Items {
+		page.Items[i].Highlights = highlightUser(page.Items[i].UserResponse, terms)
+	}
+
+	uc.ResponseHandler.SuccessWithMeta(w, r, http.StatusOK, "Users found", page.Items, helpers.ListMeta(params, page))
 }
 
 // GetUser func get user by ID.
 // @Summary      Get User by ID
-// @Description  Get user data based on provided ID
+// @Description  Get user data based on provided ID. Email and phone number of other users are only included for holders of users:read-private, otherwise the data is a models.PublicUserResponse. The ETag header carries the version of the user for conditional updates.
 // @Tags         Users
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
 // @Param id 	 path string true "User ID"
+// @Param        If-None-Match header string false "ETag of a copy the caller already has"
 // @Success      200  {object}  models.SuccessResponse[models.UserResponse]
+// @Header       200  {string}  ETag "Version of the user, for If-Match"
+// @Success      304  "The user has not changed"
 // @Failure      400  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
 // @Failure      500  {object}  models.FailureResponse[string]
@@ -120,27 +491,44 @@ func (uc *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := uc.DB.GetUser(parsedId)
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	user, err := uc.DB.GetUser(r.Context(), parsedId)
 	if err != nil {
 		uc.ResponseHandler.NotFoundError(w, r, err, "User")
 		return
 	}
 
-	uc.ResponseHandler.Success(w, r, http.StatusOK, "User found", user)
+	if uc.ResponseHandler.NotModified(w, r, user.Version) {
+		return
+	}
+
+	helpers.SetETag(w, user.Version)
+	uc.ResponseHandler.Success(w, r, http.StatusOK, "User found", projectUser(userInfo, user))
 }
 
 // UpdateUser func update single user.
 // @Summary      Update User
-// @Description  Edit user data based on provided ID.
-// @Tags         Users
+// @Description  Edit user data based on provided ID. Users may edit their own record, other records require users:write. A changed email address has to be verified again, a verification link is sent to it. Email addresses cannot be changed while impersonating. Send the ETag of GET /api/users/{id} as If-Match to only apply the change if nobody else changed the user since, otherwise 412 is returned.
+// @Tags         Users, Management
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
 // @Param id 	 path string true "User ID"
+// @Param        If-Match header string false "ETag the change is based on"
 // @Param		 request body   models.UpdateUserRequest true "Update user request"
 // @Success      200  {object}  models.SuccessResponse[models.UpdateUserResponse]
+// @Header       200  {string}  ETag "New version of the user"
 // @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse{errors=models.FieldError}
+// @Failure      412  {object}  models.FailureResponse[string]
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/users/{id} [patch]
 func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -156,6 +544,12 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := helpers.IfMatch(r)
+	if err != nil {
+		uc.ResponseHandler.InvalidPreconditionError(w, r, err)
+		return
+	}
+
 	selectedUser := &models.UpdateUserRequest{}
 
 	if err := json.NewDecoder(r.Body).Decode(selectedUser); err != nil {
@@ -168,26 +562,64 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := uc.DB.UpdateUser(parsedId, selectedUser)
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if !policy.Allowed(userInfo, parsedId, editUser) {
+		uc.ResponseHandler.InsufficientPermissionsError(w, r)
+		return
+	}
+
+	if selectedUser.Email != nil && userInfo.IsImpersonated() {
+		uc.ResponseHandler.ImpersonationForbiddenError(w, r)
+		return
+	}
+
+	target, ok := uc.authorizeTarget(w, r, parsedId)
+	if !ok {
+		return
+	}
+
+	user, err := uc.DB.UpdateUser(r.Context(), parsedId, selectedUser, version)
 	if err != nil {
-		uc.ResponseHandler.UpdateItemError(w, r, err, "User")
+		switch {
+		case errors.Is(err, queries.ErrVersionMismatch):
+			uc.ResponseHandler.PreconditionFailedError(w, r, "User")
+		case errors.Is(err, sql.ErrNoRows):
+			uc.ResponseHandler.NotFoundError(w, r, err, "User")
+		default:
+			uc.ResponseHandler.UpdateItemError(w, r, err, "User")
+		}
 		return
 	}
 
+	// A new address is unverified until its owner confirms it, like one
+	// changed through /api/auth/profile.
+	if user.Email != target.Email {
+		uc.queueVerificationEmail(r.Context(), user.ID, user.Name, user.Email)
+	}
+
+	helpers.SetETag(w, user.Version)
 	uc.ResponseHandler.Success(w, r, http.StatusCreated, "User updated successfully", user)
 }
 
 // DeleteUser func delete single user.
 // @Summary      Delete User
-// @Description  Remove user data based on provided ID.
-// @Tags         Users
+// @Description  Remove user data based on provided ID. The last admin cannot be deleted. The deletion is recorded in the audit log and revokes the sessions and personal access tokens of the user.
+// @Tags         Users, Admin
 // @Accept       json
 // @Produce      json
 // @Security     CookieAuth
+// @Security     BearerAuth
 // @Param id	 path string true "User ID"
 // @Success      200  {object}  models.SuccessResponseWithoutData
 // @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
 // @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
 // @Failure      500  {object}  models.FailureResponse[string]
 // @Router       /api/users/{id} [delete]
 func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
@@ -203,10 +635,658 @@ func (uc *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := uc.DB.DeleteUser(parsedId); err != nil {
-		uc.ResponseHandler.DeleteItemError(w, r, err, "User")
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if _, ok := uc.authorizeTarget(w, r, parsedId); !ok {
+		return
+	}
+
+	ipAddress := clientIP(r)
+	if err := uc.DB.DeleteUser(r.Context(), parsedId, &models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserDeleted,
+		IPAddress: &ipAddress,
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			uc.ResponseHandler.NotFoundError(w, r, err, "User")
+		case errors.Is(err, queries.ErrLastAdmin):
+			uc.ResponseHandler.LastAdminError(w, r)
+		default:
+			uc.ResponseHandler.DeleteItemError(w, r, err, "User")
+		}
+		return
+	}
+
+	uc.Log.Info("User deleted",
+		zap.String("user_id", parsedId.String()),
+		zap.String("actor_id", userInfo.ID.String()))
+
+	if err := revokeUserAccess(r.Context(), uc.DB, uc.Revocations, accessTokenLifetime(uc.JWTManager, uc.Settings.ImpersonationDuration), parsedId); err != nil {
+		uc.ResponseHandler.SessionRevocationError(w, r, err)
 		return
 	}
 
 	uc.ResponseHandler.Success(w, r, http.StatusOK, "User deleted successfully", nil)
-}
\ No newline at end of file
+}
+
+// GetDeletedUsers func get deleted users.
+// @Summary      Get Deleted Users
+// @Description  Get a page of soft deleted users, with the time each one is purged at unless restored. Pages like the users list.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param        limit  query string false "Page size, 1 to 100 (default 20)"
+// @Param        cursor query string false "Cursor of the next page, from meta.next_cursor"
+// @Param        sort   query string false "Sort field: name, email or deleted_at, prefixed with - for descending order (default -deleted_at)"
+// @Param        role   query string false "Filter by role" Enums(ADMIN, OWNER, USER)
+// @Param        email  query string false "Filter by email address"
+// @Param        q      query string false "Search in name and full name"
+// @Success      200  {object}  models.SuccessListResponse[[]models.DeletedUserResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/deleted [get]
+func (uc *UserController) GetDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := helpers.ParseListParams(r.URL.Query(), deletedUserListSpec)
+	if err != nil {
+		uc.ResponseHandler.InvalidQueryError(w, r, err)
+		return
+	}
+
+	if role := params.Filter("role"); role != "" && !models.IsValidRole(role) {
+		uc.ResponseHandler.InvalidQueryError(w, r, fmt.Errorf("unknown role %q", role))
+		return
+	}
+
+	page, err := uc.DB.GetDeletedUsers(r.Context(), params)
+	if err != nil {
+		uc.ResponseHandler.ListItemsError(w, r, err, "Deleted users")
+		return
+	}
+
+	if uc.Settings.PurgeRetention > 0 {
+		for i := range page.Items {
+			purgeAt := page.Items[i].DeletedAt.Add(uc.Settings.PurgeRetention)
+			page.Items[i].PurgeAt = &purgeAt
+		}
+	}
+
+	uc.ResponseHandler.SuccessWithMeta(w, r, http.StatusOK, "Deleted users found", page.Items, helpers.ListMeta(params, page))
+}
+
+// RestoreUser func restore a deleted user.
+// @Summary      Restore User
+// @Description  Bring back the soft deleted user with the provided ID. Fails while another user holds the same name or email address. Identities linked before the deletion are not restored. The restore is recorded in the audit log.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "User ID"
+// @Success      200  {object}  models.SuccessResponse[models.UserResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/{id}/restore [post]
+func (uc *UserController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if !uc.authorizeDeletedTarget(w, r, parsedId) {
+		return
+	}
+
+	ipAddress := clientIP(r)
+	user, err := uc.DB.RestoreUser(r.Context(), parsedId, &models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserRestored,
+		IPAddress: &ipAddress,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			uc.ResponseHandler.NotFoundError(w, r, err, "Deleted user")
+		case dberrors.IsUniqueViolation(err, ""):
+			uc.ResponseHandler.DuplicateKeyError(w, r, err, "user")
+		default:
+			uc.ResponseHandler.UpdateItemError(w, r, err, "User")
+		}
+		return
+	}
+
+	uc.Log.Info("User restored",
+		zap.String("user_id", user.ID.String()),
+		zap.String("actor_id", userInfo.ID.String()))
+
+	uc.ResponseHandler.Success(w, r, http.StatusOK, "User restored successfully", user)
+}
+
+// PurgeUser func permanently remove a deleted user.
+// @Summary      Purge User
+// @Description  Permanently remove the soft deleted user with the provided ID and everything belonging to it, without waiting for the retention period. Active users have to be deleted first. The purge is recorded in the audit log.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "User ID"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/{id}/purge [delete]
+func (uc *UserController) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if !uc.authorizeDeletedTarget(w, r, parsedId) {
+		return
+	}
+
+	ipAddress := clientIP(r)
+	if err := uc.DB.PurgeUser(r.Context(), parsedId, &models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserPurged,
+		IPAddress: &ipAddress,
+	}); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			uc.ResponseHandler.NotFoundError(w, r, err, "Deleted user")
+		case errors.Is(err, queries.ErrLastAdmin):
+			uc.ResponseHandler.LastAdminError(w, r)
+		default:
+			uc.ResponseHandler.DeleteItemError(w, r, err, "User")
+		}
+		return
+	}
+
+	uc.Log.Info("User purged",
+		zap.String("user_id", parsedId.String()),
+		zap.String("actor_id", userInfo.ID.String()))
+
+	uc.ResponseHandler.Success(w, r, http.StatusOK, "User purged successfully", nil)
+}
+
+// UpdateUserRole func change the role of a user.
+// @Summary      Update User Role
+// @Description  Change the role of the user with the provided ID. The last admin cannot be demoted. The change is recorded in the audit log and signs the user out, so new tokens carry the new role.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "User ID"
+// @Param        request body   models.UpdateUserRoleRequest true "Update user role request"
+// @Success      200  {object}  models.SuccessResponse[models.UserRoleResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      409  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/{id}/role [put]
+func (uc *UserController) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	request := &models.UpdateUserRoleRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		uc.ResponseHandler.JSONDecodeError(w, r, err)
+		return
+	}
+
+	if err := uc.Validate.Struct(request); err != nil {
+		uc.ResponseHandler.ValidationError(w, r, err)
+		return
+	}
+
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	role := models.UserRole(request.Role)
+	if _, ok := uc.authorizeTarget(w, r, parsedId); !ok {
+		return
+	}
+
+	if !uc.authorizeRole(w, r, role) {
+		return
+	}
+
+	// The refresh tokens are revoked with the change, so none of them can
+	// outlive the old role.
+	ipAddress := clientIP(r)
+	var user models.UserRoleResponse
+	err = uc.DB.WithTx(r.Context(), func(tx db.Repository) error {
+		var err error
+		user, err = tx.UpdateUserRole(r.Context(), parsedId, role, &models.AuditLog{
+			ActorID:   &userInfo.ID,
+			Action:    models.AuditUserRoleChanged,
+			IPAddress: &ipAddress,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.RevokeUserRefreshTokens(r.Context(), user.ID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			uc.ResponseHandler.NotFoundError(w, r, err, "User")
+		case errors.Is(err, queries.ErrRoleUnchanged), errors.Is(err, queries.ErrLastAdmin):
+			uc.ResponseHandler.RoleTransitionError(w, r, err.Error())
+		default:
+			uc.ResponseHandler.UpdateItemError(w, r, err, "User role")
+		}
+		return
+	}
+
+	uc.Log.Info("User role changed",
+		zap.String("user_id", user.ID.String()),
+		zap.String("actor_id", userInfo.ID.String()),
+		zap.String("from", string(user.PreviousRole)),
+		zap.String("to", string(user.Role)))
+
+	if err := revokeAccessTokens(r.Context(), uc.Revocations, accessTokenLifetime(uc.JWTManager, uc.Settings.ImpersonationDuration), user.ID); err != nil {
+		uc.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	uc.ResponseHandler.Success(w, r, http.StatusOK, "User role updated successfully", user)
+}
+
+// Impersonate func issue a token to act as a user.
+// @Summary      Impersonate User
+// @Description  Issue a short-lived access token to act as the user with the provided ID, e.g. to reproduce a reported bug. Users whose role may impersonate others cannot be impersonated. The token names the admin in its act claim, has no refresh token and cannot change passwords, two-factor authentication or tokens. Send it as a Bearer token.
+// @Tags         Users, Admin
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "User ID"
+// @Success      201  {object}  models.SuccessResponse[models.ImpersonationResponse]
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/{id}/impersonate [post]
+func (uc *UserController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return
+	}
+
+	if parsedId == userInfo.ID {
+		uc.ResponseHandler.CustomError(w, r, http.StatusBadRequest, "Cannot impersonate yourself", nil)
+		return
+	}
+
+	target, ok := uc.authorizeTarget(w, r, parsedId)
+	if !ok {
+		return
+	}
+
+	// Impersonation is for reproducing what users see. Acting as another
+	// admin would put one admin's actions on someone else in the audit log.
+	granted, err := uc.Permissions.Resolve(r.Context(), target.Role)
+	if err != nil {
+		uc.ResponseHandler.PermissionResolutionError(w, r, err)
+		return
+	}
+
+	if slices.Contains(granted, models.PermissionUsersImpersonate) {
+		uc.ResponseHandler.ImpersonationTargetError(w, r)
+		return
+	}
+
+	token, err := uc.JWTManager.GenerateImpersonationToken(target.ID.String(), target.Role, userInfo.ID.String(), uc.Settings.ImpersonationDuration)
+	if err != nil {
+		uc.ResponseHandler.TokenGenerationError(w, r, err)
+		return
+	}
+
+	ipAddress := clientIP(r)
+	if err := uc.DB.CreateAuditLog(r.Context(), &models.AuditLog{
+		ActorID:   &userInfo.ID,
+		Action:    models.AuditUserImpersonated,
+		TargetID:  &target.ID,
+		Details:   models.AuditDetails{"expires_in": int(uc.Settings.ImpersonationDuration.Seconds())},
+		IPAddress: &ipAddress,
+	}); err != nil {
+		uc.ResponseHandler.CreateItemError(w, r, err, "Audit log")
+		return
+	}
+
+	uc.Log.Info("Impersonation started",
+		zap.String("user_id", target.ID.String()),
+		zap.String("actor_id", userInfo.ID.String()))
+
+	uc.ResponseHandler.Success(w, r, http.StatusCreated, "Impersonation token issued", models.ImpersonationResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(uc.Settings.ImpersonationDuration.Seconds()),
+		UserID:      target.ID,
+		ActorID:     userInfo.ID,
+	})
+}
+
+// ForceLogout func revoke every session of a user.
+// @Summary      Force Logout User
+// @Description  Revoke every access token, refresh token and personal access token issued to the user with the provided ID.
+// @Tags         Users, Management
+// @Accept       json
+// @Produce      json
+// @Security     CookieAuth
+// @Security     BearerAuth
+// @Param id	 path string true "User ID"
+// @Success      200  {object}  models.SuccessResponseWithoutData
+// @Failure      400  {object}  models.FailureResponse[string]
+// @Failure      403  {object}  models.FailureResponse[string]
+// @Failure      404  {object}  models.FailureResponse[string]
+// @Failure      500  {object}  models.FailureResponse[string]
+// @Router       /api/users/{id}/logout [post]
+func (uc *UserController) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := uuid.Validate(id); err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	parsedId, err := uuid.Parse(id)
+	if err != nil {
+		uc.ResponseHandler.InvalidIDError(w, r, err)
+		return
+	}
+
+	if _, ok := uc.authorizeTarget(w, r, parsedId); !ok {
+		return
+	}
+
+	if err := revokeUserAccess(r.Context(), uc.DB, uc.Revocations, accessTokenLifetime(uc.JWTManager, uc.Settings.ImpersonationDuration), parsedId); err != nil {
+		uc.ResponseHandler.SessionRevocationError(w, r, err)
+		return
+	}
+
+	uc.ResponseHandler.Success(w, r, http.StatusOK, "User logged out from all devices", nil)
+}
+
+// authorizeTarget loads the user with id and allows the request only if the
+// caller holds every permission of that user's role.
+func (uc *UserController) authorizeTarget(w http.ResponseWriter, r *http.Request, id uuid.UUID) (models.UserResponse, bool) {
+	target, err := uc.DB.GetUser(r.Context(), id)
+	if err != nil {
+		uc.ResponseHandler.NotFoundError(w, r, err, "User")
+		return models.UserResponse{}, false
+	}
+
+	return target, uc.authorizeRole(w, r, target.Role)
+}
+
+// validateUserImport checks every row of an import on its own and against
+// the other rows, and whether the caller may create users of its role.
+func (uc *UserController) validateUserImport(ctx context.Context, userInfo *middlewares.UserInfo, rows []models.ImportUserRow) ([]models.ImportRowError, error) {
+	rowErrors := []models.ImportRowError{}
+	names := map[string]int{}
+	emails := map[string]int{}
+	covers := map[string]bool{}
+
+	for i, row := range rows {
+		messages := []string{}
+
+		if err := uc.Validate.Struct(row); err != nil {
+			messages = append(messages, helpers.ValidatorErrors(err)...)
+		}
+
+		if first, ok := names[row.Name]; ok {
+			messages = append(messages, fmt.Sprintf("Name is the same as in row %d", first+1))
+		} else {
+			names[row.Name] = i
+		}
+
+		if first, ok := emails[row.Email]; ok {
+			messages = append(messages, fmt.Sprintf("Email is the same as in row %d", first+1))
+		} else {
+			emails[row.Email] = i
+		}
+
+		if models.IsValidRole(row.Role) {
+			allowed, ok := covers[row.Role]
+			if !ok {
+				var err error
+				allowed, err = uc.Permissions.Covers(ctx, userInfo.Role, models.UserRole(row.Role))
+				if err != nil {
+					return nil, err
+				}
+				covers[row.Role] = allowed
+			}
+
+			if !allowed {
+				messages = append(messages, fmt.Sprintf("You may not create users with role %s", row.Role))
+			}
+		}
+
+		if len(messages) > 0 {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: i + 1, Errors: messages})
+		}
+	}
+
+	return rowErrors, nil
+}
+
+// sendInvites emails each imported user a link to choose their password.
+func (uc *UserController) sendInvites(ctx context.Context, users []models.CreateUserResponse) {
+	for _, user := range users {
+		link, err := passwordResetLink(ctx, uc.DB, uc.Settings.AppURL, user.ID, uc.Settings.InviteDuration)
+		if err == nil {
+			err = sendWithin(ctx, uc.Mailer, mailer.InviteMessage(user.Email, user.Name, link))
+		}
+
+		if err != nil {
+			uc.Log.Error("Failed to send invite email",
+				zap.String("user_id", user.ID.String()),
+				zap.Error(err))
+		}
+	}
+}
+
+// queueVerificationEmail sends the user a link to verify their new address in
+// the background, so a slow mail server does not hold up the response.
+func (uc *UserController) queueVerificationEmail(ctx context.Context, userID uuid.UUID, name, email string) {
+	inBackground(ctx, func(ctx context.Context) {
+		link, err := verificationLink(uc.JWTManager, uc.Settings.AppURL, userID, email, uc.Settings.EmailVerificationDuration)
+		if err == nil {
+			err = uc.Mailer.Send(ctx, mailer.VerificationMessage(email, name, link))
+		}
+
+		if err != nil {
+			uc.Log.Error("Failed to send verification email",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+		}
+	})
+}
+
+// authorizeDeletedTarget is authorizeTarget for soft deleted users.
+func (uc *UserController) authorizeDeletedTarget(w http.ResponseWriter, r *http.Request, id uuid.UUID) bool {
+	target, err := uc.DB.GetDeletedUser(r.Context(), id)
+	if err != nil {
+		uc.ResponseHandler.NotFoundError(w, r, err, "Deleted user")
+		return false
+	}
+
+	return uc.authorizeRole(w, r, target.Role)
+}
+
+// authorizeRole lets the request manage users of role only if the caller holds
+// every permission of that role, so nobody can create or take over an account
+// more privileged than their own.
+func (uc *UserController) authorizeRole(w http.ResponseWriter, r *http.Request, role models.UserRole) bool {
+	userInfo, ok := middlewares.GetUserFromContext(r.Context())
+	if !ok {
+		uc.ResponseHandler.AuthenticationRequiredError(w, r)
+		return false
+	}
+
+	covers, err := uc.Permissions.Covers(r.Context(), userInfo.Role, role)
+	if err != nil {
+		uc.ResponseHandler.PermissionResolutionError(w, r, err)
+		return false
+	}
+
+	if !covers {
+		uc.ResponseHandler.InsufficientPermissionsError(w, r)
+		return false
+	}
+
+	return true
+}
+
+// projectUser hides the contact details of user from callers that may not
+// read them.
+func projectUser(userInfo *middlewares.UserInfo, user models.UserResponse) any {
+	if policy.Allowed(userInfo, user.ID, viewPrivateProfile) {
+		return user
+	}
+
+	return user.Public()
+}
+
+// highlightUser marks the search terms in the searchable fields of user.
+func highlightUser(user models.UserResponse, terms []string) map[string]string {
+	highlights := map[string]string{}
+
+	for field, value := range map[string]string{
+		"name":      user.Name,
+		"full_name": user.FullName,
+		"email":     user.Email,
+	} {
+		if highlighted, ok := utils.Highlight(value, terms); ok {
+			highlights[field] = highlighted
+		}
+	}
+
+	return highlights
+}
+
+// decodeUserImport reads the rows of an import from a CSV or JSON body.
+func decodeUserImport(w http.ResponseWriter, r *http.Request) ([]models.ImportUserRow, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errors.New("Content-Type must be text/csv or application/json")
+	}
+
+	body := http.MaxBytesReader(w, r.Body, userImportMaxBytes)
+
+	var rows []models.ImportUserRow
+	switch mediaType {
+	case "text/csv":
+		rows, err = helpers.DecodeCSV[models.ImportUserRow](body, userImportMaxRows)
+		if err != nil {
+			return nil, err
+		}
+	case "application/json":
+		decoder := json.NewDecoder(body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		if len(rows) > userImportMaxRows {
+			return nil, fmt.Errorf("at most %d rows can be imported at once", userImportMaxRows)
+		}
+	default:
+		return nil, errors.New("Content-Type must be text/csv or application/json")
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the import contains no users")
+	}
+
+	return rows, nil
+}
+
+// importConflictErrors reports the rows of conflict like validation errors.
+func importConflictErrors(conflict *queries.ImportConflictError) []models.ImportRowError {
+	rowErrors := make([]models.ImportRowError, 0, len(conflict.Conflicts))
+
+	for _, index := range slices.Sorted(maps.Keys(conflict.Conflicts)) {
+		message := "Already taken by an existing user"
+		if column := conflict.Conflicts[index]; column != "" {
+			message = fmt.Sprintf("%s%s is already taken by an existing user", strings.ToUpper(column[:1]), column[1:])
+		}
+
+		rowErrors = append(rowErrors, models.ImportRowError{Row: index + 1, Errors: []string{message}})
+	}
+
+	return rowErrors
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IsValidScope reports whether scope names a permission. Whether the owner
+// of a token may grant it depends on their role.
+func IsValidScope(scope string) bool {
+	return IsValidPermission(scope)
+}
+
+// Scopes is stored as a space separated list, the same way OAuth2 encodes
+// the scope parameter.
+type Scopes []string
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+func (s *Scopes) Scan(src any) error {
+	switch value := src.(type) {
+	case string:
+		*s = strings.Fields(value)
+	case []byte:
+		*s = strings.Fields(string(value))
+	case nil:
+		*s = Scopes{}
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", src)
+	}
+	return nil
+}
+
+type CreateAPITokenRequest struct {
+	Name          string     `json:"name" validate:"required,min=2,max=100"`
+	Scopes        []string   `json:"scopes" validate:"required,min=1,dive,scope"`
+	ExpiresInDays int        `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+	UserID        *uuid.UUID `json:"user_id"`
+}
+
+type CreateAPITokenResponse struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	Name      string     `db:"name" json:"name"`
+	Token     string     `db:"-" json:"token"`
+	Scopes    Scopes     `db:"scopes" json:"scopes"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	CreatedAt string     `db:"created_at" json:"created_at"`
+}
+
+type APITokenResponse struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	UserID      uuid.UUID  `db:"user_id" json:"user_id"`
+	Name        string     `db:"name" json:"name"`
+	TokenPrefix string     `db:"token_prefix" json:"token_prefix"`
+	Scopes      Scopes     `db:"scopes" json:"scopes"`
+	ExpiresAt   *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt   string     `db:"created_at" json:"created_at"`
+}
+
+type APIToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	Role      UserRole   `db:"role"`
+	Scopes    Scopes     `db:"scopes"`
+	ExpiresAt *time.Time `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditUserRegistered   = "user.registered"
+	AuditUserRoleChanged  = "user.role_changed"
+	AuditAdminCreated     = "user.admin_created"
+	AuditUserImpersonated = "user.impersonated"
+	AuditUserDeleted      = "user.deleted"
+	AuditUserRestored     = "user.restored"
+	AuditUserPurged       = "user.purged"
+	AuditUserImported     = "user.imported"
+	// AuditAPITokenCreated is only recorded for tokens created on behalf of
+	// another user.
+	AuditAPITokenCreated = "api_token.created"
+)
+
+// AuditDetails holds the action specific part of an audit log entry and is
+// stored as JSONB.
+type AuditDetails map[string]any
+
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+
+	value, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+func (d *AuditDetails) Scan(src any) error {
+	switch value := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(value), d)
+	case []byte:
+		return json.Unmarshal(value, d)
+	case nil:
+		*d = AuditDetails{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into AuditDetails", src)
+	}
+}
+
+type AuditLog struct {
+	ID        uuid.UUID    `db:"id" json:"id"`
+	ActorID   *uuid.UUID   `db:"actor_id" json:"actor_id"`
+	Action    string       `db:"action" json:"action"`
+	TargetID  *uuid.UUID   `db:"target_id" json:"target_id"`
+	Details   AuditDetails `db:"details" json:"details"`
+	IPAddress *string      `db:"ip_address" json:"ip_address,omitempty"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+}
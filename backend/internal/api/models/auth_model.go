@@ -1,6 +1,10 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required,min=2,max=50,alpha_space"`
@@ -21,12 +25,71 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	ID       uuid.UUID `db:"id" json:"id"`
-	Password string    `db:"password_hash" json:"-"`
-	Email    string    `db:"email" json:"email"`
-	Role     UserRole  `db:"role" json:"role"`
+	ID              uuid.UUID  `db:"id" json:"id"`
+	Password        string     `db:"password_hash" json:"-"`
+	Email           string     `db:"email" json:"email"`
+	Role            UserRole   `db:"role" json:"role"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"-"`
 }
 
 type RoleResponse struct {
 	Role UserRole `json:"role"`
 }
+
+// TokenResponse either carries a new session or, when the account has two
+// factor authentication enabled, only the MFA token to finish the login with.
+type TokenResponse struct {
+	Role         UserRole `json:"role,omitempty"`
+	AccessToken  string   `json:"access_token,omitempty"`
+	TokenType    string   `json:"token_type,omitempty"`
+	ExpiresIn    int      `json:"expires_in,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	MFARequired  bool     `json:"mfa_required,omitempty"`
+	MFAToken     string   `json:"mfa_token,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	FamilyID  uuid.UUID  `db:"family_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=255,password_strength,nefield=CurrentPassword"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=255,password_strength"`
+}
+
+type AccountResponse struct {
+	ID              uuid.UUID  `db:"id" json:"id"`
+	Name            string     `db:"name" json:"name"`
+	Email           string     `db:"email" json:"email"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdentityUser is the account an external identity signs in to.
+type IdentityUser struct {
+	ID              uuid.UUID  `db:"id"`
+	Email           string     `db:"email"`
+	Role            UserRole   `db:"role"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+}
+
+type NewIdentityUser struct {
+	Name         string
+	FullName     string
+	Email        string
+	PasswordHash string
+	Provider     string
+	Subject      string
+}
+
+type OIDCLinkResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ListParams are the paging, sorting and filter options of a list endpoint,
+// parsed and whitelisted by helpers.ParseListParams.
+type ListParams struct {
+	Limit   int
+	Cursor  *Cursor
+	Sort    string
+	Desc    bool
+	Filters map[string]string
+}
+
+// Filter returns the value of a filter, empty when it was not given.
+func (p ListParams) Filter(name string) string {
+	return p.Filters[name]
+}
+
+// SortKey is the sort as given in the query, e.g. "-created_at".
+func (p ListParams) SortKey() string {
+	if p.Desc {
+		return "-" + p.Sort
+	}
+	return p.Sort
+}
+
+// Cursor points after the last item of a page: the value of the sort column
+// and the id, which breaks ties. It is bound to the sort it was issued for.
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	value, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	return &cursor, nil
+}
+
+// Page is one page of a keyset paginated list. NextCursor is nil on the last
+// page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor *Cursor
+	Total      int
+}
+
+type ListMeta struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "slices"
+
+// Permissions are the named capabilities checked by the API. Roles are
+// granted permissions through the role_permissions table, and API tokens
+// carry a subset of them as scopes.
+const (
+	PermissionProfileRead      = "profile:read"
+	PermissionProfileWrite     = "profile:write"
+	PermissionUsersRead        = "users:read"
+	PermissionUsersReadPrivate = "users:read-private"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersManageRoles = "users:manage-roles"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionTokensManage     = "tokens:manage"
+)
+
+var Permissions = []string{
+	PermissionProfileRead,
+	PermissionProfileWrite,
+	PermissionUsersRead,
+	PermissionUsersReadPrivate,
+	PermissionUsersWrite,
+	PermissionUsersDelete,
+	PermissionUsersManageRoles,
+	PermissionUsersImpersonate,
+	PermissionTokensManage,
+}
+
+func IsValidPermission(permission string) bool {
+	return slices.Contains(Permissions, permission)
+}
+
+type RolePermission struct {
+	Role       UserRole `db:"role"`
+	Permission string   `db:"permission"`
+}
@@ -6,6 +6,13 @@ type SuccessResponse[T any] struct {
 	Data    T      `json:"data,omitempty"`
 }
 
+type SuccessListResponse[T any] struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Data    T        `json:"data"`
+	Meta    ListMeta `json:"meta"`
+}
+
 type FailureResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
@@ -15,4 +22,11 @@ type FailureResponse struct {
 type SuccessResponseWithoutData struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
-}
\ No newline at end of file
+}
+
+// FieldError names the request field a failure is about, e.g. the one whose
+// value is already taken.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImportUserRow is one user of a bulk import, read from a JSON object or a CSV
+// row with the same column names. PasswordHash takes a bcrypt hash from another
+// system, users without one are invited by email to choose a password.
+type ImportUserRow struct {
+	Name         string `json:"name" validate:"required,min=2,max=50,alpha_space"`
+	FullName     string `json:"full_name" validate:"max=100"`
+	Email        string `json:"email" validate:"required,email,max=254"`
+	PhoneNumber  string `json:"phone_number" validate:"max=20,phone"`
+	Role         string `json:"role" validate:"required,oneof=USER OWNER"`
+	PasswordHash string `json:"password_hash" validate:"omitempty,bcrypt"`
+
+	// EmailVerified is set for users brought over with their password hash.
+	// They move from a system that already knew their address and get no
+	// email, so the importing admin vouches for the address.
+	EmailVerified bool `json:"-"`
+}
+
+// ImportRowError lists what is wrong with a row of an import. Rows are
+// counted from 1, without the CSV header.
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+type ImportUsersResponse struct {
+	DryRun  bool                 `json:"dry_run"`
+	Created int                  `json:"created"`
+	Invited int                  `json:"invited"`
+	Users   []CreateUserResponse `json:"users,omitempty"`
+}
+
+// ExportUserColumns is the header of a CSV export, in the order of
+// ExportUser.Record.
+var ExportUserColumns = []string{"id", "name", "full_name", "email", "phone_number", "role", "email_verified", "created_at"}
+
+// ExportUser is a user as written to an export. Password hashes are never
+// exported.
+type ExportUser struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	Name          string    `db:"name" json:"name"`
+	FullName      string    `db:"full_name" json:"full_name"`
+	Email         string    `db:"email" json:"email"`
+	PhoneNumber   string    `db:"phone_number" json:"phone_number"`
+	Role          UserRole  `db:"role" json:"role"`
+	EmailVerified bool      `db:"email_verified" json:"email_verified"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// Record returns the CSV fields of the user.
+func (u ExportUser) Record() []string {
+	verified := "false"
+	if u.EmailVerified {
+		verified = "true"
+	}
+
+	return []string{
+		u.ID.String(),
+		csvText(u.Name),
+		csvText(u.FullName),
+		csvText(u.Email),
+		csvText(u.PhoneNumber),
+		string(u.Role),
+		verified,
+		u.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// csvText keeps a user supplied value from being run as a formula when the
+// export is opened in a spreadsheet, by prefixing values that start like one
+// with a quote.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
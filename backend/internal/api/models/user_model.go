@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -12,6 +14,14 @@ const (
 	RoleOwner UserRole = "OWNER"
 )
 
+func IsValidRole(role string) bool {
+	switch UserRole(role) {
+	case RoleAdmin, RoleOwner, RoleUser:
+		return true
+	}
+	return false
+}
+
 type CreateUserRequest struct {
 	Name        string `json:"name" validate:"required,min=2,max=50,alpha_space"`
 	FullName    string `json:"full_name" validate:"max=100"`
@@ -21,6 +31,15 @@ type CreateUserRequest struct {
 	Role        string `json:"role" validate:"required,oneof=USER OWNER"`
 }
 
+// CreateAdminRequest is read by the create-admin command to bootstrap the
+// first ADMIN account.
+type CreateAdminRequest struct {
+	Name     string `json:"name" validate:"required,min=2,max=50,alpha_space"`
+	FullName string `json:"full_name" validate:"max=100"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=8,max=255,password_strength"`
+}
+
 type CreateUserResponse struct {
 	ID          uuid.UUID `db:"id" json:"id"`
 	Name        string    `db:"name" json:"name"`
@@ -38,6 +57,61 @@ type UserResponse struct {
 	Email       string    `db:"email" json:"email"`
 	PhoneNumber string    `db:"phone_number" json:"phone_number"`
 	Role        UserRole  `db:"role" json:"role"`
+	// Version is sent as the ETag header rather than in the body.
+	Version int64 `db:"version" json:"-"`
+}
+
+// PublicUserResponse is the profile of another user as seen by callers that
+// may not read their contact details.
+type PublicUserResponse struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	FullName string    `json:"full_name"`
+	Role     UserRole  `json:"role"`
+}
+
+func (u UserResponse) Public() PublicUserResponse {
+	return PublicUserResponse{
+		ID:       u.ID,
+		Name:     u.Name,
+		FullName: u.FullName,
+		Role:     u.Role,
+	}
+}
+
+// DeletedUserResponse is a soft deleted user, permanently removed at PurgeAt
+// unless restored before.
+type DeletedUserResponse struct {
+	UserResponse
+	DeletedAt time.Time  `db:"deleted_at" json:"deleted_at"`
+	PurgeAt   *time.Time `db:"-" json:"purge_at,omitempty"`
+}
+
+// UserSearchResult is a user matching a search, with the relevance it was
+// ranked by and the matched parts of each field wrapped in <mark> tags.
+type UserSearchResult struct {
+	UserResponse
+	Rank       float64           `db:"rank" json:"rank"`
+	Highlights map[string]string `db:"-" json:"highlights,omitempty"`
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=ADMIN OWNER USER"`
+}
+
+type UserRoleResponse struct {
+	ID           uuid.UUID `db:"id" json:"id"`
+	Role         UserRole  `db:"role" json:"role"`
+	PreviousRole UserRole  `db:"previous_role" json:"previous_role"`
+	UpdatedAt    string    `db:"updated_at" json:"updated_at"`
+}
+
+type ImpersonationResponse struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	UserID      uuid.UUID `json:"user_id"`
+	ActorID     uuid.UUID `json:"actor_id"`
 }
 
 type UpdateUserRequest struct {
@@ -55,4 +129,5 @@ type UpdateUserResponse struct {
 	PhoneNumber string    `db:"phone_number" json:"phone_number,omitempty"`
 	Role        UserRole  `db:"role" json:"role"`
 	UpdatedAt   string    `db:"updated_at" json:"updated_at"`
+	Version     int64     `db:"version" json:"-"`
 }
@@ -0,0 +1,128 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type APITokenQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *APITokenQueries) CreateAPIToken(ctx context.Context, userID uuid.UUID, name, tokenPrefix string, tokenHash []byte, scopes models.Scopes, expiresAt *time.Time) (models.CreateAPITokenResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var token models.CreateAPITokenResponse
+
+	if err := q.QueryRowxContext(ctx,
+		`INSERT INTO api_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING id, name, scopes, expires_at, created_at`,
+		userID,
+		name,
+		tokenPrefix,
+		tokenHash,
+		scopes,
+		expiresAt,
+	).StructScan(&token); err != nil {
+		return models.CreateAPITokenResponse{}, err
+	}
+
+	return token, nil
+}
+
+func (q *APITokenQueries) GetAPITokens(ctx context.Context, userID uuid.UUID) ([]models.APITokenResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tokens := []models.APITokenResponse{}
+
+	if err := q.SelectContext(ctx, &tokens,
+		`SELECT id, user_id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+         FROM api_tokens
+         WHERE user_id = $1 AND revoked_at IS NULL
+         ORDER BY created_at DESC`,
+		userID,
+	); err != nil {
+		return []models.APITokenResponse{}, err
+	}
+
+	return tokens, nil
+}
+
+// GetAPITokenByHash returns the token together with the role of its owner.
+// Tokens of deleted users are never returned.
+func (q *APITokenQueries) GetAPITokenByHash(ctx context.Context, tokenHash []byte) (models.APIToken, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var token models.APIToken
+
+	if err := q.GetContext(ctx, &token,
+		`SELECT t.id, t.user_id, u.role, t.scopes, t.expires_at, t.revoked_at
+         FROM api_tokens t
+         JOIN users u ON u.id = t.user_id
+         WHERE t.token_hash = $1 AND u.deleted_at IS NULL`,
+		tokenHash,
+	); err != nil {
+		return models.APIToken{}, err
+	}
+
+	return token, nil
+}
+
+// TouchAPIToken records the last use of a token, at most once per minute to
+// keep authenticated requests from writing on every call.
+func (q *APITokenQueries) TouchAPIToken(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx,
+		`UPDATE api_tokens SET last_used_at = NOW()
+         WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`,
+		id,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (q *APITokenQueries) RevokeAPIToken(ctx context.Context, id, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	result, err := q.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RevokeUserAPITokens revokes every personal access token of the user.
+func (q *APITokenQueries) RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx, `UPDATE api_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
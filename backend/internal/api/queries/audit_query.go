@@ -0,0 +1,37 @@
+package queries
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type AuditQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *AuditQueries) CreateAuditLog(ctx context.Context, entry *models.AuditLog) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	return insertAuditLog(ctx, q, entry)
+}
+
+// insertAuditLog writes entry with execer, so changes can be recorded inside
+// the transaction that makes them.
+func insertAuditLog(ctx context.Context, execer sqlx.ExecerContext, entry *models.AuditLog) error {
+	if _, err := execer.ExecContext(ctx,
+		`INSERT INTO audit_logs (actor_id, action, target_id, details, ip_address)
+         VALUES ($1, $2, $3, $4, $5)`,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetID,
+		entry.Details,
+		entry.IPAddress,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
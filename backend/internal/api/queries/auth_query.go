@@ -1,18 +1,25 @@
 package queries
 
 import (
-	"github.com/jmoiron/sqlx"
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
 )
 
 type AuthQueries struct {
-	*sqlx.DB
+	DB
+	Timeouts Timeouts
 }
 
-func (q *AuthQueries) Register(u *models.RegisterRequest) (models.RegisterResponse, error) {
+func (q *AuthQueries) Register(ctx context.Context, u *models.RegisterRequest) (models.RegisterResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
 	var user models.RegisterResponse
 
-	if err := q.QueryRowx(
+	if err := q.QueryRowxContext(ctx,
 		`INSERT INTO users (name, email, password_hash, role)
          VALUES ($1, $2, $3, 'USER')
          RETURNING id, name, email, created_at`,
@@ -26,12 +33,83 @@ func (q *AuthQueries) Register(u *models.RegisterRequest) (models.RegisterRespon
 	return user, nil
 }
 
-func (q *AuthQueries) Login(email string) (models.LoginResponse, error) {
+func (q *AuthQueries) Login(ctx context.Context, email string) (models.LoginResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
 	var user models.LoginResponse
 
-	if err := q.Get(&user, `SELECT id, password_hash, email, role FROM users WHERE email = $1 AND deleted_at IS NULL`, email); err != nil {
+	if err := q.GetContext(ctx, &user, `SELECT id, password_hash, email, role, email_verified_at FROM users WHERE email = $1 AND deleted_at IS NULL`, email); err != nil {
 		return models.LoginResponse{}, err
 	}
 
 	return user, nil
 }
+
+func (q *AuthQueries) GetAccountByEmail(ctx context.Context, email string) (models.AccountResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var account models.AccountResponse
+
+	if err := q.GetContext(ctx, &account, `SELECT id, name, email, email_verified_at FROM users WHERE email = $1 AND deleted_at IS NULL`, email); err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	return account, nil
+}
+
+// VerifyEmail marks the address as verified as long as it still belongs to the
+// user. Verifying an already verified address keeps the original timestamp.
+func (q *AuthQueries) VerifyEmail(ctx context.Context, id uuid.UUID, email string) (models.AccountResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var account models.AccountResponse
+
+	if err := q.QueryRowxContext(ctx,
+		`UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+         WHERE id = $1 AND email = $2 AND deleted_at IS NULL
+         RETURNING id, name, email, email_verified_at`,
+		id,
+		email,
+	).StructScan(&account); err != nil {
+		return models.AccountResponse{}, err
+	}
+
+	return account, nil
+}
+
+func (q *AuthQueries) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var passwordHash string
+
+	if err := q.GetContext(ctx, &passwordHash, `SELECT password_hash FROM users WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+		return "", err
+	}
+
+	return passwordHash, nil
+}
+
+func (q *AuthQueries) UpdatePassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	result, err := q.ExecContext(ctx, `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
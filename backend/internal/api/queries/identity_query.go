@@ -0,0 +1,123 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type IdentityQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+// LoginWithIdentity returns the account linked to the external subject and
+// records the login.
+func (q *IdentityQueries) LoginWithIdentity(ctx context.Context, provider, subject string) (models.IdentityUser, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var user models.IdentityUser
+
+	if err := q.QueryRowxContext(ctx,
+		`UPDATE user_identities i SET last_login_at = NOW()
+         FROM users u
+         WHERE i.provider = $1 AND i.subject = $2 AND u.id = i.user_id AND u.deleted_at IS NULL
+         RETURNING u.id, u.email, u.role, u.email_verified_at`,
+		provider,
+		subject,
+	).StructScan(&user); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return user, nil
+}
+
+func (q *IdentityQueries) GetIdentityUserByEmail(ctx context.Context, email string) (models.IdentityUser, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var user models.IdentityUser
+
+	if err := q.GetContext(ctx, &user, `SELECT id, email, role, email_verified_at FROM users WHERE email = $1 AND deleted_at IS NULL`, email); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return user, nil
+}
+
+func (q *IdentityQueries) GetIdentityUser(ctx context.Context, id uuid.UUID) (models.IdentityUser, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var user models.IdentityUser
+
+	if err := q.GetContext(ctx, &user, `SELECT id, email, role, email_verified_at FROM users WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	return user, nil
+}
+
+func (q *IdentityQueries) CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+         VALUES ($1, $2, $3, $4, NOW())`,
+		userID,
+		provider,
+		subject,
+		email,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity registers a user whose email address was verified by
+// the provider, together with the identity they signed in with.
+func (q *IdentityQueries) CreateUserWithIdentity(ctx context.Context, u *models.NewIdentityUser) (models.IdentityUser, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return models.IdentityUser{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user models.IdentityUser
+	if err := tx.QueryRowxContext(ctx,
+		`INSERT INTO users (name, full_name, email, password_hash, phone_number, role, email_verified_at)
+         VALUES ($1, $2, $3, $4, '', 'USER', NOW())
+         RETURNING id, email, role, email_verified_at`,
+		u.Name,
+		u.FullName,
+		u.Email,
+		u.PasswordHash,
+	).StructScan(&user); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+         VALUES ($1, $2, $3, $4, NOW())`,
+		user.ID,
+		u.Provider,
+		u.Subject,
+		u.Email,
+	); err != nil {
+		return models.IdentityUser{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.IdentityUser{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,126 @@
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// sortColumn is the column behind a whitelisted sort field and the type its
+// cursor value, sent as text, is cast back to.
+type sortColumn struct {
+	column string
+	cast   string
+}
+
+// value is the column as the text of a cursor. Timestamps are written in RFC
+// 3339 in UTC rather than in the format of the session's DateStyle, so they
+// can be checked before they are sent back.
+func (s sortColumn) value() string {
+	if s.cast == "timestamptz" {
+		return fmt.Sprintf(`to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`, s.column)
+	}
+	return s.column + "::text"
+}
+
+// sortTypes maps each sort field of columns to the type its cursor value is
+// cast back to.
+func sortTypes(columns map[string]sortColumn) map[string]string {
+	types := map[string]string{}
+	for field, column := range columns {
+		types[field] = column.cast
+	}
+	return types
+}
+
+// listQuery collects the conditions and arguments of a keyset paginated list.
+type listQuery struct {
+	conditions []string
+	args       []any
+}
+
+// arg adds an argument and returns its placeholder, for values used more than
+// once in a query.
+func (l *listQuery) arg(value any) string {
+	l.args = append(l.args, value)
+	return fmt.Sprintf("$%d", len(l.args))
+}
+
+// where adds a condition. Each %s in condition is replaced with the
+// placeholder of the matching argument, a condition without arguments is
+// added as is.
+func (l *listQuery) where(condition string, args ...any) {
+	if len(args) == 0 {
+		l.conditions = append(l.conditions, condition)
+		return
+	}
+
+	placeholders := make([]any, len(args))
+	for i, arg := range args {
+		placeholders[i] = l.arg(arg)
+	}
+
+	l.conditions = append(l.conditions, fmt.Sprintf(condition, placeholders...))
+}
+
+func (l *listQuery) whereClause() string {
+	if len(l.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(l.conditions, " AND ")
+}
+
+// page adds the cursor condition and returns the ORDER BY and LIMIT clauses.
+// One row more than the limit is fetched to tell whether a next page exists.
+func (l *listQuery) page(params models.ListParams, sort sortColumn) string {
+	direction, comparison := "ASC", ">"
+	if params.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if params.Cursor != nil {
+		value := "%s::text"
+		if sort.cast != "text" {
+			value += "::" + sort.cast
+		}
+		l.where(fmt.Sprintf("(%s, id) %s (%s, %%s::uuid)", sort.column, comparison, value), params.Cursor.Value, params.Cursor.ID)
+	}
+
+	l.args = append(l.args, params.Limit+1)
+	return fmt.Sprintf("%s ORDER BY %s %s, id %s LIMIT $%d", l.whereClause(), sort.column, direction, direction, len(l.args))
+}
+
+// containsPattern turns search input into an ILIKE pattern matching it
+// anywhere, with the wildcards of the input escaped.
+func containsPattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// newPage trims the extra row fetched by listQuery.page and builds the cursor
+// of the next page from the last row. split returns the item of a row and the
+// cursor pointing at it.
+func newPage[R, T any](params models.ListParams, rows []R, total int, split func(R) (T, models.Cursor)) models.Page[T] {
+	page := models.Page[T]{
+		Items: make([]T, 0, len(rows)),
+		Total: total,
+	}
+
+	hasNext := len(rows) > params.Limit
+	if hasNext {
+		rows = rows[:params.Limit]
+	}
+
+	for i, row := range rows {
+		item, cursor := split(row)
+		page.Items = append(page.Items, item)
+
+		if hasNext && i == len(rows)-1 {
+			cursor.Sort = params.SortKey()
+			page.NextCursor = &cursor
+		}
+	}
+
+	return page
+}
@@ -0,0 +1,206 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type MFAQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *MFAQueries) GetMFA(ctx context.Context, userID uuid.UUID) (models.UserMFA, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var mfa models.UserMFA
+
+	if err := q.GetContext(ctx, &mfa, `SELECT user_id, secret_encrypted, confirmed_at, last_used_step FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return models.UserMFA{}, err
+	}
+
+	return mfa, nil
+}
+
+// EnrollMFA stores a new pending secret, replacing an earlier enrollment that
+// was never confirmed. It reports false when two factor authentication is
+// already enabled for the user.
+func (q *MFAQueries) EnrollMFA(ctx context.Context, userID uuid.UUID, secretEncrypted []byte) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	result, err := q.ExecContext(ctx,
+		`INSERT INTO user_mfa (user_id, secret_encrypted)
+         VALUES ($1, $2)
+         ON CONFLICT (user_id) DO UPDATE
+         SET secret_encrypted = EXCLUDED.secret_encrypted, last_used_step = 0, created_at = NOW()
+         WHERE user_mfa.confirmed_at IS NULL`,
+		userID,
+		secretEncrypted,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ConfirmMFA enables a pending enrollment and replaces the recovery codes of
+// the user. It reports false when there was no pending enrollment.
+func (q *MFAQueries) ConfirmMFA(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE user_mfa SET confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return false, err
+	}
+
+	if err := insertRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It reports false when
+// the same or a later step was used before, so a code cannot be replayed.
+func (q *MFAQueries) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	result, err := q.ExecContext(ctx, `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (q *MFAQueries) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash []byte) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	result, err := q.ExecContext(ctx, `UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (q *MFAQueries) CountRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var count int
+
+	if err := q.GetContext(ctx, &count, `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (q *MFAQueries) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodeHashes [][]byte) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if err := insertRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (q *MFAQueries) DisableMFA(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func insertRecoveryCodes(ctx context.Context, execer sqlx.ExecerContext, userID uuid.UUID, recoveryCodeHashes [][]byte) error {
+	for _, hash := range recoveryCodeHashes {
+		if _, err := execer.ExecContext(ctx, `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *PasswordResetQueries) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash []byte, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx,
+		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+         VALUES ($1, $2, $3)`,
+		userID,
+		tokenHash,
+		expiresAt,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword consumes an unused, unexpired token and stores the new
+// password of its owner. Every other outstanding token of the user is
+// invalidated as well. It returns sql.ErrNoRows when the token is unusable.
+func (q *PasswordResetQueries) ResetPassword(ctx context.Context, tokenHash []byte, passwordHash string) (uuid.UUID, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	if err := tx.GetContext(ctx, &userID,
+		`SELECT user_id FROM password_reset_tokens
+         WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+         FOR UPDATE`,
+		tokenHash,
+	); err != nil {
+		return uuid.Nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = $2, updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, userID, passwordHash)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if rowsAffected == 0 {
+		return uuid.Nil, sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,29 @@
+package queries
+
+import (
+	"context"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type PermissionQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *PermissionQueries) GetRolePermissions(ctx context.Context) (map[models.UserRole][]string, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	rows := []models.RolePermission{}
+
+	if err := q.SelectContext(ctx, &rows, `SELECT role, permission FROM role_permissions ORDER BY role, permission`); err != nil {
+		return nil, err
+	}
+
+	permissions := map[models.UserRole][]string{}
+	for _, row := range rows {
+		permissions[row.Role] = append(permissions[row.Role], row.Permission)
+	}
+
+	return permissions, nil
+}
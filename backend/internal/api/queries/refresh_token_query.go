@@ -0,0 +1,113 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type RefreshTokenQueries struct {
+	DB
+	Timeouts Timeouts
+}
+
+func (q *RefreshTokenQueries) CreateRefreshToken(ctx context.Context, userID, familyID uuid.UUID, tokenHash []byte, expiresAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+         VALUES ($1, $2, $3, $4)`,
+		userID,
+		familyID,
+		tokenHash,
+		expiresAt,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (q *RefreshTokenQueries) GetRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var token models.RefreshToken
+
+	if err := q.GetContext(ctx, &token, `SELECT id, user_id, family_id, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = $1`, tokenHash); err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken marks the given token as used and stores its successor in
+// the same family. It reports false when the token had already been used or
+// revoked, which callers must treat as a replay.
+func (q *RefreshTokenQueries) RotateRefreshToken(ctx context.Context, current models.RefreshToken, tokenHash []byte, expiresAt time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`, current.ID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+         VALUES ($1, $2, $3, $4)`,
+		current.UserID,
+		current.FamilyID,
+		tokenHash,
+		expiresAt,
+	); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+func (q *RefreshTokenQueries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, familyID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (q *RefreshTokenQueries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	if _, err := q.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package queries
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts are the deadlines applied to queries on top of the context of the
+// caller, so a slow database cannot hold a pool connection forever. A zero
+// duration leaves the deadline to the caller.
+type Timeouts struct {
+	// Query bounds single lookups and changes, including their transaction.
+	Query time.Duration
+	// List bounds paginated lists and searches together with their count.
+	List time.Duration
+	// Bulk bounds imports and streamed exports of many rows.
+	Bulk time.Duration
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,95 @@
+package queries
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DB is what the query types run their statements on: the connection pool,
+// or a Tx when they take part in a unit of work.
+type DB interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// Tx is a transaction the query types can run on. Transactions begun on a Tx
+// are savepoints of it, so queries that need a transaction of their own still
+// commit or roll back together with the unit of work around them.
+type Tx struct {
+	*sqlx.Tx
+
+	savepoint  string
+	savepoints *int
+	done       bool
+}
+
+var (
+	_ DB = (*sqlx.DB)(nil)
+	_ DB = (*Tx)(nil)
+)
+
+// BeginTx starts a transaction on db, or a savepoint when db is already a Tx.
+// opts only apply to new transactions, a savepoint runs with the isolation
+// level of the transaction it belongs to.
+func BeginTx(ctx context.Context, db DB, opts *sql.TxOptions) (*Tx, error) {
+	switch db := db.(type) {
+	case *sqlx.DB:
+		tx, err := db.BeginTxx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &Tx{Tx: tx, savepoints: new(int)}, nil
+	case *Tx:
+		return db.begin(ctx)
+	default:
+		return nil, fmt.Errorf("cannot begin a transaction on %T", db)
+	}
+}
+
+func (t *Tx) begin(ctx context.Context) (*Tx, error) {
+	*t.savepoints++
+	name := fmt.Sprintf("sp_%d", *t.savepoints)
+
+	if _, err := t.Tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+
+	return &Tx{Tx: t.Tx, savepoint: name, savepoints: t.savepoints}, nil
+}
+
+// Commit commits the transaction, or releases the savepoint so its changes
+// become part of the enclosing transaction.
+func (t *Tx) Commit() error {
+	if t.savepoint == "" {
+		return t.Tx.Commit()
+	}
+
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	_, err := t.Tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
+}
+
+// Rollback aborts the transaction, or undoes the changes made since the
+// savepoint. Like sql.Tx, it returns sql.ErrTxDone once committed, so it can
+// be deferred right after BeginTx.
+func (t *Tx) Rollback() error {
+	if t.savepoint == "" {
+		return t.Tx.Rollback()
+	}
+
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	_, err := t.Tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint)
+	return err
+}
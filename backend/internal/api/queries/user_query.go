@@ -1,22 +1,74 @@
 package queries
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+	"github.com/otterly-id/otterly/backend/internal/utils"
 )
 
+var (
+	ErrRoleUnchanged = errors.New("user already has this role")
+	ErrLastAdmin     = errors.New("the last admin cannot be demoted or deleted")
+	ErrAdminExists   = errors.New("an admin already exists")
+
+	// ErrVersionMismatch is returned by conditional updates when the row has
+	// been changed since the version the caller read.
+	ErrVersionMismatch = errors.New("the version does not match")
+)
+
+// roleChangeLock serializes role changes, so two admins demoting each other at
+// the same time cannot leave the system without one.
+const roleChangeLock = `SELECT pg_advisory_xact_lock(hashtext('users.role'))`
+
+// guardLastAdmin takes roleChangeLock and fails with ErrLastAdmin when the
+// active user with id is the only admin left. Every change that can take away
+// an admin calls it in its transaction before making the change.
+func guardLastAdmin(ctx context.Context, tx *Tx, id uuid.UUID) error {
+	if _, err := tx.ExecContext(ctx, roleChangeLock); err != nil {
+		return err
+	}
+
+	var counts struct {
+		Target int `db:"target"`
+		Admins int `db:"admins"`
+	}
+	if err := tx.GetContext(ctx, &counts,
+		`SELECT COUNT(*) FILTER (WHERE id = $1) AS target, COUNT(*) AS admins
+         FROM users WHERE role = 'ADMIN' AND deleted_at IS NULL`,
+		id,
+	); err != nil {
+		return err
+	}
+
+	if counts.Target > 0 && counts.Admins <= 1 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
 type UserQueries struct {
-	*sqlx.DB
+	DB
+	Timeouts Timeouts
 }
 
-func (q *UserQueries) CreateUser(u *models.CreateUserRequest) (models.CreateUserResponse, error) {
+func (q *UserQueries) CreateUser(ctx context.Context, u *models.CreateUserRequest) (models.CreateUserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
 	var user models.CreateUserResponse
 
-	if err := q.QueryRowx(
+	if err := q.QueryRowxContext(ctx,
 		`INSERT INTO users (name, full_name, email, password_hash, phone_number, role)
          VALUES ($1, $2, $3, $4, $5, $6)
          RETURNING id, name, full_name, email, phone_number, role, created_at`,
@@ -33,27 +85,358 @@ func (q *UserQueries) CreateUser(u *models.CreateUserRequest) (models.CreateUser
 	return user, nil
 }
 
-func (q *UserQueries) GetUsers() ([]models.UserResponse, error) {
-	var user []models.UserResponse
+// ImportConflictError lists the rows of an import, by index, whose name or
+// email address is already taken, with the taken column.
+type ImportConflictError struct {
+	Conflicts map[int]string
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%d rows conflict with existing users", len(e.Conflicts))
+}
+
+// ImportUsers creates users in a single transaction and records each one in
+// the audit log, based on audit. Every row is tried, rows clashing with
+// existing users or earlier rows are returned in an ImportConflictError and
+// then nothing is kept. With dryRun the transaction is rolled back anyway.
+func (q *UserQueries) ImportUsers(ctx context.Context, rows []models.ImportUserRow, dryRun bool, audit models.AuditLog) ([]models.CreateUserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Bulk)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	users := make([]models.CreateUserResponse, 0, len(rows))
+	conflicts := map[int]string{}
+
+	for i, row := range rows {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+			return nil, err
+		}
+
+		var user models.CreateUserResponse
+		err := tx.QueryRowxContext(ctx,
+			`INSERT INTO users (name, full_name, email, password_hash, phone_number, role, email_verified_at)
+             VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $7::boolean THEN NOW() END)
+             RETURNING id, name, full_name, email, phone_number, role, created_at`,
+			row.Name,
+			row.FullName,
+			row.Email,
+			row.PasswordHash,
+			row.PhoneNumber,
+			row.Role,
+			row.EmailVerified,
+		).StructScan(&user)
+
+		if conflict, ok := dberrors.As(err); ok && conflict.Kind == dberrors.ErrUniqueViolation {
+			conflicts[i] = conflict.Field
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := audit
+		entry.TargetID = &user.ID
+		if err := insertAuditLog(ctx, tx, &entry); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &ImportConflictError{Conflicts: conflicts}
+	}
+
+	if dryRun {
+		return users, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return users, nil
+}
+
+// ExportUsers passes every active user matching the role, email and q filters
+// of params to fn, oldest first, reading them one by one so exports of any
+// size use constant memory. It stops at the first error of fn.
+func (q *UserQueries) ExportUsers(ctx context.Context, params models.ListParams, fn func(models.ExportUser) error) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Bulk)
+	defer cancel()
+
+	list := &listQuery{}
+	list.where("deleted_at IS NULL")
+	filterUsers(list, params)
+
+	rows, err := q.QueryxContext(ctx,
+		`SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role,
+                email_verified_at IS NOT NULL AS email_verified, created_at
+         FROM users `+list.whereClause()+` ORDER BY created_at, id`,
+		list.args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.ExportUser
+		if err := rows.StructScan(&user); err != nil {
+			return err
+		}
+
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// userSortColumns maps the sort fields accepted by GET /api/users to columns.
+var userSortColumns = map[string]sortColumn{
+	"name":       {column: "name", cast: "text"},
+	"email":      {column: "email", cast: "text"},
+	"created_at": {column: "created_at", cast: "timestamptz"},
+}
+
+// UserSortFields lists the fields users can be sorted by.
+var UserSortFields = slices.Sorted(maps.Keys(userSortColumns))
+
+// UserSortTypes maps the fields users can be sorted by to their types.
+var UserSortTypes = sortTypes(userSortColumns)
+
+// userRow is a user together with its sort column as text, for the cursor.
+type userRow struct {
+	models.UserResponse
+	SortValue string `db:"sort_value"`
+}
+
+// GetUsers returns a page of users matching the role, email and q filters of
+// params, ordered by a field of UserSortFields.
+func (q *UserQueries) GetUsers(ctx context.Context, params models.ListParams) (models.Page[models.UserResponse], error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.List)
+	defer cancel()
+
+	sort, ok := userSortColumns[params.Sort]
+	if !ok {
+		return models.Page[models.UserResponse]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	list := &listQuery{}
+	list.where("deleted_at IS NULL")
+	filterUsers(list, params)
+
+	var total int
+	if err := q.GetContext(ctx, &total, `SELECT COUNT(*) FROM users `+list.whereClause(), list.args...); err != nil {
+		return models.Page[models.UserResponse]{}, err
+	}
+
+	rows := []userRow{}
+	if err := q.SelectContext(ctx, &rows,
+		fmt.Sprintf(`SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, %s AS sort_value FROM users `, sort.value())+list.page(params, sort),
+		list.args...,
+	); err != nil {
+		return models.Page[models.UserResponse]{}, err
+	}
+
+	return newPage(params, rows, total, func(row userRow) (models.UserResponse, models.Cursor) {
+		return row.UserResponse, models.Cursor{Value: row.SortValue, ID: row.ID}
+	}), nil
+}
+
+// filterUsers adds the role, email and q filters of params to list.
+func filterUsers(list *listQuery, params models.ListParams) {
+	if role := params.Filter("role"); role != "" {
+		list.where("role = %s", role)
+	}
+
+	if email := params.Filter("email"); email != "" {
+		list.where("LOWER(email) = LOWER(%s)", email)
+	}
+
+	if search := params.Filter("q"); search != "" {
+		pattern := containsPattern(search)
+		list.where("(name ILIKE %s OR full_name ILIKE %s)", pattern, pattern)
+	}
+}
+
+// deletedUserSortColumns maps the sort fields accepted by GET
+// /api/users/deleted to columns.
+var deletedUserSortColumns = map[string]sortColumn{
+	"name":       {column: "name", cast: "text"},
+	"email":      {column: "email", cast: "text"},
+	"deleted_at": {column: "deleted_at", cast: "timestamptz"},
+}
+
+// DeletedUserSortFields lists the fields deleted users can be sorted by.
+var DeletedUserSortFields = slices.Sorted(maps.Keys(deletedUserSortColumns))
+
+// DeletedUserSortTypes maps the fields deleted users can be sorted by to their
+// types.
+var DeletedUserSortTypes = sortTypes(deletedUserSortColumns)
+
+type deletedUserRow struct {
+	models.DeletedUserResponse
+	SortValue string `db:"sort_value"`
+}
+
+// GetDeletedUsers returns a page of soft deleted users matching the role,
+// email and q filters of params, ordered by a field of DeletedUserSortFields.
+func (q *UserQueries) GetDeletedUsers(ctx context.Context, params models.ListParams) (models.Page[models.DeletedUserResponse], error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.List)
+	defer cancel()
+
+	sort, ok := deletedUserSortColumns[params.Sort]
+	if !ok {
+		return models.Page[models.DeletedUserResponse]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	list := &listQuery{}
+	list.where("deleted_at IS NOT NULL")
+	filterUsers(list, params)
+
+	var total int
+	if err := q.GetContext(ctx, &total, `SELECT COUNT(*) FROM users `+list.whereClause(), list.args...); err != nil {
+		return models.Page[models.DeletedUserResponse]{}, err
+	}
 
-	if err := q.Select(&user, `SELECT id, name, full_name, email, phone_number, role FROM users WHERE deleted_at IS NULL`); err != nil {
-		return []models.UserResponse{}, err
+	rows := []deletedUserRow{}
+	if err := q.SelectContext(ctx, &rows,
+		fmt.Sprintf(`SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, deleted_at, %s AS sort_value FROM users `, sort.value())+list.page(params, sort),
+		list.args...,
+	); err != nil {
+		return models.Page[models.DeletedUserResponse]{}, err
+	}
+
+	return newPage(params, rows, total, func(row deletedUserRow) (models.DeletedUserResponse, models.Cursor) {
+		return row.DeletedUserResponse, models.Cursor{Value: row.SortValue, ID: row.ID}
+	}), nil
+}
+
+// GetDeletedUser returns a soft deleted user.
+func (q *UserQueries) GetDeletedUser(ctx context.Context, id uuid.UUID) (models.DeletedUserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	var user models.DeletedUserResponse
+
+	if err := q.GetContext(ctx, &user, `SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, deleted_at FROM users WHERE id = $1 AND deleted_at IS NOT NULL`, id); err != nil {
+		return models.DeletedUserResponse{}, err
 	}
 
 	return user, nil
 }
 
-func (q *UserQueries) GetUser(id uuid.UUID) (models.UserResponse, error) {
+// userSearchSortColumns maps the sort fields accepted by the user search to
+// columns of the ranked subquery.
+var userSearchSortColumns = map[string]sortColumn{
+	"relevance": {column: "rank", cast: "float8"},
+}
+
+// UserSearchSortFields lists the fields search results can be sorted by.
+var UserSearchSortFields = slices.Sorted(maps.Keys(userSearchSortColumns))
+
+// UserSearchSortTypes maps the fields search results can be sorted by to their
+// types.
+var UserSearchSortTypes = sortTypes(userSearchSortColumns)
+
+type userSearchRow struct {
+	models.UserSearchResult
+	SortValue string `db:"sort_value"`
+}
+
+// SearchUsers ranks users matching search in their name, full name or email.
+// Whole and prefix words are found through the search_vector document,
+// partial and misspelled input through trigram similarity.
+func (q *UserQueries) SearchUsers(ctx context.Context, search string, params models.ListParams) (models.Page[models.UserSearchResult], error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.List)
+	defer cancel()
+
+	sort, ok := userSearchSortColumns[params.Sort]
+	if !ok {
+		return models.Page[models.UserSearchResult]{}, fmt.Errorf("unknown sort field %q", params.Sort)
+	}
+
+	prefixes := []string{}
+	for _, term := range utils.SearchTerms(search) {
+		prefixes = append(prefixes, term+":*")
+	}
+
+	ranked := &listQuery{}
+	ranked.where("deleted_at IS NULL")
+
+	if role := params.Filter("role"); role != "" {
+		ranked.where("role = %s", role)
+	}
+
+	text := ranked.arg(search)
+	pattern := ranked.arg(containsPattern(search))
+	tsquery := fmt.Sprintf("to_tsquery('simple', %s)", ranked.arg(strings.Join(prefixes, " & ")))
+
+	ranked.where(fmt.Sprintf(
+		`(search_vector @@ %[1]s
+          OR name ILIKE %[2]s OR full_name ILIKE %[2]s OR email ILIKE %[2]s
+          OR name %% %[3]s OR full_name %% %[3]s OR email %% %[3]s)`,
+		tsquery, pattern, text))
+
+	var total int
+	if err := q.GetContext(ctx, &total, `SELECT COUNT(*) FROM users `+ranked.whereClause(), ranked.args...); err != nil {
+		return models.Page[models.UserSearchResult]{}, err
+	}
+
+	rank := fmt.Sprintf(
+		`(ts_rank(search_vector, %s) + GREATEST(similarity(name, %[2]s), similarity(COALESCE(full_name, ''), %[2]s), similarity(email, %[2]s)))::float8`,
+		tsquery, text)
+
+	outer := &listQuery{args: ranked.args}
+	rows := []userSearchRow{}
+	if err := q.SelectContext(ctx, &rows,
+		fmt.Sprintf(
+			`SELECT id, name, full_name, email, phone_number, role, rank, %s AS sort_value FROM (
+             SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, %s AS rank
+             FROM users %s
+         ) ranked `,
+			sort.value(), rank, ranked.whereClause())+outer.page(params, sort),
+		outer.args...,
+	); err != nil {
+		return models.Page[models.UserSearchResult]{}, err
+	}
+
+	return newPage(params, rows, total, func(row userSearchRow) (models.UserSearchResult, models.Cursor) {
+		return row.UserSearchResult, models.Cursor{Value: row.SortValue, ID: row.ID}
+	}), nil
+}
+
+func (q *UserQueries) GetUser(ctx context.Context, id uuid.UUID) (models.UserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
 	var user models.UserResponse
 
-	if err := q.Get(&user, `SELECT id, name, full_name, email, phone_number, role FROM users WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+	if err := q.GetContext(ctx, &user, `SELECT id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, version FROM users WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
 		return models.UserResponse{}, err
 	}
 
 	return user, nil
 }
 
-func (q *UserQueries) UpdateUser(id uuid.UUID, u *models.UpdateUserRequest) (models.UpdateUserResponse, error) {
+// UpdateUser changes the given fields of a user. With a version, the update
+// only applies while the user is still at that version and fails with
+// ErrVersionMismatch otherwise.
+func (q *UserQueries) UpdateUser(ctx context.Context, id uuid.UUID, u *models.UpdateUserRequest, version *int64) (models.UpdateUserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
 	setParts := []string{}
 	args := []interface{}{id}
 	argIndex := 2
@@ -71,7 +454,9 @@ func (q *UserQueries) UpdateUser(id uuid.UUID, u *models.UpdateUserRequest) (mod
 	}
 
 	if u.Email != nil && *u.Email != "" {
+		// A new address has to be verified again.
 		setParts = append(setParts, fmt.Sprintf("email = $%d", argIndex))
+		setParts = append(setParts, fmt.Sprintf("email_verified_at = CASE WHEN email = $%d THEN email_verified_at END", argIndex))
 		args = append(args, *u.Email)
 		argIndex++
 	}
@@ -79,6 +464,7 @@ func (q *UserQueries) UpdateUser(id uuid.UUID, u *models.UpdateUserRequest) (mod
 	if u.PhoneNumber != nil && *u.PhoneNumber != "" {
 		setParts = append(setParts, fmt.Sprintf("phone_number = $%d", argIndex))
 		args = append(args, *u.PhoneNumber)
+		argIndex++
 	}
 
 	if len(setParts) == 0 {
@@ -87,24 +473,311 @@ func (q *UserQueries) UpdateUser(id uuid.UUID, u *models.UpdateUserRequest) (mod
 
 	setParts = append(setParts, "updated_at = NOW()")
 
+	condition := "id = $1 AND deleted_at IS NULL"
+	if version != nil {
+		condition += fmt.Sprintf(" AND version = $%d", argIndex)
+		args = append(args, *version)
+	}
+
 	query := fmt.Sprintf(
 		`UPDATE users SET %s
-		 WHERE id = $1 AND deleted_at IS NULL
-		 RETURNING id, name, full_name, email, phone_number, role, updated_at`,
-		strings.Join(setParts, ", "))
+		 WHERE %s
+		 RETURNING id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role, updated_at, version`,
+		strings.Join(setParts, ", "), condition)
 
 	var user models.UpdateUserResponse
-	if err := q.QueryRowx(query, args...).StructScan(&user); err != nil {
+	if err := q.QueryRowxContext(ctx, query, args...).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) && version != nil {
+			return models.UpdateUserResponse{}, q.versionMismatch(ctx, id)
+		}
 		return models.UpdateUserResponse{}, err
 	}
 
 	return user, nil
 }
 
-func (q *UserQueries) DeleteUser(id uuid.UUID) error {
-	if _, err := q.Exec(`UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+// versionMismatch tells why a versioned update of an active user matched no
+// row: ErrVersionMismatch when the user exists, sql.ErrNoRows otherwise.
+func (q *UserQueries) versionMismatch(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	if err := q.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)`, id); err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrVersionMismatch
+	}
+
+	return sql.ErrNoRows
+}
+
+// DeleteUser soft deletes a user and records it in the audit log, returning
+// sql.ErrNoRows when there is no active user with the id and ErrLastAdmin for
+// the last admin. Linked identities are removed, so the external accounts can
+// sign up again.
+func (q *UserQueries) DeleteUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := guardLastAdmin(ctx, tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+
+	audit.TargetID = &id
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreUser brings back a soft deleted user and records it in the audit
+// log. It fails with a unique violation when the name or email address has
+// been taken since.
+func (q *UserQueries) RestoreUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) (models.UserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return models.UserResponse{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user models.UserResponse
+	if err := tx.QueryRowxContext(ctx,
+		`UPDATE users SET deleted_at = NULL, updated_at = NOW()
+         WHERE id = $1 AND deleted_at IS NOT NULL
+         RETURNING id, name, COALESCE(full_name, '') AS full_name, email, COALESCE(phone_number, '') AS phone_number, role`,
+		id,
+	).StructScan(&user); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	audit.TargetID = &user.ID
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return models.UserResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.UserResponse{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// PurgeUser permanently removes a soft deleted user together with everything
+// referencing it, returning sql.ErrNoRows when there is no such user.
+func (q *UserQueries) PurgeUser(ctx context.Context, id uuid.UUID, audit *models.AuditLog) error {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := guardLastAdmin(ctx, tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
 		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	audit.TargetID = &id
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
+
+// PurgeDeletedUsers permanently removes up to limit users soft deleted before
+// the given time and returns how many were removed.
+func (q *UserQueries) PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := []uuid.UUID{}
+	if err := tx.SelectContext(ctx, &ids,
+		`DELETE FROM users WHERE id IN (
+             SELECT id FROM users WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED
+         )
+         RETURNING id`,
+		before,
+		limit,
+	); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := insertAuditLog(ctx, tx, &models.AuditLog{
+			Action:   models.AuditUserPurged,
+			TargetID: &id,
+			Details:  models.AuditDetails{"source": "retention"},
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(ids), nil
+}
+
+// UpdateUserRole changes the role of a user and records the change in the
+// audit log. It refuses to demote the last remaining admin.
+func (q *UserQueries) UpdateUserRole(ctx context.Context, id uuid.UUID, role models.UserRole, audit *models.AuditLog) (models.UserRoleResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return models.UserRoleResponse{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Giving the admin role never leaves the system without an admin.
+	if role != models.RoleAdmin {
+		if err := guardLastAdmin(ctx, tx, id); err != nil {
+			return models.UserRoleResponse{}, err
+		}
+	}
+
+	var previous models.UserRole
+	if err := tx.GetContext(ctx, &previous, `SELECT role FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id); err != nil {
+		return models.UserRoleResponse{}, err
+	}
+
+	if previous == role {
+		return models.UserRoleResponse{}, ErrRoleUnchanged
+	}
+
+	user := models.UserRoleResponse{PreviousRole: previous}
+	if err := tx.QueryRowxContext(ctx,
+		`UPDATE users SET role = $2, updated_at = NOW()
+         WHERE id = $1
+         RETURNING id, role, updated_at`,
+		id,
+		role,
+	).StructScan(&user); err != nil {
+		return models.UserRoleResponse{}, err
+	}
+
+	audit.TargetID = &user.ID
+	audit.Details = models.AuditDetails{"from": previous, "to": role}
+	if err := insertAuditLog(ctx, tx, audit); err != nil {
+		return models.UserRoleResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.UserRoleResponse{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+// CreateFirstAdmin creates an admin account while there is none yet. The
+// address is trusted, so it is marked as verified.
+func (q *UserQueries) CreateFirstAdmin(ctx context.Context, u *models.CreateAdminRequest) (models.CreateUserResponse, error) {
+	ctx, cancel := withTimeout(ctx, q.Timeouts.Query)
+	defer cancel()
+
+	tx, err := BeginTx(ctx, q.DB, nil)
+	if err != nil {
+		return models.CreateUserResponse{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, roleChangeLock); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM users WHERE role = 'ADMIN' AND deleted_at IS NULL)`); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	if exists {
+		return models.CreateUserResponse{}, ErrAdminExists
+	}
+
+	var user models.CreateUserResponse
+	if err := tx.QueryRowxContext(ctx,
+		`INSERT INTO users (name, full_name, email, password_hash, phone_number, role, email_verified_at)
+         VALUES ($1, $2, $3, $4, '', 'ADMIN', NOW())
+         RETURNING id, name, full_name, email, phone_number, role, created_at`,
+		u.Name,
+		u.FullName,
+		u.Email,
+		u.Password,
+	).StructScan(&user); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	if err := insertAuditLog(ctx, tx, &models.AuditLog{
+		Action:   models.AuditAdminCreated,
+		TargetID: &user.ID,
+		Details:  models.AuditDetails{"source": "cli"},
+	}); err != nil {
+		return models.CreateUserResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.CreateUserResponse{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
@@ -1,6 +1,7 @@
 package configs
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -11,7 +12,12 @@ import (
 	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
 	"github.com/otterly-id/otterly/backend/internal/delivery/route"
 	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/jobs"
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+	"github.com/otterly-id/otterly/backend/internal/policy"
+	"github.com/otterly-id/otterly/backend/internal/store"
 	"github.com/otterly-id/otterly/backend/internal/utils"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -23,36 +29,123 @@ type BootstrapConfig struct {
 	Config   *viper.Viper
 	Server   *http.Server
 	DB       *db.Queries
+	Redis    *redis.Client
+	Mailer   mailer.Mailer
 }
 
 func Bootstrap(config *BootstrapConfig) {
-	jwtSecret := config.Config.GetString("JWT_SECRET")
-	jwtExpiresIn := config.Config.GetInt("JWT_EXPIRES_IN")
+	jwtKeySet, err := NewJWTKeySet(config.Config)
+	if err != nil {
+		config.Log.Fatal("Failed to load JWT keys", zap.Error(err))
+	}
+
+	jwtAccessExpiresIn := config.Config.GetInt("JWT_ACCESS_EXPIRES_IN")
+	jwtRefreshExpiresIn := config.Config.GetInt("JWT_REFRESH_EXPIRES_IN")
 
 	jwtManager := utils.NewJWTManager(
-		[]byte(jwtSecret),
+		jwtKeySet,
 		"otterly-backend",
 		"otterly-users",
-		time.Duration(jwtExpiresIn)*time.Hour,
+		time.Duration(jwtAccessExpiresIn)*time.Minute,
+		time.Duration(jwtRefreshExpiresIn)*time.Hour,
 	)
 
+	var revocationStore store.RevocationStore = store.NewMemoryRevocationStore()
+	var attemptStore store.AttemptStore = store.NewMemoryAttemptStore()
+	if config.Redis != nil {
+		revocationStore = store.NewRedisRevocationStore(config.Redis)
+		attemptStore = store.NewRedisAttemptStore(config.Redis)
+	}
+
+	tokenSources, err := middlewares.ParseTokenSources(config.Config.GetString("AUTH_TOKEN_SOURCES"))
+	if err != nil {
+		config.Log.Fatal("Invalid AUTH_TOKEN_SOURCES", zap.Error(err))
+	}
+
+	mfaSecrets, err := NewMFASecretBox(config.Config)
+	if err != nil {
+		config.Log.Fatal("Failed to set up MFA secret encryption", zap.Error(err))
+	}
+
+	mfaRequiredRoles, err := ParseMFARequiredRoles(config.Config.GetString("AUTH_MFA_REQUIRED_ROLES"))
+	if err != nil {
+		config.Log.Fatal("Invalid AUTH_MFA_REQUIRED_ROLES", zap.Error(err))
+	}
+
+	loginThrottle := controllers.LoginThrottleSettings{
+		MaxAccountFailures: config.Config.GetInt("AUTH_LOGIN_MAX_ACCOUNT_FAILURES"),
+		MaxIPFailures:      config.Config.GetInt("AUTH_LOGIN_MAX_IP_FAILURES"),
+		FailureWindow:      time.Duration(config.Config.GetInt("AUTH_LOGIN_FAILURE_WINDOW")) * time.Minute,
+		LockoutDuration:    time.Duration(config.Config.GetInt("AUTH_LOGIN_LOCKOUT_DURATION")) * time.Minute,
+		BaseDelay:          time.Duration(config.Config.GetInt("AUTH_LOGIN_BASE_DELAY")) * time.Millisecond,
+		MaxDelay:           time.Duration(config.Config.GetInt("AUTH_LOGIN_MAX_DELAY")) * time.Millisecond,
+	}
+
+	impersonationDuration := time.Duration(config.Config.GetInt("AUTH_IMPERSONATION_EXPIRES_IN")) * time.Minute
+
+	authSettings := controllers.AuthSettings{
+		AppURL:                    config.Config.GetString("APP_URL"),
+		RequireEmailVerification:  config.Config.GetBool("AUTH_REQUIRE_EMAIL_VERIFICATION"),
+		EmailVerificationDuration: time.Duration(config.Config.GetInt("AUTH_EMAIL_VERIFICATION_EXPIRES_IN")) * time.Hour,
+		PasswordResetDuration:     time.Duration(config.Config.GetInt("AUTH_PASSWORD_RESET_EXPIRES_IN")) * time.Minute,
+		MFATokenDuration:          time.Duration(config.Config.GetInt("AUTH_MFA_TOKEN_EXPIRES_IN")) * time.Minute,
+		ImpersonationDuration:     impersonationDuration,
+		LoginThrottle:             loginThrottle,
+	}
+
+	mfaSettings := controllers.MFASettings{
+		Issuer:        config.Config.GetString("MFA_ISSUER"),
+		RequiredRoles: mfaRequiredRoles,
+		Throttle:      loginThrottle,
+	}
+
+	oidcSettings := controllers.OIDCSettings{
+		AppURL:           config.Config.GetString("APP_URL"),
+		MFATokenDuration: authSettings.MFATokenDuration,
+	}
+
+	userSettings := controllers.UserSettings{
+		AppURL:                    config.Config.GetString("APP_URL"),
+		InviteDuration:            time.Duration(config.Config.GetInt("AUTH_INVITE_EXPIRES_IN")) * time.Hour,
+		ImpersonationDuration:     impersonationDuration,
+		EmailVerificationDuration: time.Duration(config.Config.GetInt("AUTH_EMAIL_VERIFICATION_EXPIRES_IN")) * time.Hour,
+		PurgeRetention:            time.Duration(config.Config.GetInt("USER_PURGE_RETENTION_DAYS")) * 24 * time.Hour,
+	}
+
+	permissions := policy.NewRolePermissions(config.DB, time.Duration(config.Config.GetInt("AUTH_PERMISSIONS_CACHE_TTL"))*time.Second)
+
 	responseHandler := helpers.NewHandler(config.Log)
 
-	userController := controllers.NewUserController(config.Log, config.Validate, config.DB)
-	authController := controllers.NewAuthController(config.Log, config.Validate, config.DB, jwtManager)
+	userController := controllers.NewUserController(config.Log, config.Validate, config.DB, jwtManager, revocationStore, permissions, config.Mailer, userSettings)
+	authController := controllers.NewAuthController(config.Log, config.Validate, config.DB, jwtManager, revocationStore, attemptStore, config.Mailer, authSettings)
+	tokenController := controllers.NewTokenController(config.Log, config.Validate, config.DB, permissions)
+	mfaController := controllers.NewMFAController(config.Log, config.Validate, config.DB, jwtManager, attemptStore, mfaSecrets, mfaSettings)
+	oidcController := controllers.NewOIDCController(config.Log, config.DB, jwtManager, NewOIDCProviders(config.Config), oidcSettings)
 
-	authMiddleware := middlewares.NewAuthMiddleware(jwtManager, responseHandler, config.Log)
+	authMiddleware := middlewares.NewAuthMiddleware(config.DB, jwtManager, revocationStore, permissions, tokenSources, responseHandler, config.Log)
 
 	routeConfig := route.RouteConfig{
-		App:             config.App,
-		Log:             config.Log,
-		UserController:  userController,
-		AuthController:  authController,
-		ResponseHandler: helpers.NewHandler(config.Log),
-		AuthMiddleware:  authMiddleware,
+		App:              config.App,
+		Log:              config.Log,
+		UserController:   userController,
+		AuthController:   authController,
+		TokenController:  tokenController,
+		MFAController:    mfaController,
+		OIDCController:   oidcController,
+		ResponseHandler:  helpers.NewHandler(config.Log),
+		AuthMiddleware:   authMiddleware,
+		MFARequiredRoles: mfaRequiredRoles,
 	}
 
 	routeConfig.Setup()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	purgeInterval := time.Duration(config.Config.GetInt("USER_PURGE_INTERVAL")) * time.Minute
+	if userSettings.PurgeRetention > 0 && purgeInterval > 0 {
+		jobs.NewUserPurge(config.DB, userSettings.PurgeRetention, purgeInterval, config.Log).Start(ctx)
+	}
+
 	utils.StartServerWithGracefulShutdown(config.Server, config.Log)
-}
\ No newline at end of file
+}
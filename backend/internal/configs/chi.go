@@ -4,10 +4,15 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/spf13/viper"
 )
 
-func NewChi(cors func(http.Handler) http.Handler) chi.Router {
+func NewChi(config *viper.Viper, cors func(http.Handler) http.Handler) chi.Router {
 	c := chi.NewRouter()
+	if config.GetBool("SERVER_TRUST_PROXY") {
+		c.Use(middleware.RealIP)
+	}
 	c.Use(cors)
 	return c
 }
@@ -0,0 +1,54 @@
+package configs
+
+import (
+	"fmt"
+
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// NewJWTKeySet builds the signing keys from config. When neither JWT_KEYS_DIR
+// nor JWT_PRIVATE_KEY is set tokens keep being signed with JWT_SECRET (HS256).
+// Otherwise JWT_SECRET, if present, is only used to verify tokens issued before
+// the switch to asymmetric keys.
+func NewJWTKeySet(config *viper.Viper) (*utils.KeySet, error) {
+	secret := config.GetString("JWT_SECRET")
+	keysDir := config.GetString("JWT_KEYS_DIR")
+	privateKey := config.GetString("JWT_PRIVATE_KEY")
+	activeKeyID := config.GetString("JWT_ACTIVE_KEY_ID")
+
+	if keysDir == "" && privateKey == "" {
+		if secret == "" {
+			return nil, fmt.Errorf("one of JWT_SECRET, JWT_KEYS_DIR or JWT_PRIVATE_KEY must be set")
+		}
+		return utils.NewHMACKeySet([]byte(secret)), nil
+	}
+
+	if activeKeyID == "" {
+		return nil, fmt.Errorf("JWT_ACTIVE_KEY_ID must be set when using asymmetric keys")
+	}
+
+	keySet := utils.NewKeySet()
+
+	if keysDir != "" {
+		if err := keySet.LoadDir(keysDir); err != nil {
+			return nil, fmt.Errorf("failed to load JWT keys: %w", err)
+		}
+	}
+
+	if privateKey != "" {
+		if err := keySet.AddPEM(activeKeyID, []byte(privateKey)); err != nil {
+			return nil, fmt.Errorf("failed to load JWT_PRIVATE_KEY: %w", err)
+		}
+	}
+
+	if err := keySet.Activate(activeKeyID); err != nil {
+		return nil, fmt.Errorf("failed to activate JWT key: %w", err)
+	}
+
+	if secret != "" {
+		keySet.AddHMAC([]byte(secret))
+	}
+
+	return keySet, nil
+}
@@ -0,0 +1,30 @@
+package configs
+
+import (
+	"fmt"
+
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+func NewMailer(config *viper.Viper, log *zap.Logger) (mailer.Mailer, error) {
+	from := config.GetString("MAIL_FROM")
+
+	switch driver := config.GetString("MAIL_DRIVER"); driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			config.GetString("SMTP_HOST"),
+			config.GetInt("SMTP_PORT"),
+			config.GetString("SMTP_USERNAME"),
+			config.GetString("SMTP_PASSWORD"),
+			from,
+		), nil
+	case "file":
+		return mailer.NewFileMailer(config.GetString("MAIL_FILE_DIR"), from)
+	case "log":
+		return mailer.NewLogMailer(log), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
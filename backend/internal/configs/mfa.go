@@ -0,0 +1,48 @@
+package configs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"github.com/spf13/viper"
+)
+
+// NewMFASecretBox builds the cipher used for stored TOTP secrets from
+// MFA_ENCRYPTION_KEY, a base64 encoded 32 byte key. Any user may enroll, so
+// the key is required rather than derived from JWT_SECRET, which would tie the
+// stored secrets to the signing key.
+func NewMFASecretBox(config *viper.Viper) (*utils.SecretBox, error) {
+	encodedKey := config.GetString("MFA_ENCRYPTION_KEY")
+	if encodedKey == "" {
+		return nil, fmt.Errorf("MFA_ENCRYPTION_KEY must be set")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MFA_ENCRYPTION_KEY: %w", err)
+	}
+
+	return utils.NewSecretBox(key)
+}
+
+func ParseMFARequiredRoles(value string) ([]models.UserRole, error) {
+	roles := []models.UserRole{}
+
+	for _, part := range strings.Split(value, ",") {
+		role := models.UserRole(strings.ToUpper(strings.TrimSpace(part)))
+
+		switch role {
+		case "":
+			continue
+		case models.RoleAdmin, models.RoleOwner, models.RoleUser:
+			roles = append(roles, role)
+		default:
+			return nil, fmt.Errorf("unknown role %q", part)
+		}
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,23 @@
+package configs
+
+import (
+	"github.com/otterly-id/otterly/backend/internal/oidc"
+	"github.com/spf13/viper"
+)
+
+// NewOIDCProviders returns the OpenID Connect providers that have a client
+// configured, keyed by the name used in /api/auth/oidc/{provider} routes.
+func NewOIDCProviders(config *viper.Viper) map[string]*oidc.Provider {
+	providers := map[string]*oidc.Provider{}
+
+	if clientID := config.GetString("OIDC_GOOGLE_CLIENT_ID"); clientID != "" {
+		providers["google"] = oidc.NewProvider(oidc.Config{
+			Issuer:       config.GetString("OIDC_GOOGLE_ISSUER"),
+			ClientID:     clientID,
+			ClientSecret: config.GetString("OIDC_GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  config.GetString("OIDC_GOOGLE_REDIRECT_URL"),
+		}, nil)
+	}
+
+	return providers
+}
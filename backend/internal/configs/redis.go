@@ -0,0 +1,36 @@
+package configs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// NewRedis connects to REDIS_URL. It returns a nil client when no URL is
+// configured so callers can fall back to in-memory stores.
+func NewRedis(config *viper.Viper) (*redis.Client, error) {
+	redisURL := config.GetString("REDIS_URL")
+	if redisURL == "" {
+		return nil, nil
+	}
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+
+	return client, nil
+}
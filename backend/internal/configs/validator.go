@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func NewValidator() *validator.Validate {
@@ -39,5 +41,14 @@ func NewValidator() *validator.Validate {
 		return len(password) >= 8 && hasUpper && hasLower && hasNumber
 	})
 
+	v.RegisterValidation("scope", func(fl validator.FieldLevel) bool {
+		return models.IsValidScope(fl.Field().String())
+	})
+
+	v.RegisterValidation("bcrypt", func(fl validator.FieldLevel) bool {
+		_, err := bcrypt.Cost([]byte(fl.Field().String()))
+		return err == nil
+	})
+
 	return v
 }
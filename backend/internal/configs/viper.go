@@ -14,11 +14,42 @@ func NewViper() *viper.Viper {
 	config.AddConfigPath(".")
 	config.AddConfigPath("./..")
 
-	config.SetDefault("JWT_EXPIRES_IN", 24)
+	config.SetDefault("JWT_ACCESS_EXPIRES_IN", 15)
+	config.SetDefault("JWT_REFRESH_EXPIRES_IN", 720)
+	config.SetDefault("AUTH_TOKEN_SOURCES", "cookie,header")
+	config.SetDefault("AUTH_PERMISSIONS_CACHE_TTL", 60)
+	config.SetDefault("AUTH_REQUIRE_EMAIL_VERIFICATION", false)
+	config.SetDefault("AUTH_EMAIL_VERIFICATION_EXPIRES_IN", 24)
+	config.SetDefault("AUTH_PASSWORD_RESET_EXPIRES_IN", 60)
+	config.SetDefault("AUTH_INVITE_EXPIRES_IN", 72)
+	config.SetDefault("AUTH_LOGIN_MAX_ACCOUNT_FAILURES", 5)
+	config.SetDefault("AUTH_LOGIN_MAX_IP_FAILURES", 50)
+	config.SetDefault("AUTH_LOGIN_FAILURE_WINDOW", 15)
+	config.SetDefault("AUTH_LOGIN_LOCKOUT_DURATION", 15)
+	config.SetDefault("AUTH_LOGIN_BASE_DELAY", 250)
+	config.SetDefault("AUTH_LOGIN_MAX_DELAY", 4000)
+	config.SetDefault("AUTH_MFA_REQUIRED_ROLES", "ADMIN,OWNER")
+	config.SetDefault("AUTH_MFA_TOKEN_EXPIRES_IN", 5)
+	config.SetDefault("AUTH_IMPERSONATION_EXPIRES_IN", 15)
+	config.SetDefault("USER_PURGE_RETENTION_DAYS", 30)
+	config.SetDefault("USER_PURGE_INTERVAL", 60)
+	config.SetDefault("MFA_ISSUER", "Otterly")
+	config.SetDefault("OIDC_GOOGLE_ISSUER", "https://accounts.google.com")
+	config.SetDefault("OIDC_GOOGLE_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/google/callback")
+	config.SetDefault("SERVER_TRUST_PROXY", false)
+	config.SetDefault("APP_URL", "http://localhost:3000")
+	config.SetDefault("MAIL_DRIVER", "log")
+	config.SetDefault("MAIL_FROM", "Otterly <no-reply@otterly.id>")
+	config.SetDefault("MAIL_FILE_DIR", "tmp/mail")
+	config.SetDefault("SMTP_PORT", 587)
 	config.SetDefault("SERVER_URL", "0.0.0.0:8080")
 	config.SetDefault("DB_MAX_CONNECTIONS", 100)
 	config.SetDefault("DB_MAX_IDLE_CONNECTIONS", 10)
 	config.SetDefault("DB_MAX_LIFETIME_CONNECTIONS", 2)
+	config.SetDefault("DB_QUERY_TIMEOUT", 5)
+	config.SetDefault("DB_LIST_QUERY_TIMEOUT", 15)
+	config.SetDefault("DB_BULK_QUERY_TIMEOUT", 300)
+	config.SetDefault("DB_AUTO_MIGRATE", false)
 
 	err := config.ReadInConfig()
 
@@ -0,0 +1,120 @@
+// Package dberrors classifies the errors returned by the database, so callers
+// can tell a missing row or a taken email address apart from a failure
+// without looking at driver types or SQLSTATE codes.
+package dberrors
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// The kinds of classified errors. errors.Is matches an Error against its
+// kind.
+var (
+	ErrNotFound            = errors.New("record not found")
+	ErrUniqueViolation     = errors.New("unique violation")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrCheckViolation      = errors.New("check violation")
+	// ErrSerializationFailure is a transaction the database aborted to stay
+	// serializable or to break a deadlock. Running it again may succeed.
+	ErrSerializationFailure = errors.New("serialization failure")
+)
+
+// kinds maps the SQLSTATE codes that are classified to their kind.
+var kinds = map[string]error{
+	"23505": ErrUniqueViolation,
+	"23503": ErrForeignKeyViolation,
+	"23514": ErrCheckViolation,
+	"40001": ErrSerializationFailure,
+	"40P01": ErrSerializationFailure,
+}
+
+// fields names the request field each constraint protects, for the
+// constraints clients run into with valid input.
+var fields = map[string]string{
+	"users_name_key":  "name",
+	"users_email_key": "email",
+}
+
+// Error is a classified database error. It unwraps to both its kind and the
+// driver error, so errors.Is(err, sql.ErrNoRows) and errors.As with a
+// *pgconn.PgError keep working.
+type Error struct {
+	Kind error
+	// Constraint is the name of the violated constraint, if any.
+	Constraint string
+	// Field is the request field the constraint protects, if known.
+	Field string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Translate classifies err. Errors that say nothing about the data, like a
+// lost connection or a timeout, are returned as they are, and so is nil.
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	kind, ok := kinds[pgErr.Code]
+	if !ok {
+		return err
+	}
+
+	field := fields[pgErr.ConstraintName]
+	if field == "" {
+		field = pgErr.ColumnName
+	}
+
+	return &Error{
+		Kind:       kind,
+		Constraint: pgErr.ConstraintName,
+		Field:      field,
+		Err:        err,
+	}
+}
+
+// As returns the classification of err, if it has one.
+func As(err error) (*Error, bool) {
+	var classified *Error
+	if errors.As(Translate(err), &classified) {
+		return classified, true
+	}
+
+	return nil, false
+}
+
+// IsUniqueViolation reports whether err violates a unique constraint, the
+// one named constraint unless it is empty.
+func IsUniqueViolation(err error, constraint string) bool {
+	classified, ok := As(err)
+	if !ok || classified.Kind != ErrUniqueViolation {
+		return false
+	}
+
+	return constraint == "" || classified.Constraint == constraint
+}
@@ -0,0 +1,79 @@
+package dberrors_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		kind       error
+		constraint string
+		field      string
+	}{
+		{
+			name: "no rows",
+			err:  fmt.Errorf("failed to get user: %w", sql.ErrNoRows),
+			kind: dberrors.ErrNotFound,
+		},
+		{
+			name:       "taken email",
+			err:        &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"},
+			kind:       dberrors.ErrUniqueViolation,
+			constraint: "users_email_key",
+			field:      "email",
+		},
+		{
+			name:       "foreign key",
+			err:        &pgconn.PgError{Code: "23503", ConstraintName: "api_tokens_user_id_fkey", ColumnName: "user_id"},
+			kind:       dberrors.ErrForeignKeyViolation,
+			constraint: "api_tokens_user_id_fkey",
+			field:      "user_id",
+		},
+		{
+			name: "deadlock",
+			err:  &pgconn.PgError{Code: "40P01"},
+			kind: dberrors.ErrSerializationFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			translated := dberrors.Translate(tt.err)
+
+			if !errors.Is(translated, tt.kind) {
+				t.Fatalf("Translate(%v) = %v, want %v", tt.err, translated, tt.kind)
+			}
+			if !errors.Is(translated, tt.err) {
+				t.Fatalf("Translate(%v) does not wrap the driver error", tt.err)
+			}
+
+			classified, ok := dberrors.As(translated)
+			if !ok {
+				t.Fatalf("As(%v) is not classified", translated)
+			}
+			if classified.Constraint != tt.constraint || classified.Field != tt.field {
+				t.Fatalf("constraint, field = %q, %q, want %q, %q", classified.Constraint, classified.Field, tt.constraint, tt.field)
+			}
+		})
+	}
+}
+
+func TestTranslateLeavesOtherErrors(t *testing.T) {
+	for _, err := range []error{
+		nil,
+		errors.New("connection reset"),
+		&pgconn.PgError{Code: "57014"},
+	} {
+		if translated := dberrors.Translate(err); translated != err {
+			t.Errorf("Translate(%v) = %v, want it unchanged", err, translated)
+		}
+	}
+}
@@ -2,13 +2,20 @@ package middlewares
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/db"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
 	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/policy"
+	"github.com/otterly-id/otterly/backend/internal/store"
 	"github.com/otterly-id/otterly/backend/internal/utils"
 	"go.uber.org/zap"
 )
@@ -19,30 +26,102 @@ const (
 	UserContextKey ContextKey = "user"
 )
 
+type TokenSource string
+
+const (
+	TokenSourceCookie TokenSource = "cookie"
+	TokenSourceHeader TokenSource = "header"
+)
+
+type AuthMethod string
+
+const (
+	AuthMethodSession  AuthMethod = "session"
+	AuthMethodAPIToken AuthMethod = "api_token"
+)
+
+var errTokenNotFound = errors.New("no token found in request")
+
 type UserInfo struct {
-	ID   uuid.UUID       `json:"id"`
-	Role models.UserRole `json:"role"`
+	ID          uuid.UUID       `json:"id"`
+	Role        models.UserRole `json:"role"`
+	AuthMethod  AuthMethod      `json:"-"`
+	Scopes      []string        `json:"-"`
+	Permissions []string        `json:"-"`
+	ActorID     *uuid.UUID      `json:"-"`
+	TokenID     string          `json:"-"`
+	ExpiresAt   time.Time       `json:"-"`
+}
+
+// IsImpersonated reports whether an admin, ActorID, is acting as the user.
+func (u *UserInfo) IsImpersonated() bool {
+	return u.ActorID != nil
+}
+
+// UserID identifies the caller to the rules of the policy package.
+func (u *UserInfo) UserID() uuid.UUID {
+	return u.ID
+}
+
+// HasPermission reports whether the request may use permission. Sessions hold
+// the permissions of their role, API tokens only those of their scopes that
+// the role still grants.
+func (u *UserInfo) HasPermission(permission string) bool {
+	return slices.Contains(u.Permissions, permission)
 }
 
 type AuthMiddleware struct {
+	DB              db.AuthRepository
 	JWTManager      *utils.JWTManager
+	Revocations     store.RevocationStore
+	Permissions     *policy.RolePermissions
+	TokenSources    []TokenSource
 	ResponseHandler *helpers.ResponseHandler
 	Log             *zap.Logger
 }
 
-func NewAuthMiddleware(jwtManager *utils.JWTManager, responseHandler *helpers.ResponseHandler, log *zap.Logger) *AuthMiddleware {
+func NewAuthMiddleware(db db.AuthRepository, jwtManager *utils.JWTManager, revocations store.RevocationStore, permissions *policy.RolePermissions, tokenSources []TokenSource, responseHandler *helpers.ResponseHandler, log *zap.Logger) *AuthMiddleware {
 	return &AuthMiddleware{
+		DB:              db,
 		JWTManager:      jwtManager,
+		Revocations:     revocations,
+		Permissions:     permissions,
+		TokenSources:    tokenSources,
 		ResponseHandler: responseHandler,
 		Log:             log,
 	}
 }
 
+// ParseTokenSources reads a comma separated precedence list such as
+// "cookie,header". The first source that carries a token wins.
+func ParseTokenSources(value string) ([]TokenSource, error) {
+	sources := []TokenSource{}
+
+	for _, part := range strings.Split(value, ",") {
+		source := TokenSource(strings.ToLower(strings.TrimSpace(part)))
+		switch source {
+		case TokenSourceCookie, TokenSourceHeader:
+			if !slices.Contains(sources, source) {
+				sources = append(sources, source)
+			}
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown token source %q", part)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("at least one token source is required")
+	}
+
+	return sources, nil
+}
+
 func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, err := am.getTokenFromCookie(r)
+		token, err := am.getToken(r)
 		if err != nil {
-			am.Log.Warn("Failed to get token from cookie",
+			am.Log.Warn("Failed to get token from request",
 				zap.String("url", r.URL.String()),
 				zap.String("method", r.Method),
 				zap.Error(err))
@@ -50,9 +129,15 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		claims, err := am.JWTManager.ValidateToken(token)
+		var userInfo *UserInfo
+		if strings.HasPrefix(token, utils.APITokenPrefix) {
+			userInfo, err = am.authenticateAPIToken(r, token)
+		} else {
+			userInfo, err = am.authenticateJWT(r, token)
+		}
+
 		if err != nil {
-			am.Log.Warn("Invalid JWT token",
+			am.Log.Warn("Invalid token",
 				zap.String("url", r.URL.String()),
 				zap.String("method", r.Method),
 				zap.Error(err))
@@ -60,23 +145,26 @@ func (am *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, err := uuid.Parse(claims.ID)
-		if err != nil {
-			am.ResponseHandler.InvalidIDError(w, r, err)
+		if err := am.resolvePermissions(r.Context(), userInfo); err != nil {
+			am.ResponseHandler.PermissionResolutionError(w, r, err)
 			return
 		}
 
-		userInfo := &UserInfo{
-			ID:   userID,
-			Role: claims.Role,
+		ctx := context.WithValue(r.Context(), UserContextKey, userInfo)
+		if userInfo.IsImpersonated() {
+			ctx = helpers.WithImpersonation(ctx, helpers.Impersonation{
+				UserID:  userInfo.ID,
+				ActorID: *userInfo.ActorID,
+			})
 		}
 
-		ctx := context.WithValue(r.Context(), UserContextKey, userInfo)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func (am *AuthMiddleware) RequireRole(requiredRole models.UserRole) func(http.Handler) http.Handler {
+// RequirePermission rejects requests whose role, or API token scopes, do not
+// grant permission.
+func (am *AuthMiddleware) RequirePermission(permission string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userInfo, ok := r.Context().Value(UserContextKey).(*UserInfo)
@@ -85,12 +173,13 @@ func (am *AuthMiddleware) RequireRole(requiredRole models.UserRole) func(http.Ha
 				return
 			}
 
-			if userInfo.Role != requiredRole {
+			if !userInfo.HasPermission(permission) {
 				am.Log.Warn("Insufficient permissions",
 					zap.String("url", r.URL.String()),
 					zap.String("method", r.Method),
 					zap.String("user_role", string(userInfo.Role)),
-					zap.String("required_role", string(requiredRole)))
+					zap.String("auth_method", string(userInfo.AuthMethod)),
+					zap.String("required_permission", permission))
 				am.ResponseHandler.InsufficientPermissionsError(w, r)
 				return
 			}
@@ -100,7 +189,51 @@ func (am *AuthMiddleware) RequireRole(requiredRole models.UserRole) func(http.Ha
 	}
 }
 
-func (am *AuthMiddleware) RequireAnyRole(requiredRoles ...models.UserRole) func(http.Handler) http.Handler {
+// RequireSession only lets interactive sessions through, so API tokens cannot
+// be used to log out or to mint further tokens.
+func (am *AuthMiddleware) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userInfo, ok := r.Context().Value(UserContextKey).(*UserInfo)
+		if !ok {
+			am.ResponseHandler.AuthenticationRequiredError(w, r)
+			return
+		}
+
+		if userInfo.AuthMethod != AuthMethodSession {
+			am.Log.Warn("Session required",
+				zap.String("url", r.URL.String()),
+				zap.String("method", r.Method),
+				zap.String("auth_method", string(userInfo.AuthMethod)))
+			am.ResponseHandler.InsufficientPermissionsError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectImpersonation keeps admins acting as a user away from the credentials
+// of that user, such as the password, two factor authentication and tokens.
+func (am *AuthMiddleware) RejectImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userInfo, ok := r.Context().Value(UserContextKey).(*UserInfo)
+		if !ok {
+			am.ResponseHandler.AuthenticationRequiredError(w, r)
+			return
+		}
+
+		if userInfo.IsImpersonated() {
+			am.ResponseHandler.ImpersonationForbiddenError(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireMFAEnrollment rejects users of the given roles until they have two
+// factor authentication enabled. Routes needed to enroll must not use it.
+func (am *AuthMiddleware) RequireMFAEnrollment(roles ...models.UserRole) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userInfo, ok := r.Context().Value(UserContextKey).(*UserInfo)
@@ -109,15 +242,20 @@ func (am *AuthMiddleware) RequireAnyRole(requiredRoles ...models.UserRole) func(
 				return
 			}
 
-			hasRole := slices.Contains(requiredRoles, userInfo.Role)
+			if !slices.Contains(roles, userInfo.Role) {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if !hasRole {
-				am.Log.Warn("Insufficient permissions",
-					zap.String("url", r.URL.String()),
-					zap.String("method", r.Method),
-					zap.String("user_role", string(userInfo.Role)),
-					zap.String("required_roles", strings.Join(roleStrings(requiredRoles), ", ")))
-				am.ResponseHandler.InsufficientPermissionsError(w, r)
+			mfa, err := am.DB.GetMFA(r.Context(), userInfo.ID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				am.Log.Error("Failed to check two-factor enrollment",
+					zap.String("user_id", userInfo.ID.String()),
+					zap.Error(err))
+			}
+
+			if err != nil || mfa.ConfirmedAt == nil {
+				am.ResponseHandler.MFARequiredError(w, r)
 				return
 			}
 
@@ -126,20 +264,156 @@ func (am *AuthMiddleware) RequireAnyRole(requiredRoles ...models.UserRole) func(
 	}
 }
 
-func (am *AuthMiddleware) getTokenFromCookie(r *http.Request) (string, error) {
+func (am *AuthMiddleware) authenticateJWT(r *http.Request, token string) (*UserInfo, error) {
+	claims, err := am.JWTManager.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id: %w", err)
+	}
+
+	if err := am.checkRevocation(r, userID, claims); err != nil {
+		return nil, err
+	}
+
+	userInfo := &UserInfo{
+		ID:         userID,
+		Role:       claims.Role,
+		AuthMethod: AuthMethodSession,
+		TokenID:    claims.RegisteredClaims.ID,
+		ExpiresAt:  claims.ExpiresAt.Time,
+	}
+
+	if claims.Actor != nil {
+		actorID, err := uuid.Parse(claims.Actor.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor id: %w", err)
+		}
+
+		// Signing the admin out also ends the sessions they impersonate.
+		revoked, err := am.Revocations.IsUserRevoked(r.Context(), actorID, claims.IssuedAtTime())
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, errors.New("actor sessions have been revoked")
+		}
+
+		userInfo.ActorID = &actorID
+	}
+
+	return userInfo, nil
+}
+
+func (am *AuthMiddleware) authenticateAPIToken(r *http.Request, token string) (*UserInfo, error) {
+	apiToken, err := am.DB.GetAPITokenByHash(r.Context(), utils.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("unknown api token: %w", err)
+	}
+
+	if apiToken.RevokedAt != nil {
+		return nil, errors.New("api token has been revoked")
+	}
+
+	if apiToken.ExpiresAt != nil && apiToken.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("api token expired")
+	}
+
+	if err := am.DB.TouchAPIToken(r.Context(), apiToken.ID); err != nil {
+		am.Log.Warn("Failed to record api token usage",
+			zap.String("token_id", apiToken.ID.String()),
+			zap.Error(err))
+	}
+
+	userInfo := &UserInfo{
+		ID:         apiToken.UserID,
+		Role:       apiToken.Role,
+		AuthMethod: AuthMethodAPIToken,
+		Scopes:     apiToken.Scopes,
+		TokenID:    apiToken.ID.String(),
+	}
+
+	if apiToken.ExpiresAt != nil {
+		userInfo.ExpiresAt = *apiToken.ExpiresAt
+	}
+
+	return userInfo, nil
+}
+
+// resolvePermissions looks up the permissions of the role carried by the
+// credential. API tokens are narrowed down to their scopes.
+func (am *AuthMiddleware) resolvePermissions(ctx context.Context, userInfo *UserInfo) error {
+	permissions, err := am.Permissions.Resolve(ctx, userInfo.Role)
+	if err != nil {
+		return err
+	}
+
+	if userInfo.AuthMethod == AuthMethodAPIToken {
+		permissions = slices.DeleteFunc(slices.Clone(permissions), func(permission string) bool {
+			return !slices.Contains(userInfo.Scopes, permission)
+		})
+	}
+
+	userInfo.Permissions = permissions
+	return nil
+}
+
+func (am *AuthMiddleware) checkRevocation(r *http.Request, userID uuid.UUID, claims *utils.Claims) error {
+	revoked, err := am.Revocations.IsTokenRevoked(r.Context(), claims.RegisteredClaims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+
+	revoked, err = am.Revocations.IsUserRevoked(r.Context(), userID, claims.IssuedAtTime())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("user sessions have been revoked")
+	}
+
+	return nil
+}
+
+func (am *AuthMiddleware) getToken(r *http.Request) (string, error) {
+	for _, source := range am.TokenSources {
+		var token string
+
+		switch source {
+		case TokenSourceCookie:
+			token = am.getTokenFromCookie(r)
+		case TokenSourceHeader:
+			token = am.getTokenFromHeader(r)
+		}
+
+		if token != "" {
+			return token, nil
+		}
+	}
+
+	return "", errTokenNotFound
+}
+
+func (am *AuthMiddleware) getTokenFromCookie(r *http.Request) string {
 	cookie, err := r.Cookie("otterly_token")
 	if err != nil {
-		return "", err
+		return ""
 	}
-	return cookie.Value, nil
+	return cookie.Value
 }
 
-func roleStrings(roles []models.UserRole) []string {
-	result := make([]string, len(roles))
-	for i, role := range roles {
-		result[i] = string(role)
+func (am *AuthMiddleware) getTokenFromHeader(r *http.Request) string {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
 	}
-	return result
+	return strings.TrimSpace(token)
 }
 
 func GetUserFromContext(ctx context.Context) (*UserInfo, bool) {
@@ -0,0 +1,280 @@
+package route_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/helpers"
+)
+
+func TestRegisterVerifyAndLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	s.call(t, http.MethodPost, "/api/auth/register", "", models.RegisterRequest{
+		Name:     "Otter",
+		Email:    "otter@example.com",
+		Password: "weak",
+	}).expect(t, http.StatusBadRequest)
+
+	s.call(t, http.MethodPost, "/api/auth/register", "", "{").expect(t, http.StatusBadRequest)
+
+	user := data[models.RegisterResponse](t, s.call(t, http.MethodPost, "/api/auth/register", "", models.RegisterRequest{
+		Name:     "Otter",
+		Email:    "otter@example.com",
+		Password: password,
+	}).expect(t, http.StatusCreated))
+
+	if user.Email != "otter@example.com" {
+		t.Fatalf("email = %q, want otter@example.com", user.Email)
+	}
+
+	verification := s.mail.next(t)
+	if verification.To != user.Email {
+		t.Fatalf("verification sent to %q, want %q", verification.To, user.Email)
+	}
+
+	conflict := s.call(t, http.MethodPost, "/api/auth/register", "", models.RegisterRequest{
+		Name:     "Otter",
+		Email:    "other@example.com",
+		Password: password,
+	}).expect(t, http.StatusConflict)
+
+	if field := conflictingField(t, conflict); field != "name" {
+		t.Fatalf("conflicting field = %q, want name", field)
+	}
+
+	// Only the registration that went through is audited.
+	var registered []models.AuditLog
+	for _, entry := range s.db.AuditLogs() {
+		if entry.Action == models.AuditUserRegistered {
+			registered = append(registered, entry)
+		}
+	}
+
+	if len(registered) != 1 || registered[0].ActorID == nil || *registered[0].ActorID != user.ID || *registered[0].TargetID != user.ID {
+		t.Fatalf("audit logs = %+v, want one registration of %s", registered, user.ID)
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/verify-email/resend", "", models.ResendVerificationRequest{
+		Email: user.Email,
+	}).expect(t, http.StatusOK)
+	resent := s.mail.next(t)
+
+	s.call(t, http.MethodPost, "/api/auth/verify-email", "", models.VerifyEmailRequest{Token: "invalid"}).expect(t, http.StatusBadRequest)
+
+	account := data[models.AccountResponse](t, s.call(t, http.MethodPost, "/api/auth/verify-email", "", models.VerifyEmailRequest{
+		Token: tokenOf(t, resent),
+	}).expect(t, http.StatusOK))
+
+	if account.EmailVerifiedAt == nil {
+		t.Fatalf("email_verified_at = nil after verification")
+	}
+
+	// Verified accounts and unknown addresses get the same answer, without an
+	// email.
+	for _, email := range []string{user.Email, "nobody@example.com"} {
+		s.call(t, http.MethodPost, "/api/auth/verify-email/resend", "", models.ResendVerificationRequest{Email: email}).expect(t, http.StatusOK)
+	}
+	s.mail.empty(t)
+
+	s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+		Email:    user.Email,
+		Password: "Wrong-Password-1",
+	}).expect(t, http.StatusUnauthorized)
+
+	s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+		Email:    "nobody@example.com",
+		Password: password,
+	}).expect(t, http.StatusUnauthorized)
+
+	// Browsers only get the refresh token as an HttpOnly cookie.
+	browser := s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+		Email:    user.Email,
+		Password: password,
+	}).expect(t, http.StatusOK)
+	if got := data[models.TokenResponse](t, browser); got.AccessToken == "" || got.RefreshToken != "" {
+		t.Fatalf("login without X-Token-Delivery = %+v, want no refresh token", got)
+	}
+	if !strings.Contains(strings.Join(browser.header.Values("Set-Cookie"), "\n"), "otterly_refresh_token=") {
+		t.Fatalf("login set cookies %q, want the refresh token", browser.header.Values("Set-Cookie"))
+	}
+
+	session := s.login(t, user.Email, password)
+	if session.AccessToken == "" || session.RefreshToken == "" || session.Role != models.RoleUser {
+		t.Fatalf("login = %+v, want a USER session", session)
+	}
+
+	me := s.call(t, http.MethodGet, "/api/auth/me", session.AccessToken, nil).expect(t, http.StatusOK)
+	if got := data[models.UserResponse](t, me); got.ID != user.ID {
+		t.Fatalf("me = %s, want %s", got.ID, user.ID)
+	}
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	session := s.login(t, "otter@example.com", password)
+
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: "unknown"}).expect(t, http.StatusUnauthorized)
+
+	refreshed := data[models.TokenResponse](t, s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{
+		RefreshToken: session.RefreshToken,
+	}).expect(t, http.StatusOK))
+
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == session.RefreshToken {
+		t.Fatalf("refresh token was not rotated")
+	}
+
+	// Replaying the old token revokes the whole family, the new one included.
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: session.RefreshToken}).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: refreshed.RefreshToken}).expect(t, http.StatusUnauthorized)
+}
+
+func TestProfileConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	me := s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)
+	etag := me.header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("GET /api/auth/me has no ETag")
+	}
+
+	request := s.newRequest(t, http.MethodGet, "/api/auth/me", token, nil)
+	request.Header.Set("If-None-Match", etag)
+	s.send(t, request).expect(t, http.StatusNotModified)
+
+	fullName := "Otter Lutra"
+	request = s.newRequest(t, http.MethodPatch, "/api/auth/me", token, models.UpdateUserRequest{FullName: &fullName})
+	request.Header.Set("If-Match", helpers.ETag(99))
+	s.send(t, request).expect(t, http.StatusPreconditionFailed)
+
+	request = s.newRequest(t, http.MethodPatch, "/api/auth/me", token, models.UpdateUserRequest{FullName: &fullName})
+	request.Header.Set("If-Match", "not-an-etag")
+	s.send(t, request).expect(t, http.StatusBadRequest)
+
+	request = s.newRequest(t, http.MethodPatch, "/api/auth/me", token, models.UpdateUserRequest{FullName: &fullName})
+	request.Header.Set("If-Match", etag)
+	updated := s.send(t, request).expect(t, http.StatusOK)
+
+	if got := data[models.UpdateUserResponse](t, updated).FullName; got != fullName {
+		t.Fatalf("full_name = %q, want %q", got, fullName)
+	}
+
+	if updated.header.Get("ETag") == etag {
+		t.Fatalf("ETag %s did not change with the update", etag)
+	}
+
+	// A new address is verified again.
+	email := "lutra@example.com"
+	s.call(t, http.MethodPatch, "/api/auth/me", token, models.UpdateUserRequest{Email: &email}).expect(t, http.StatusOK)
+	if message := s.mail.next(t); message.To != email {
+		t.Fatalf("verification sent to %q, want %q", message.To, email)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	session := s.login(t, "otter@example.com", password)
+
+	s.call(t, http.MethodPost, "/api/auth/forgot-password", "", models.ForgotPasswordRequest{Email: "nobody@example.com"}).expect(t, http.StatusOK)
+	s.call(t, http.MethodPost, "/api/auth/forgot-password", "", models.ForgotPasswordRequest{Email: "otter@example.com"}).expect(t, http.StatusOK)
+
+	token := tokenOf(t, s.mail.next(t))
+	newPassword := "New-Otter-Password-1"
+
+	s.call(t, http.MethodPost, "/api/auth/reset-password", "", models.ResetPasswordRequest{Token: "unknown", Password: newPassword}).expect(t, http.StatusBadRequest)
+	s.call(t, http.MethodPost, "/api/auth/reset-password", "", models.ResetPasswordRequest{Token: token, Password: newPassword}).expect(t, http.StatusOK)
+	s.call(t, http.MethodPost, "/api/auth/reset-password", "", models.ResetPasswordRequest{Token: token, Password: newPassword}).expect(t, http.StatusBadRequest)
+
+	// Every session of the account ended with the reset.
+	s.call(t, http.MethodGet, "/api/auth/me", session.AccessToken, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: session.RefreshToken}).expect(t, http.StatusUnauthorized)
+
+	s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{Email: "otter@example.com", Password: password}).expect(t, http.StatusUnauthorized)
+	s.login(t, "otter@example.com", newPassword)
+}
+
+func TestChangePasswordAndDeleteAccount(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	session := s.login(t, "otter@example.com", password)
+	newPassword := "New-Otter-Password-1"
+
+	s.call(t, http.MethodPost, "/api/auth/me/password", session.AccessToken, models.ChangePasswordRequest{
+		CurrentPassword: "Wrong-Password-1",
+		NewPassword:     newPassword,
+	}).expect(t, http.StatusUnauthorized)
+
+	changed := data[models.TokenResponse](t, s.call(t, http.MethodPost, "/api/auth/me/password", session.AccessToken, models.ChangePasswordRequest{
+		CurrentPassword: password,
+		NewPassword:     newPassword,
+	}).expect(t, http.StatusOK))
+
+	s.call(t, http.MethodGet, "/api/auth/me", session.AccessToken, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodGet, "/api/auth/me", changed.AccessToken, nil).expect(t, http.StatusOK)
+
+	s.call(t, http.MethodDelete, "/api/auth/me", changed.AccessToken, models.DeleteAccountRequest{Password: password}).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodDelete, "/api/auth/me", changed.AccessToken, models.DeleteAccountRequest{Password: newPassword}).expect(t, http.StatusOK)
+
+	s.call(t, http.MethodGet, "/api/auth/me", changed.AccessToken, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{Email: "otter@example.com", Password: newPassword}).expect(t, http.StatusUnauthorized)
+
+	// The address is free again.
+	s.register(t, "Otter", "otter@example.com")
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+
+	first := s.login(t, "otter@example.com", password)
+	s.call(t, http.MethodPost, "/api/auth/logout", first.AccessToken, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, "/api/auth/me", first.AccessToken, nil).expect(t, http.StatusUnauthorized)
+
+	second := s.login(t, "otter@example.com", password)
+	third := s.login(t, "otter@example.com", password)
+	apiToken := data[models.CreateAPITokenResponse](t, s.call(t, http.MethodPost, "/api/tokens", second.AccessToken, models.CreateAPITokenRequest{
+		Name:   "Script",
+		Scopes: []string{models.PermissionProfileRead},
+	}).expect(t, http.StatusCreated))
+
+	s.call(t, http.MethodPost, "/api/auth/logout-all", second.AccessToken, nil).expect(t, http.StatusOK)
+
+	for _, session := range []models.TokenResponse{second, third} {
+		s.call(t, http.MethodGet, "/api/auth/me", session.AccessToken, nil).expect(t, http.StatusUnauthorized)
+		s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: session.RefreshToken}).expect(t, http.StatusUnauthorized)
+	}
+	s.call(t, http.MethodGet, "/api/auth/me", apiToken.Token, nil).expect(t, http.StatusUnauthorized)
+
+	// Signing in again right away works, within the second of the
+	// revocation.
+	fourth := s.login(t, "otter@example.com", password)
+	s.call(t, http.MethodGet, "/api/auth/me", fourth.AccessToken, nil).expect(t, http.StatusOK)
+}
+
+func TestLoginLockout(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+
+	for range 5 {
+		s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+			Email:    "otter@example.com",
+			Password: "Wrong-Password-1",
+		}).expect(t, http.StatusUnauthorized)
+	}
+
+	r := s.call(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+		Email:    "otter@example.com",
+		Password: password,
+	}).expect(t, http.StatusTooManyRequests)
+
+	if r.header.Get("Retry-After") == "" {
+		t.Fatalf("locked out login has no Retry-After header")
+	}
+}
@@ -0,0 +1,201 @@
+package route_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+)
+
+// enableMFA enrolls the holder of token and confirms the enrollment with the
+// code of the current step. It returns the secret and the recovery codes.
+func (s *testServer) enableMFA(t *testing.T, token string) (string, []string) {
+	t.Helper()
+
+	enrollment := data[models.MFAEnrollResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/enroll", token, nil).expect(t, http.StatusOK))
+
+	code, err := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+
+	confirmed := data[models.MFARecoveryCodesResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/confirm", token, models.MFACodeRequest{
+		Code: code,
+	}).expect(t, http.StatusOK))
+
+	return enrollment.Secret, confirmed.RecoveryCodes
+}
+
+// nextCode returns the code of the step after the current one, which the
+// validation window accepts and no earlier request has used.
+func nextCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+1)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+
+	return code
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	status := data[models.MFAStatusResponse](t, s.call(t, http.MethodGet, "/api/auth/mfa", token, nil).expect(t, http.StatusOK))
+	if status.Enabled || status.Required {
+		t.Fatalf("status = %+v, want disabled and not required", status)
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/confirm", token, models.MFACodeRequest{Code: "123456"}).expect(t, http.StatusConflict)
+
+	enrollment := data[models.MFAEnrollResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/enroll", token, nil).expect(t, http.StatusOK))
+	if enrollment.Secret == "" || enrollment.ProvisioningURI == "" {
+		t.Fatalf("enrollment = %+v, want a secret and a provisioning URI", enrollment)
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/confirm", token, models.MFACodeRequest{Code: "abcdef"}).expect(t, http.StatusBadRequest)
+
+	wrong, err := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now())+10)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	s.call(t, http.MethodPost, "/api/auth/mfa/confirm", token, models.MFACodeRequest{Code: wrong}).expect(t, http.StatusUnauthorized)
+
+	code, err := utils.TOTPCode(enrollment.Secret, utils.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+
+	codes := data[models.MFARecoveryCodesResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/confirm", token, models.MFACodeRequest{
+		Code: code,
+	}).expect(t, http.StatusOK))
+
+	if len(codes.RecoveryCodes) == 0 {
+		t.Fatalf("confirmation returned no recovery codes")
+	}
+
+	status = data[models.MFAStatusResponse](t, s.call(t, http.MethodGet, "/api/auth/mfa", token, nil).expect(t, http.StatusOK))
+	if !status.Enabled || status.RecoveryCodesRemaining != len(codes.RecoveryCodes) {
+		t.Fatalf("status = %+v, want enabled with %d recovery codes", status, len(codes.RecoveryCodes))
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/enroll", token, nil).expect(t, http.StatusConflict)
+}
+
+func TestMFALogin(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	secret, recoveryCodes := s.enableMFA(t, s.login(t, "otter@example.com", password).AccessToken)
+
+	login := func() string {
+		t.Helper()
+
+		pending := s.login(t, "otter@example.com", password)
+		if !pending.MFARequired || pending.MFAToken == "" || pending.AccessToken != "" {
+			t.Fatalf("login = %+v, want only an MFA token", pending)
+		}
+
+		return pending.MFAToken
+	}
+
+	mfaToken := login()
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: "invalid", Code: nextCode(t, secret)}).expect(t, http.StatusBadRequest)
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: mfaToken, Code: "000000"}).expect(t, http.StatusUnauthorized)
+
+	code := nextCode(t, secret)
+	session := data[models.TokenResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{
+		MFAToken: mfaToken,
+		Code:     code,
+	}).expect(t, http.StatusOK))
+
+	s.call(t, http.MethodGet, "/api/auth/me", session.AccessToken, nil).expect(t, http.StatusOK)
+
+	// A code is only good once.
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: login(), Code: code}).expect(t, http.StatusUnauthorized)
+
+	// So is a recovery code.
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: login(), Code: recoveryCodes[0]}).expect(t, http.StatusOK)
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: login(), Code: recoveryCodes[0]}).expect(t, http.StatusUnauthorized)
+
+	status := data[models.MFAStatusResponse](t, s.call(t, http.MethodGet, "/api/auth/mfa", session.AccessToken, nil).expect(t, http.StatusOK))
+	if status.RecoveryCodesRemaining != len(recoveryCodes)-1 {
+		t.Fatalf("recovery_codes_remaining = %d, want %d", status.RecoveryCodesRemaining, len(recoveryCodes)-1)
+	}
+}
+
+func TestMFARecoveryCodesAndDisable(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+	secret, recoveryCodes := s.enableMFA(t, token)
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/recovery-codes", token, models.MFACodeRequest{Code: "000000"}).expect(t, http.StatusUnauthorized)
+
+	regenerated := data[models.MFARecoveryCodesResponse](t, s.call(t, http.MethodPost, "/api/auth/mfa/recovery-codes", token, models.MFACodeRequest{
+		Code: nextCode(t, secret),
+	}).expect(t, http.StatusOK))
+
+	if len(regenerated.RecoveryCodes) != len(recoveryCodes) || regenerated.RecoveryCodes[0] == recoveryCodes[0] {
+		t.Fatalf("recovery codes were not replaced")
+	}
+
+	s.call(t, http.MethodDelete, "/api/auth/mfa", token, models.MFADisableRequest{Password: "Wrong-Password-1", Code: regenerated.RecoveryCodes[0]}).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodDelete, "/api/auth/mfa", token, models.MFADisableRequest{Password: password, Code: recoveryCodes[0]}).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodDelete, "/api/auth/mfa", token, models.MFADisableRequest{Password: password, Code: regenerated.RecoveryCodes[0]}).expect(t, http.StatusOK)
+
+	status := data[models.MFAStatusResponse](t, s.call(t, http.MethodGet, "/api/auth/mfa", token, nil).expect(t, http.StatusOK))
+	if status.Enabled {
+		t.Fatalf("status = %+v, want disabled", status)
+	}
+
+	if login := s.login(t, "otter@example.com", password); login.MFARequired {
+		t.Fatalf("login still requires a second factor")
+	}
+}
+
+func TestMFALockout(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+	secret, recoveryCodes := s.enableMFA(t, token)
+
+	// Guessing codes is limited on every endpoint that asks for one, not only
+	// on login.
+	for range 5 {
+		s.call(t, http.MethodPost, "/api/auth/mfa/recovery-codes", token, models.MFACodeRequest{Code: "000000"}).expect(t, http.StatusUnauthorized)
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/mfa/recovery-codes", token, models.MFACodeRequest{Code: nextCode(t, secret)}).expect(t, http.StatusTooManyRequests)
+	s.call(t, http.MethodDelete, "/api/auth/mfa", token, models.MFADisableRequest{Password: password, Code: recoveryCodes[0]}).expect(t, http.StatusTooManyRequests)
+
+	mfaToken := s.login(t, "otter@example.com", password).MFAToken
+	s.call(t, http.MethodPost, "/api/auth/mfa/verify", "", models.MFAVerifyRequest{MFAToken: mfaToken, Code: recoveryCodes[0]}).expect(t, http.StatusTooManyRequests)
+}
+
+func TestMFARequiredRoles(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	s.createUser(t, admin, "Owner", "owner@example.com", models.RoleOwner)
+	token := s.login(t, "owner@example.com", password).AccessToken
+
+	s.call(t, http.MethodGet, "/api/users", token, nil).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusForbidden)
+
+	status := data[models.MFAStatusResponse](t, s.call(t, http.MethodGet, "/api/auth/mfa", token, nil).expect(t, http.StatusOK))
+	if !status.Required {
+		t.Fatalf("status = %+v, want required", status)
+	}
+
+	_, recoveryCodes := s.enableMFA(t, token)
+
+	s.call(t, http.MethodGet, "/api/users", token, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)
+
+	s.call(t, http.MethodDelete, "/api/auth/mfa", token, models.MFADisableRequest{Password: password, Code: recoveryCodes[0]}).expect(t, http.StatusForbidden)
+}
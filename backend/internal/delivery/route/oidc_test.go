@@ -0,0 +1,135 @@
+package route_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/oidc/oidctest"
+)
+
+// finishOIDC approves the authorization at the provider and follows the
+// callback with the state cookie set by start, like a browser would.
+func (s *testServer) finishOIDC(t *testing.T, start response, authURL string) response {
+	t.Helper()
+
+	var state *http.Cookie
+	for _, cookie := range (&http.Response{Header: start.header}).Cookies() {
+		if cookie.Name == "otterly_oidc_state" {
+			state = cookie
+		}
+	}
+	if state == nil {
+		t.Fatalf("no state cookie was set")
+	}
+
+	callback, err := s.provider.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	request := s.newRequest(t, http.MethodGet, "/api/auth/oidc/google/callback?"+callback.RawQuery, "", nil)
+	request.AddCookie(&http.Cookie{Name: state.Name, Value: state.Value})
+
+	return s.send(t, request).expect(t, http.StatusFound)
+}
+
+// oidcLogin signs in through the provider and returns where the app was
+// redirected to and the session cookie, if any.
+func (s *testServer) oidcLogin(t *testing.T) (string, string) {
+	t.Helper()
+
+	start := s.call(t, http.MethodGet, "/api/auth/oidc/google/login", "", nil).expect(t, http.StatusFound)
+	callback := s.finishOIDC(t, start, start.header.Get("Location"))
+
+	token := ""
+	for _, cookie := range (&http.Response{Header: callback.header}).Cookies() {
+		if cookie.Name == "otterly_token" {
+			token = cookie.Value
+		}
+	}
+
+	return callback.header.Get("Location"), token
+}
+
+func TestOIDCLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	s.call(t, http.MethodGet, "/api/auth/oidc/github/login", "", nil).expect(t, http.StatusNotFound)
+
+	s.provider.SetIdentity(oidctest.Identity{Subject: "unverified", Email: "lutra@example.com", Name: "Lutra"})
+	if location, token := s.oidcLogin(t); location != appURL+"/login?error=unverified_email" || token != "" {
+		t.Fatalf("unverified login redirected to %q, want the unverified_email error", location)
+	}
+
+	s.provider.SetIdentity(oidctest.Identity{Subject: "lutra", Email: "lutra@example.com", EmailVerified: true, Name: "Lutra"})
+
+	location, token := s.oidcLogin(t)
+	if location != appURL+"/" || token == "" {
+		t.Fatalf("login redirected to %q, want %s/ with a session", location, appURL)
+	}
+
+	created := data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK))
+	if created.Email != "lutra@example.com" {
+		t.Fatalf("email = %q, want lutra@example.com", created.Email)
+	}
+
+	// The identity signs in to the same account from now on.
+	_, token = s.oidcLogin(t)
+	if got := data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)); got.ID != created.ID {
+		t.Fatalf("second login signed in to %s, want %s", got.ID, created.ID)
+	}
+
+	// A callback without the state cookie is rejected.
+	start := s.call(t, http.MethodGet, "/api/auth/oidc/google/login", "", nil).expect(t, http.StatusFound)
+	callback, err := s.provider.Authorize(start.header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	rejected := s.call(t, http.MethodGet, "/api/auth/oidc/google/callback?"+callback.RawQuery, "", nil).expect(t, http.StatusFound)
+	if location := rejected.header.Get("Location"); location != appURL+"/login?error=invalid_state" {
+		t.Fatalf("callback without state redirected to %q, want the invalid_state error", location)
+	}
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register(t, "Otter", "otter@example.com")
+
+	s.provider.SetIdentity(oidctest.Identity{Subject: "otter", Email: "otter@example.com", EmailVerified: true, Name: "Otter"})
+
+	_, token := s.oidcLogin(t)
+	if got := data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)); got.ID != user.ID {
+		t.Fatalf("login signed in to %s, want the registered account %s", got.ID, user.ID)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	s := newTestServer(t)
+	s.register(t, "Otter", "otter@example.com")
+	session := s.login(t, "otter@example.com", password)
+
+	s.call(t, http.MethodPost, "/api/auth/oidc/github/link", session.AccessToken, nil).expect(t, http.StatusNotFound)
+
+	start := s.call(t, http.MethodPost, "/api/auth/oidc/google/link", session.AccessToken, nil).expect(t, http.StatusOK)
+	authURL := data[models.OIDCLinkResponse](t, start).AuthorizationURL
+
+	if _, err := url.Parse(authURL); err != nil || authURL == "" {
+		t.Fatalf("authorization_url = %q, want a URL", authURL)
+	}
+
+	// The provider address differs from the account's.
+	s.provider.SetIdentity(oidctest.Identity{Subject: "otter-google", Email: "otter@gmail.example", EmailVerified: true, Name: "Otter"})
+
+	linked := s.finishOIDC(t, start, authURL)
+	if location := linked.header.Get("Location"); location != appURL+"/?linked=google" {
+		t.Fatalf("link redirected to %q, want %s/?linked=google", location, appURL)
+	}
+
+	_, token := s.oidcLogin(t)
+	if got := data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)); got.Email != "otter@example.com" {
+		t.Fatalf("linked identity signed in as %q, want otter@example.com", got.Email)
+	}
+}
@@ -16,17 +16,22 @@ import (
 )
 
 type RouteConfig struct {
-	App             chi.Router
-	Log             *zap.Logger
-	ResponseHandler *helpers.ResponseHandler
-	UserController  *controllers.UserController
-	AuthController  *controllers.AuthController
-	AuthMiddleware  *middlewares.AuthMiddleware
+	App              chi.Router
+	Log              *zap.Logger
+	ResponseHandler  *helpers.ResponseHandler
+	UserController   *controllers.UserController
+	AuthController   *controllers.AuthController
+	TokenController  *controllers.TokenController
+	MFAController    *controllers.MFAController
+	OIDCController   *controllers.OIDCController
+	AuthMiddleware   *middlewares.AuthMiddleware
+	MFARequiredRoles []models.UserRole
 }
 
 func (c *RouteConfig) Setup() {
 	c.SetupAPIRoutes()
 	c.SetupHealthCheckRoute()
+	c.SetupWellKnownRoute()
 	c.SetupDefaultRoute()
 	c.SetupSwaggerRoute()
 }
@@ -36,26 +41,102 @@ func (c *RouteConfig) SetupAPIRoutes() {
 		r.Route("/auth", func(r chi.Router) {
 			r.Post("/register", c.AuthController.Register)
 			r.Post("/login", c.AuthController.Login)
+			r.Post("/refresh", c.AuthController.Refresh)
+			r.Post("/verify-email", c.AuthController.VerifyEmail)
+			r.Post("/verify-email/resend", c.AuthController.ResendVerification)
+			r.Post("/forgot-password", c.AuthController.ForgotPassword)
+			r.Post("/reset-password", c.AuthController.ResetPassword)
+			r.Post("/mfa/verify", c.MFAController.Verify)
+			r.Get("/oidc/{provider}/login", c.OIDCController.Login)
+			r.Get("/oidc/{provider}/callback", c.OIDCController.Callback)
 
 			r.Group(func(r chi.Router) {
 				r.Use(c.AuthMiddleware.Authenticate)
-				r.Get("/me", c.AuthController.GetAuthenticatedUser)
-				r.Post("/logout", c.AuthController.Logout)
+
+				// Reachable before enrolling, so users whose role requires two
+				// factor authentication can set it up or sign out.
+				r.Group(func(r chi.Router) {
+					r.Use(c.AuthMiddleware.RequireSession)
+					r.Post("/logout", c.AuthController.Logout)
+					r.Get("/mfa", c.MFAController.GetStatus)
+
+					r.Group(func(r chi.Router) {
+						r.Use(c.AuthMiddleware.RejectImpersonation)
+						r.Post("/logout-all", c.AuthController.LogoutAll)
+						r.Post("/mfa/enroll", c.MFAController.Enroll)
+						r.Post("/mfa/confirm", c.MFAController.Confirm)
+						r.Post("/mfa/recovery-codes", c.MFAController.RegenerateRecoveryCodes)
+						r.Delete("/mfa", c.MFAController.Disable)
+					})
+				})
+
+				r.Group(func(r chi.Router) {
+					r.Use(c.AuthMiddleware.RequireMFAEnrollment(c.MFARequiredRoles...))
+					r.With(c.AuthMiddleware.RequirePermission(models.PermissionProfileRead)).Get("/me", c.AuthController.GetAuthenticatedUser)
+					r.With(c.AuthMiddleware.RequirePermission(models.PermissionProfileWrite)).Patch("/me", c.AuthController.UpdateProfile)
+
+					r.Group(func(r chi.Router) {
+						r.Use(c.AuthMiddleware.RequireSession)
+						r.Use(c.AuthMiddleware.RejectImpersonation)
+						r.Post("/me/password", c.AuthController.ChangePassword)
+						r.Delete("/me", c.AuthController.DeleteAccount)
+						r.Post("/oidc/{provider}/link", c.OIDCController.Link)
+					})
+				})
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Use(c.AuthMiddleware.Authenticate)
+			r.Use(c.AuthMiddleware.RequireMFAEnrollment(c.MFARequiredRoles...))
 
-			r.Get("/", c.UserController.GetUsers)
-			r.Get("/{id}", c.UserController.GetUser)
+			r.Group(func(r chi.Router) {
+				r.Use(c.AuthMiddleware.RequirePermission(models.PermissionUsersRead))
+				r.Get("/", c.UserController.GetUsers)
+				r.Get("/{id}", c.UserController.GetUser)
+			})
 
 			r.Group(func(r chi.Router) {
-				r.Use(c.AuthMiddleware.RequireRole(models.RoleAdmin))
+				r.Use(c.AuthMiddleware.RequirePermission(models.PermissionUsersReadPrivate))
+				r.Get("/search", c.UserController.SearchUsers)
+				r.Get("/export", c.UserController.ExportUsers)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(c.AuthMiddleware.RequirePermission(models.PermissionUsersWrite))
 				r.Post("/", c.UserController.CreateUser)
-				r.Patch("/{id}", c.UserController.UpdateUser)
+				r.Post("/import", c.UserController.ImportUsers)
+				r.Post("/{id}/logout", c.UserController.ForceLogout)
+			})
+
+			// Ownership is checked by the controller, users may edit themselves.
+			r.Patch("/{id}", c.UserController.UpdateUser)
+
+			r.Group(func(r chi.Router) {
+				r.Use(c.AuthMiddleware.RequirePermission(models.PermissionUsersDelete))
 				r.Delete("/{id}", c.UserController.DeleteUser)
+				r.Get("/deleted", c.UserController.GetDeletedUsers)
+				r.Post("/{id}/restore", c.UserController.RestoreUser)
+				r.Delete("/{id}/purge", c.UserController.PurgeUser)
 			})
+
+			r.With(c.AuthMiddleware.RequirePermission(models.PermissionUsersManageRoles)).Put("/{id}/role", c.UserController.UpdateUserRole)
+			r.With(
+				c.AuthMiddleware.RequireSession,
+				c.AuthMiddleware.RejectImpersonation,
+				c.AuthMiddleware.RequirePermission(models.PermissionUsersImpersonate),
+			).Post("/{id}/impersonate", c.UserController.Impersonate)
+		})
+
+		r.Route("/tokens", func(r chi.Router) {
+			r.Use(c.AuthMiddleware.Authenticate)
+			r.Use(c.AuthMiddleware.RequireSession)
+			r.Use(c.AuthMiddleware.RejectImpersonation)
+			r.Use(c.AuthMiddleware.RequireMFAEnrollment(c.MFARequiredRoles...))
+
+			r.Post("/", c.TokenController.CreateToken)
+			r.Get("/", c.TokenController.GetTokens)
+			r.Delete("/{id}", c.TokenController.RevokeToken)
 		})
 	})
 }
@@ -72,6 +153,10 @@ func (c *RouteConfig) SetupHealthCheckRoute() {
 	})
 }
 
+func (c *RouteConfig) SetupWellKnownRoute() {
+	c.App.Get("/.well-known/jwks.json", c.AuthController.JWKS)
+}
+
 func (c *RouteConfig) SetupDefaultRoute() {
 	c.App.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		c.Log.Info("Route doesn't exist",
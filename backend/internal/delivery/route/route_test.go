@@ -0,0 +1,446 @@
+package route_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/otterly-id/otterly/backend/db/dbtest"
+	"github.com/otterly-id/otterly/backend/internal/api/controllers"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/configs"
+	"github.com/otterly-id/otterly/backend/internal/delivery/middlewares"
+	"github.com/otterly-id/otterly/backend/internal/delivery/route"
+	"github.com/otterly-id/otterly/backend/internal/helpers"
+	"github.com/otterly-id/otterly/backend/internal/mailer"
+	"github.com/otterly-id/otterly/backend/internal/oidc"
+	"github.com/otterly-id/otterly/backend/internal/oidc/oidctest"
+	"github.com/otterly-id/otterly/backend/internal/policy"
+	"github.com/otterly-id/otterly/backend/internal/store"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	appURL   = "http://app.otterly.test"
+	password = "Otter-Password-123"
+
+	adminEmail = "admin@example.com"
+)
+
+// testServer serves the routes of RouteConfig on top of an in-memory
+// repository, with a fake mailer and identity provider.
+type testServer struct {
+	*httptest.Server
+
+	db       *dbtest.Memory
+	mail     *mailbox
+	provider *oidctest.Server
+	client   *http.Client
+}
+
+// testSettings are the token lifetimes of a test server, options of
+// newTestServer may change them.
+type testSettings struct {
+	accessTokenDuration   time.Duration
+	impersonationDuration time.Duration
+}
+
+func newTestServer(t *testing.T, options ...func(*testSettings)) *testServer {
+	t.Helper()
+
+	settings := testSettings{
+		accessTokenDuration:   15 * time.Minute,
+		impersonationDuration: 15 * time.Minute,
+	}
+	for _, option := range options {
+		option(&settings)
+	}
+
+	log := zap.NewNop()
+	validate := configs.NewValidator()
+	repository := dbtest.NewMemory()
+	mail := &mailbox{messages: make(chan mailer.Message, 100)}
+
+	jwtManager := utils.NewJWTManager(
+		utils.NewHMACKeySet([]byte("route-test-secret-route-test-secret")),
+		"otterly-backend",
+		"otterly-users",
+		settings.accessTokenDuration,
+		720*time.Hour,
+	)
+
+	secrets, err := utils.NewSecretBox(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecretBox: %v", err)
+	}
+
+	provider := oidctest.NewServer("otterly", "secret")
+	t.Cleanup(provider.Close)
+
+	revocations := store.NewMemoryRevocationStore()
+	attempts := store.NewMemoryAttemptStore()
+	permissions := policy.NewRolePermissions(repository, 0)
+	mfaRequiredRoles := []models.UserRole{models.RoleOwner}
+
+	throttle := controllers.LoginThrottleSettings{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      100,
+		FailureWindow:      time.Minute,
+		LockoutDuration:    time.Minute,
+	}
+
+	providers := map[string]*oidc.Provider{
+		"google": oidc.NewProvider(oidc.Config{
+			Issuer:       provider.Issuer(),
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  "http://otterly.test/api/auth/oidc/google/callback",
+		}, provider.Client()),
+	}
+
+	responseHandler := helpers.NewHandler(log)
+	config := route.RouteConfig{
+		App:             chi.NewRouter(),
+		Log:             log,
+		ResponseHandler: responseHandler,
+		UserController: controllers.NewUserController(log, validate, repository, jwtManager, revocations, permissions, mail, controllers.UserSettings{
+			AppURL:                    appURL,
+			ImpersonationDuration:     settings.impersonationDuration,
+			EmailVerificationDuration: time.Hour,
+			InviteDuration:            72 * time.Hour,
+			PurgeRetention:            30 * 24 * time.Hour,
+		}),
+		AuthController: controllers.NewAuthController(log, validate, repository, jwtManager, revocations, attempts, mail, controllers.AuthSettings{
+			AppURL:                    appURL,
+			EmailVerificationDuration: time.Hour,
+			PasswordResetDuration:     time.Hour,
+			MFATokenDuration:          5 * time.Minute,
+			ImpersonationDuration:     settings.impersonationDuration,
+			LoginThrottle:             throttle,
+		}),
+		TokenController: controllers.NewTokenController(log, validate, repository, permissions),
+		MFAController: controllers.NewMFAController(log, validate, repository, jwtManager, attempts, secrets, controllers.MFASettings{
+			Issuer:        "Otterly",
+			RequiredRoles: mfaRequiredRoles,
+			Throttle:      throttle,
+		}),
+		OIDCController: controllers.NewOIDCController(log, repository, jwtManager, providers, controllers.OIDCSettings{
+			AppURL:           appURL,
+			MFATokenDuration: 5 * time.Minute,
+		}),
+		AuthMiddleware: middlewares.NewAuthMiddleware(repository, jwtManager, revocations, permissions,
+			[]middlewares.TokenSource{middlewares.TokenSourceHeader}, responseHandler, log),
+		MFARequiredRoles: mfaRequiredRoles,
+	}
+	config.Setup()
+
+	server := httptest.NewServer(config.App)
+	t.Cleanup(server.Close)
+
+	return &testServer{
+		Server:   server,
+		db:       repository,
+		mail:     mail,
+		provider: provider,
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// mailbox collects the messages sent by the API. Some are sent from
+// goroutines, so they are read with a timeout.
+type mailbox struct {
+	messages chan mailer.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, message mailer.Message) error {
+	m.messages <- message
+	return nil
+}
+
+func (m *mailbox) next(t *testing.T) mailer.Message {
+	t.Helper()
+
+	select {
+	case message := <-m.messages:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no email was sent")
+		return mailer.Message{}
+	}
+}
+
+func (m *mailbox) empty(t *testing.T) {
+	t.Helper()
+
+	select {
+	case message := <-m.messages:
+		t.Fatalf("unexpected email %q to %s", message.Subject, message.To)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+// tokenOf returns the token of the link in message.
+func tokenOf(t *testing.T, message mailer.Message) string {
+	t.Helper()
+
+	match := linkToken.FindStringSubmatch(message.Body)
+	if match == nil {
+		t.Fatalf("email %q has no link with a token:\n%s", message.Subject, message.Body)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("QueryUnescape(%q): %v", match[1], err)
+	}
+
+	return token
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+type envelope struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    json.RawMessage `json:"meta"`
+	Errors  json.RawMessage `json:"errors"`
+}
+
+func (s *testServer) newRequest(t *testing.T, method, path, token string, body any) *http.Request {
+	t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return request
+}
+
+func (s *testServer) send(t *testing.T, request *http.Request) response {
+	t.Helper()
+
+	resp, err := s.client.Do(request)
+	if err != nil {
+		t.Fatalf("%s %s: %v", request.Method, request.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response of %s %s: %v", request.Method, request.URL.Path, err)
+	}
+
+	return response{status: resp.StatusCode, header: resp.Header, body: body}
+}
+
+// call sends a request with body encoded as JSON, or as is for strings.
+func (s *testServer) call(t *testing.T, method, path, token string, body any) response {
+	t.Helper()
+	return s.send(t, s.newRequest(t, method, path, token, body))
+}
+
+// expect fails the test unless the response has status.
+func (r response) expect(t *testing.T, status int) response {
+	t.Helper()
+
+	if r.status != status {
+		t.Fatalf("status = %d, want %d\n%s", r.status, status, r.body)
+	}
+
+	return r
+}
+
+func (r response) envelope(t *testing.T) envelope {
+	t.Helper()
+
+	var decoded envelope
+	if err := json.Unmarshal(r.body, &decoded); err != nil {
+		t.Fatalf("decoding envelope %q: %v", r.body, err)
+	}
+
+	return decoded
+}
+
+// data decodes the data of the response envelope.
+func data[T any](t *testing.T, r response) T {
+	t.Helper()
+
+	var decoded T
+	if err := json.Unmarshal(r.envelope(t).Data, &decoded); err != nil {
+		t.Fatalf("decoding data of %q: %v", r.body, err)
+	}
+
+	return decoded
+}
+
+func meta(t *testing.T, r response) models.ListMeta {
+	t.Helper()
+
+	var decoded models.ListMeta
+	if err := json.Unmarshal(r.envelope(t).Meta, &decoded); err != nil {
+		t.Fatalf("decoding meta of %q: %v", r.body, err)
+	}
+
+	return decoded
+}
+
+// conflictingField decodes the field a failure response names.
+func conflictingField(t *testing.T, r response) string {
+	t.Helper()
+
+	var decoded models.FieldError
+	if err := json.Unmarshal(r.envelope(t).Errors, &decoded); err != nil {
+		t.Fatalf("decoding errors of %q: %v", r.body, err)
+	}
+
+	return decoded.Field
+}
+
+// register signs up a user through the API and verifies the address.
+func (s *testServer) register(t *testing.T, name, email string) models.RegisterResponse {
+	t.Helper()
+
+	user := data[models.RegisterResponse](t, s.call(t, http.MethodPost, "/api/auth/register", "", models.RegisterRequest{
+		Name:     name,
+		Email:    email,
+		Password: password,
+	}).expect(t, http.StatusCreated))
+
+	s.call(t, http.MethodPost, "/api/auth/verify-email", "", models.VerifyEmailRequest{
+		Token: tokenOf(t, s.mail.next(t)),
+	}).expect(t, http.StatusOK)
+
+	return user
+}
+
+// login signs in as a bearer client, which gets the refresh token in the
+// body.
+func (s *testServer) login(t *testing.T, email, password string) models.TokenResponse {
+	t.Helper()
+
+	request := s.newRequest(t, http.MethodPost, "/api/auth/login", "", models.LoginRequest{
+		Email:    email,
+		Password: password,
+	})
+	request.Header.Set("X-Token-Delivery", "body")
+
+	return data[models.TokenResponse](t, s.send(t, request).expect(t, http.StatusOK))
+}
+
+// loginAdmin creates the first admin, as the create-admin command does, and
+// returns an access token of theirs.
+func (s *testServer) loginAdmin(t *testing.T) string {
+	t.Helper()
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	if _, err := s.db.CreateFirstAdmin(context.Background(), &models.CreateAdminRequest{
+		Name:     "Admin",
+		Email:    adminEmail,
+		Password: hash,
+	}); err != nil {
+		t.Fatalf("CreateFirstAdmin: %v", err)
+	}
+
+	return s.login(t, adminEmail, password).AccessToken
+}
+
+// createUser creates a user through the API as the holder of token.
+func (s *testServer) createUser(t *testing.T, token, name, email string, role models.UserRole) models.CreateUserResponse {
+	t.Helper()
+
+	return data[models.CreateUserResponse](t, s.call(t, http.MethodPost, "/api/users", token, models.CreateUserRequest{
+		Name:     name,
+		Email:    email,
+		Password: password,
+		Role:     string(role),
+	}).expect(t, http.StatusCreated))
+}
+
+func TestServiceRoutes(t *testing.T) {
+	// The API reference reads docs/swagger.json from the working directory.
+	t.Chdir("../../..")
+
+	s := newTestServer(t)
+
+	tests := []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+	}{
+		{http.MethodGet, "/health-check", http.StatusOK, "application/json"},
+		{http.MethodGet, "/.well-known/jwks.json", http.StatusOK, "application/json"},
+		{http.MethodGet, "/", http.StatusOK, "text/html; charset=utf-8"},
+		{http.MethodGet, "/no-such-route", http.StatusNotFound, "application/json"},
+		{http.MethodPut, "/health-check", http.StatusMethodNotAllowed, "application/json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.method+" "+test.path, func(t *testing.T) {
+			r := s.call(t, test.method, test.path, "", nil).expect(t, test.status)
+
+			if contentType := r.header.Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("Content-Type = %q, want %q", contentType, test.contentType)
+			}
+		})
+	}
+}
+
+func TestProtectedRoutesRequireAuthentication(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, path := range []string{"/api/auth/me", "/api/auth/mfa", "/api/users", "/api/tokens"} {
+		r := s.call(t, http.MethodGet, path, "", nil).expect(t, http.StatusUnauthorized)
+		if r.envelope(t).Success {
+			t.Fatalf("GET %s succeeded without a token", path)
+		}
+
+		s.call(t, http.MethodGet, path, "not-a-token", nil).expect(t, http.StatusUnauthorized)
+	}
+}
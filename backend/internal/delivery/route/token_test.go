@@ -0,0 +1,106 @@
+package route_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+func TestAPITokens(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+
+	s.call(t, http.MethodPost, "/api/tokens", admin, models.CreateAPITokenRequest{
+		Name:   "CI",
+		Scopes: []string{"users:everything"},
+	}).expect(t, http.StatusBadRequest)
+
+	created := data[models.CreateAPITokenResponse](t, s.call(t, http.MethodPost, "/api/tokens", admin, models.CreateAPITokenRequest{
+		Name:          "CI",
+		Scopes:        []string{models.PermissionProfileRead, models.PermissionUsersRead},
+		ExpiresInDays: 30,
+	}).expect(t, http.StatusCreated))
+
+	if created.Token == "" || created.ExpiresAt == nil {
+		t.Fatalf("created = %+v, want a token with an expiry", created)
+	}
+
+	tokens := data[[]models.APITokenResponse](t, s.call(t, http.MethodGet, "/api/tokens", admin, nil).expect(t, http.StatusOK))
+	if len(tokens) != 1 || tokens[0].ID != created.ID {
+		t.Fatalf("tokens = %+v, want only %s", tokens, created.ID)
+	}
+
+	// The token is limited to its scopes and cannot manage tokens itself.
+	s.call(t, http.MethodGet, "/api/auth/me", created.Token, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, "/api/users", created.Token, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, "/api/users/search?q=admin", created.Token, nil).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodGet, "/api/tokens", created.Token, nil).expect(t, http.StatusForbidden)
+
+	s.call(t, http.MethodDelete, "/api/tokens/not-a-uuid", admin, nil).expect(t, http.StatusBadRequest)
+	s.call(t, http.MethodDelete, "/api/tokens/"+created.ID.String(), admin, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodDelete, "/api/tokens/"+created.ID.String(), admin, nil).expect(t, http.StatusNotFound)
+
+	s.call(t, http.MethodGet, "/api/auth/me", created.Token, nil).expect(t, http.StatusUnauthorized)
+}
+
+func TestAPITokensOfOtherUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	service := s.createUser(t, admin, "Service", "service@example.com", models.RoleUser)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	// Scopes never exceed the role of the owner.
+	refused := s.call(t, http.MethodPost, "/api/tokens", token, models.CreateAPITokenRequest{
+		Name:   "Script",
+		Scopes: []string{models.PermissionUsersDelete},
+	}).expect(t, http.StatusForbidden)
+
+	if detail := string(refused.envelope(t).Errors); !strings.Contains(detail, models.PermissionUsersDelete) {
+		t.Fatalf("errors = %s, want the refused scope", detail)
+	}
+
+	request := models.CreateAPITokenRequest{
+		Name:   "Service",
+		Scopes: []string{models.PermissionProfileRead},
+		UserID: &service.ID,
+	}
+
+	s.call(t, http.MethodPost, "/api/tokens", token, request).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodGet, "/api/tokens?user_id="+service.ID.String(), token, nil).expect(t, http.StatusForbidden)
+
+	// Acting for another user takes tokens:manage, which is granted through
+	// the role.
+	s.db.SetRolePermissions(models.RoleUser, models.PermissionProfileRead, models.PermissionUsersRead, models.PermissionTokensManage)
+
+	created := data[models.CreateAPITokenResponse](t, s.call(t, http.MethodPost, "/api/tokens", token, request).expect(t, http.StatusCreated))
+
+	tokens := data[[]models.APITokenResponse](t, s.call(t, http.MethodGet, "/api/tokens?user_id="+service.ID.String(), token, nil).expect(t, http.StatusOK))
+	if len(tokens) != 1 || tokens[0].ID != created.ID || tokens[0].UserID != service.ID {
+		t.Fatalf("tokens = %+v, want %s of %s", tokens, created.ID, service.ID)
+	}
+
+	if id := s.userID(t, created.Token); id != service.ID.String() {
+		t.Fatalf("token acts as %s, want %s", id, service.ID)
+	}
+
+	// Only the token created for someone else is audited.
+	s.call(t, http.MethodPost, "/api/tokens", token, models.CreateAPITokenRequest{
+		Name:   "Own",
+		Scopes: []string{models.PermissionProfileRead},
+	}).expect(t, http.StatusCreated)
+
+	var audited []models.AuditLog
+	for _, entry := range s.db.AuditLogs() {
+		if entry.Action == models.AuditAPITokenCreated {
+			audited = append(audited, entry)
+		}
+	}
+
+	if len(audited) != 1 || audited[0].ActorID == nil || audited[0].ActorID.String() != s.userID(t, token) ||
+		*audited[0].TargetID != service.ID || audited[0].Details["token_id"] != created.ID {
+		t.Fatalf("audit logs = %+v, want one entry for token %s of %s", audited, created.ID, service.ID)
+	}
+}
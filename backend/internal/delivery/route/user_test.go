@@ -0,0 +1,504 @@
+package route_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/utils"
+)
+
+func TestListUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		s.createUser(t, admin, "Otter "+name, name+"@example.com", models.RoleUser)
+	}
+
+	first := s.call(t, http.MethodGet, "/api/users?limit=2&sort=email", admin, nil).expect(t, http.StatusOK)
+	users := data[[]models.UserResponse](t, first)
+	page := meta(t, first)
+
+	if len(users) != 2 || page.Total != 4 || page.NextCursor == "" {
+		t.Fatalf("first page = %d users of %d, next cursor %q, want 2 of 4 with a cursor", len(users), page.Total, page.NextCursor)
+	}
+
+	if users[0].Email != "a@example.com" || users[1].Email != adminEmail {
+		t.Fatalf("first page = %s, %s, want a@example.com, %s", users[0].Email, users[1].Email, adminEmail)
+	}
+
+	second := s.call(t, http.MethodGet, "/api/users?limit=2&sort=email&cursor="+page.NextCursor, admin, nil).expect(t, http.StatusOK)
+	users = data[[]models.UserResponse](t, second)
+
+	if len(users) != 2 || users[0].Email != "b@example.com" || meta(t, second).NextCursor != "" {
+		t.Fatalf("second page = %+v, want b@example.com and c@example.com without a cursor", users)
+	}
+
+	filtered := data[[]models.UserResponse](t, s.call(t, http.MethodGet, "/api/users?role=ADMIN", admin, nil).expect(t, http.StatusOK))
+	if len(filtered) != 1 || filtered[0].Email != adminEmail {
+		t.Fatalf("role=ADMIN = %+v, want only the admin", filtered)
+	}
+
+	for _, query := range []string{"limit=0", "sort=password", "role=ROOT", "cursor=invalid"} {
+		s.call(t, http.MethodGet, "/api/users?"+query, admin, nil).expect(t, http.StatusBadRequest)
+	}
+
+	byDate := meta(t, s.call(t, http.MethodGet, "/api/users?limit=2", admin, nil).expect(t, http.StatusOK))
+	s.call(t, http.MethodGet, "/api/users?limit=2&cursor="+byDate.NextCursor, admin, nil).expect(t, http.StatusOK)
+
+	// Cursors that decode but hold a value the sort column cannot be compared
+	// with never reach the database.
+	for _, cursor := range []models.Cursor{
+		{Sort: "created_at", Value: "yesterday", ID: uuid.New()},
+		{Sort: "email", Value: "a\x00", ID: uuid.New()},
+	} {
+		s.call(t, http.MethodGet, "/api/users?sort="+cursor.Sort+"&cursor="+cursor.Encode(), admin, nil).expect(t, http.StatusBadRequest)
+	}
+
+	relevance := models.Cursor{Sort: "-relevance", Value: "high", ID: uuid.New()}
+	s.call(t, http.MethodGet, "/api/users/search?q=otter&cursor="+relevance.Encode(), admin, nil).expect(t, http.StatusBadRequest)
+}
+
+func TestUserProjection(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	other := s.createUser(t, admin, "Other", "other@example.com", models.RoleUser)
+	s.register(t, "Otter", "otter@example.com")
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	var users []map[string]any
+	if err := json.Unmarshal(s.call(t, http.MethodGet, "/api/users", token, nil).expect(t, http.StatusOK).envelope(t).Data, &users); err != nil {
+		t.Fatalf("decoding users: %v", err)
+	}
+
+	for _, user := range users {
+		_, hasEmail := user["email"]
+		if own := user["id"] == s.userID(t, token); hasEmail != own {
+			t.Fatalf("user %v has email = %t, want %t", user["id"], hasEmail, own)
+		}
+	}
+
+	if got := s.call(t, http.MethodGet, "/api/users/"+other.ID.String(), token, nil).expect(t, http.StatusOK); bytes.Contains(got.body, []byte("other@example.com")) {
+		t.Fatalf("GET /api/users/{id} revealed the email of another user: %s", got.body)
+	}
+
+	s.call(t, http.MethodGet, "/api/users?email=other@example.com", token, nil).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodGet, "/api/users?sort=email", token, nil).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodGet, "/api/users/search?q=other", token, nil).expect(t, http.StatusForbidden)
+
+	// Permissions come from the role_permissions table, not from the code.
+	s.db.SetRolePermissions(models.RoleUser, models.PermissionProfileRead, models.PermissionUsersRead, models.PermissionUsersReadPrivate)
+
+	got := data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/users/"+other.ID.String(), token, nil).expect(t, http.StatusOK))
+	if got.Email != "other@example.com" {
+		t.Fatalf("email = %q after granting %s, want other@example.com", got.Email, models.PermissionUsersReadPrivate)
+	}
+
+	s.call(t, http.MethodGet, "/api/users?email=other@example.com", token, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodPatch, "/api/auth/me", token, models.UpdateUserRequest{}).expect(t, http.StatusForbidden)
+}
+
+// userID returns the id of the holder of token.
+func (s *testServer) userID(t *testing.T, token string) string {
+	t.Helper()
+	return data[models.UserResponse](t, s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusOK)).ID.String()
+}
+
+func TestSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	s.createUser(t, admin, "Lutra", "lutra@example.com", models.RoleUser)
+	s.createUser(t, admin, "Enhydra", "enhydra@example.com", models.RoleUser)
+
+	results := data[[]models.UserSearchResult](t, s.call(t, http.MethodGet, "/api/users/search?q=lutra", admin, nil).expect(t, http.StatusOK))
+	if len(results) != 1 || results[0].Email != "lutra@example.com" {
+		t.Fatalf("search = %+v, want only lutra@example.com", results)
+	}
+
+	if results[0].Highlights["name"] != "<mark>Lutra</mark>" {
+		t.Fatalf("highlights = %v, want the name marked", results[0].Highlights)
+	}
+
+	s.call(t, http.MethodGet, "/api/users/search", admin, nil).expect(t, http.StatusBadRequest)
+}
+
+func TestExportUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	otter := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+
+	formula := `=HYPERLINK("https://example.com","Otter")`
+	s.call(t, http.MethodPatch, "/api/users/"+otter.ID.String(), admin, models.UpdateUserRequest{FullName: &formula}).expect(t, http.StatusCreated)
+
+	exported := s.call(t, http.MethodGet, "/api/users/export", admin, nil).expect(t, http.StatusOK)
+	if contentType := exported.header.Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Fatalf("Content-Type = %q, want text/csv; charset=utf-8", contentType)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(exported.body)).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV export: %v", err)
+	}
+
+	if len(records) != 3 || records[0][3] != "email" || records[2][3] != "otter@example.com" {
+		t.Fatalf("CSV export = %v, want a header, the admin and otter@example.com", records)
+	}
+
+	if records[2][2] != "'"+formula {
+		t.Fatalf("full_name = %q, want the formula escaped", records[2][2])
+	}
+
+	exported = s.call(t, http.MethodGet, "/api/users/export?format=ndjson&role=USER", admin, nil).expect(t, http.StatusOK)
+
+	var users []models.ExportUser
+	scanner := bufio.NewScanner(bytes.NewReader(exported.body))
+	for scanner.Scan() {
+		var user models.ExportUser
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			t.Fatalf("decoding %q: %v", scanner.Text(), err)
+		}
+		users = append(users, user)
+	}
+
+	if len(users) != 1 || users[0].Email != "otter@example.com" {
+		t.Fatalf("NDJSON export = %+v, want only otter@example.com", users)
+	}
+
+	s.call(t, http.MethodGet, "/api/users/export?format=xml", admin, nil).expect(t, http.StatusBadRequest)
+}
+
+func TestCreateAndUpdateUser(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+
+	s.call(t, http.MethodPost, "/api/users", admin, models.CreateUserRequest{
+		Name:     "Otter",
+		Email:    "otter@example.com",
+		Password: password,
+		Role:     string(models.RoleAdmin),
+	}).expect(t, http.StatusBadRequest)
+
+	user := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+
+	conflict := s.call(t, http.MethodPost, "/api/users", admin, models.CreateUserRequest{
+		Name:     "Lutra",
+		Email:    "otter@example.com",
+		Password: password,
+		Role:     string(models.RoleUser),
+	}).expect(t, http.StatusConflict)
+
+	if field := conflictingField(t, conflict); field != "email" {
+		t.Fatalf("conflicting field = %q, want email", field)
+	}
+
+	path := "/api/users/" + user.ID.String()
+	etag := s.call(t, http.MethodGet, path, admin, nil).expect(t, http.StatusOK).header.Get("ETag")
+
+	name := "Lutra"
+	request := s.newRequest(t, http.MethodPatch, path, admin, models.UpdateUserRequest{Name: &name})
+	request.Header.Set("If-Match", etag)
+	updated := data[models.UpdateUserResponse](t, s.send(t, request).expect(t, http.StatusCreated))
+
+	if updated.Name != name {
+		t.Fatalf("name = %q, want %q", updated.Name, name)
+	}
+
+	// Taking the address of another user conflicts.
+	taken := s.createUser(t, admin, "Sea Otter", "sea@example.com", models.RoleUser).Email
+	conflict = s.call(t, http.MethodPatch, path, admin, models.UpdateUserRequest{Email: &taken}).expect(t, http.StatusConflict)
+
+	if field := conflictingField(t, conflict); field != "email" {
+		t.Fatalf("conflicting field = %q, want email", field)
+	}
+
+	// The ETag is stale now.
+	request = s.newRequest(t, http.MethodPatch, path, admin, models.UpdateUserRequest{Name: &name})
+	request.Header.Set("If-Match", etag)
+	s.send(t, request).expect(t, http.StatusPreconditionFailed)
+
+	// Users may only edit themselves.
+	token := s.login(t, "otter@example.com", password).AccessToken
+	s.call(t, http.MethodPatch, "/api/users/"+s.userID(t, admin), token, models.UpdateUserRequest{Name: &name}).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodPatch, path, token, models.UpdateUserRequest{Name: &name}).expect(t, http.StatusCreated)
+
+	s.call(t, http.MethodPost, "/api/users", token, models.CreateUserRequest{
+		Name:     "Otter",
+		Email:    "new@example.com",
+		Password: password,
+		Role:     string(models.RoleUser),
+	}).expect(t, http.StatusForbidden)
+
+	// Renaming sends nothing, a corrected address has to be verified again
+	// and gets a link to do so.
+	s.mail.empty(t)
+
+	email := "lutra@example.com"
+	s.call(t, http.MethodPatch, path, admin, models.UpdateUserRequest{Email: &email}).expect(t, http.StatusCreated)
+
+	verification := s.mail.next(t)
+	if verification.To != email {
+		t.Fatalf("verification sent to %q, want %q", verification.To, email)
+	}
+
+	account := data[models.AccountResponse](t, s.call(t, http.MethodPost, "/api/auth/verify-email", "", models.VerifyEmailRequest{
+		Token: tokenOf(t, verification),
+	}).expect(t, http.StatusOK))
+
+	if account.Email != email || account.EmailVerifiedAt == nil {
+		t.Fatalf("account = %+v, want %s verified", account, email)
+	}
+
+	s.call(t, http.MethodGet, "/api/users/not-a-uuid", admin, nil).expect(t, http.StatusBadRequest)
+	s.call(t, http.MethodGet, "/api/users/00000000-0000-0000-0000-000000000000", admin, nil).expect(t, http.StatusNotFound)
+}
+
+func TestImportUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+
+	rows := []models.ImportUserRow{
+		{Name: "Lutra", Email: "lutra@example.com", Role: string(models.RoleUser)},
+		{Name: "Enhydra", Email: "enhydra@example.com", Role: string(models.RoleOwner)},
+	}
+
+	checked := data[models.ImportUsersResponse](t, s.call(t, http.MethodPost, "/api/users/import?dry_run=true", admin, rows).expect(t, http.StatusOK))
+	if !checked.DryRun || checked.Created != 2 || len(checked.Users) != 0 {
+		t.Fatalf("dry run = %+v, want 2 users checked and none returned", checked)
+	}
+
+	s.call(t, http.MethodGet, "/api/users?role=OWNER", admin, nil).expect(t, http.StatusOK)
+	if total := meta(t, s.call(t, http.MethodGet, "/api/users", admin, nil)).Total; total != 1 {
+		t.Fatalf("total = %d after a dry run, want 1", total)
+	}
+
+	imported := data[models.ImportUsersResponse](t, s.call(t, http.MethodPost, "/api/users/import", admin, rows).expect(t, http.StatusCreated))
+	if imported.Created != 2 || imported.Invited != 2 {
+		t.Fatalf("import = %+v, want 2 users created and invited", imported)
+	}
+
+	invited := map[string]bool{}
+	for range 2 {
+		invited[s.mail.next(t).To] = true
+	}
+	if !invited["lutra@example.com"] || !invited["enhydra@example.com"] {
+		t.Fatalf("invites sent to %v, want both imported users", invited)
+	}
+
+	// Nothing is created when a row conflicts with an existing user.
+	request := s.newRequest(t, http.MethodPost, "/api/users/import", admin, "name,email,role\nOtter,otter@example.com,USER\nLutra,lutra@example.com,USER\n")
+	request.Header.Set("Content-Type", "text/csv")
+	rejected := s.send(t, request).expect(t, http.StatusBadRequest)
+
+	var rowErrors []models.ImportRowError
+	if err := json.Unmarshal(rejected.envelope(t).Errors, &rowErrors); err != nil {
+		t.Fatalf("decoding row errors: %v", err)
+	}
+
+	if len(rowErrors) != 1 || rowErrors[0].Row != 2 {
+		t.Fatalf("row errors = %+v, want one for row 2", rowErrors)
+	}
+
+	if total := meta(t, s.call(t, http.MethodGet, "/api/users", admin, nil)).Total; total != 3 {
+		t.Fatalf("total = %d after a rejected import, want 3", total)
+	}
+
+	// Users brought over with their password hash sign in with it right away
+	// and are not emailed.
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	s.call(t, http.MethodPost, "/api/users/import", admin, []models.ImportUserRow{
+		{Name: "Pteronura", Email: "pteronura@example.com", Role: string(models.RoleUser), PasswordHash: hash},
+	}).expect(t, http.StatusCreated)
+	s.mail.empty(t)
+
+	s.login(t, "pteronura@example.com", password)
+
+	var verified []models.ExportUser
+	scanner := bufio.NewScanner(bytes.NewReader(s.call(t, http.MethodGet, "/api/users/export?format=ndjson&role=USER", admin, nil).expect(t, http.StatusOK).body))
+	for scanner.Scan() {
+		var user models.ExportUser
+		if err := json.Unmarshal(scanner.Bytes(), &user); err != nil {
+			t.Fatalf("decoding export line: %v", err)
+		}
+		if user.EmailVerified {
+			verified = append(verified, user)
+		}
+	}
+
+	if len(verified) != 1 || verified[0].Email != "pteronura@example.com" {
+		t.Fatalf("verified users = %+v, want only pteronura@example.com", verified)
+	}
+
+	request = s.newRequest(t, http.MethodPost, "/api/users/import", admin, "<users/>")
+	request.Header.Set("Content-Type", "application/xml")
+	s.send(t, request).expect(t, http.StatusBadRequest)
+}
+
+func TestDeleteRestoreAndPurgeUser(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	user := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+	path := "/api/users/" + user.ID.String()
+	token := s.login(t, "otter@example.com", password).AccessToken
+	apiToken := data[models.CreateAPITokenResponse](t, s.call(t, http.MethodPost, "/api/tokens", token, models.CreateAPITokenRequest{
+		Name:   "CLI",
+		Scopes: []string{models.PermissionProfileRead},
+	}).expect(t, http.StatusCreated))
+
+	s.call(t, http.MethodDelete, path+"/purge", admin, nil).expect(t, http.StatusNotFound)
+	s.call(t, http.MethodDelete, path, admin, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, path, admin, nil).expect(t, http.StatusNotFound)
+
+	// Deleting signs the user out and revokes their tokens, restoring does not
+	// bring them back.
+	s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodGet, "/api/auth/me", apiToken.Token, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodDelete, path, admin, nil).expect(t, http.StatusNotFound)
+
+	deleted := data[[]models.DeletedUserResponse](t, s.call(t, http.MethodGet, "/api/users/deleted", admin, nil).expect(t, http.StatusOK))
+	if len(deleted) != 1 || deleted[0].ID != user.ID || deleted[0].PurgeAt == nil {
+		t.Fatalf("deleted = %+v, want %s with a purge date", deleted, user.ID)
+	}
+
+	restored := data[models.UserResponse](t, s.call(t, http.MethodPost, path+"/restore", admin, nil).expect(t, http.StatusOK))
+	if restored.Email != "otter@example.com" {
+		t.Fatalf("restored = %+v, want otter@example.com", restored)
+	}
+
+	s.call(t, http.MethodGet, "/api/auth/me", apiToken.Token, nil).expect(t, http.StatusUnauthorized)
+	s.login(t, "otter@example.com", password)
+	s.call(t, http.MethodPost, path+"/restore", admin, nil).expect(t, http.StatusNotFound)
+
+	s.call(t, http.MethodDelete, path, admin, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodDelete, path+"/purge", admin, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodPost, path+"/restore", admin, nil).expect(t, http.StatusNotFound)
+
+	if deleted := data[[]models.DeletedUserResponse](t, s.call(t, http.MethodGet, "/api/users/deleted", admin, nil).expect(t, http.StatusOK)); len(deleted) != 0 {
+		t.Fatalf("deleted = %+v after purging, want none", deleted)
+	}
+
+	deletions := 0
+	for _, entry := range s.db.AuditLogs() {
+		if entry.Action == models.AuditUserDeleted && entry.TargetID != nil && *entry.TargetID == user.ID {
+			deletions++
+		}
+	}
+
+	if deletions != 2 {
+		t.Fatalf("%d deletions of %s were audited, want 2", deletions, user.ID)
+	}
+
+	// The address can be registered again once the user is purged.
+	s.register(t, "Otter", "otter@example.com")
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	user := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+	session := s.login(t, "otter@example.com", password)
+	token := session.AccessToken
+
+	s.call(t, http.MethodPut, "/api/users/"+user.ID.String()+"/role", token, models.UpdateUserRoleRequest{Role: string(models.RoleAdmin)}).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodPut, "/api/users/"+user.ID.String()+"/role", admin, models.UpdateUserRoleRequest{Role: string(models.RoleUser)}).expect(t, http.StatusConflict)
+	s.call(t, http.MethodPut, "/api/users/"+s.userID(t, admin)+"/role", admin, models.UpdateUserRoleRequest{Role: string(models.RoleUser)}).expect(t, http.StatusConflict)
+
+	changed := data[models.UserRoleResponse](t, s.call(t, http.MethodPut, "/api/users/"+user.ID.String()+"/role", admin, models.UpdateUserRoleRequest{
+		Role: string(models.RoleAdmin),
+	}).expect(t, http.StatusOK))
+
+	if changed.Role != models.RoleAdmin || changed.PreviousRole != models.RoleUser {
+		t.Fatalf("role change = %+v, want USER to ADMIN", changed)
+	}
+
+	// Sessions issued for the old role end with the change.
+	s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodPost, "/api/auth/refresh", "", models.RefreshTokenRequest{RefreshToken: session.RefreshToken}).expect(t, http.StatusUnauthorized)
+}
+
+func TestLastAdmin(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	adminPath := "/api/users/" + s.userID(t, admin)
+
+	// The only admin can neither be deleted nor delete their own account.
+	s.call(t, http.MethodDelete, adminPath, admin, nil).expect(t, http.StatusConflict)
+	s.call(t, http.MethodDelete, "/api/auth/me", admin, models.DeleteAccountRequest{Password: password}).expect(t, http.StatusConflict)
+
+	other := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+	s.call(t, http.MethodPut, "/api/users/"+other.ID.String()+"/role", admin, models.UpdateUserRoleRequest{Role: string(models.RoleAdmin)}).expect(t, http.StatusOK)
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	s.call(t, http.MethodDelete, adminPath, token, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodDelete, "/api/users/"+other.ID.String(), token, nil).expect(t, http.StatusConflict)
+	s.call(t, http.MethodDelete, "/api/auth/me", token, models.DeleteAccountRequest{Password: password}).expect(t, http.StatusConflict)
+}
+
+func TestImpersonateAndForceLogout(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.loginAdmin(t)
+	user := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+	token := s.login(t, "otter@example.com", password).AccessToken
+
+	s.call(t, http.MethodPost, "/api/users/"+s.userID(t, admin)+"/impersonate", admin, nil).expect(t, http.StatusBadRequest)
+	s.call(t, http.MethodPost, "/api/users/"+s.userID(t, admin)+"/impersonate", token, nil).expect(t, http.StatusForbidden)
+
+	// Admins cannot act as each other.
+	other := s.createUser(t, admin, "Sea Otter", "sea@example.com", models.RoleUser)
+	s.call(t, http.MethodPut, "/api/users/"+other.ID.String()+"/role", admin, models.UpdateUserRoleRequest{Role: string(models.RoleAdmin)}).expect(t, http.StatusOK)
+	s.call(t, http.MethodPost, "/api/users/"+other.ID.String()+"/impersonate", admin, nil).expect(t, http.StatusForbidden)
+
+	impersonation := data[models.ImpersonationResponse](t, s.call(t, http.MethodPost, "/api/users/"+user.ID.String()+"/impersonate", admin, nil).expect(t, http.StatusCreated))
+	if impersonation.UserID != user.ID {
+		t.Fatalf("impersonated %s, want %s", impersonation.UserID, user.ID)
+	}
+
+	if id := s.userID(t, impersonation.AccessToken); id != user.ID.String() {
+		t.Fatalf("impersonation token acts as %s, want %s", id, user.ID)
+	}
+
+	s.call(t, http.MethodPost, "/api/auth/logout-all", impersonation.AccessToken, nil).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodPost, "/api/auth/me/password", impersonation.AccessToken, models.ChangePasswordRequest{
+		CurrentPassword: password,
+		NewPassword:     "New-Otter-Password-1",
+	}).expect(t, http.StatusForbidden)
+
+	// The profile may be edited, except for the email address.
+	email := "taken-over@example.com"
+	name := "Impersonated"
+	s.call(t, http.MethodPatch, "/api/auth/me", impersonation.AccessToken, models.UpdateUserRequest{Email: &email}).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodPatch, "/api/users/"+user.ID.String(), impersonation.AccessToken, models.UpdateUserRequest{Email: &email}).expect(t, http.StatusForbidden)
+	s.call(t, http.MethodPatch, "/api/auth/me", impersonation.AccessToken, models.UpdateUserRequest{Name: &name}).expect(t, http.StatusOK)
+
+	s.call(t, http.MethodPost, "/api/users/"+user.ID.String()+"/logout", admin, nil).expect(t, http.StatusOK)
+	s.call(t, http.MethodGet, "/api/auth/me", token, nil).expect(t, http.StatusUnauthorized)
+	s.call(t, http.MethodGet, "/api/auth/me", impersonation.AccessToken, nil).expect(t, http.StatusUnauthorized)
+}
+
+func TestForceLogoutOutlastsImpersonation(t *testing.T) {
+	// Impersonation tokens outlive sessions here, signing the user out has
+	// to end them all the same.
+	s := newTestServer(t, func(settings *testSettings) {
+		settings.accessTokenDuration = 2 * time.Second
+	})
+	admin := s.loginAdmin(t)
+	user := s.createUser(t, admin, "Otter", "otter@example.com", models.RoleUser)
+
+	impersonation := data[models.ImpersonationResponse](t, s.call(t, http.MethodPost, "/api/users/"+user.ID.String()+"/impersonate", admin, nil).expect(t, http.StatusCreated))
+	s.call(t, http.MethodPost, "/api/users/"+user.ID.String()+"/logout", admin, nil).expect(t, http.StatusOK)
+
+	// Past the lifetime of a session token.
+	time.Sleep(2500 * time.Millisecond)
+
+	s.call(t, http.MethodGet, "/api/auth/me", impersonation.AccessToken, nil).expect(t, http.StatusUnauthorized)
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// DecodeCSV reads CSV rows into structs of string fields. The header row names
+// the columns by the json tags of T, so a CSV file and a JSON array of the same
+// rows are interchangeable. Unknown columns are rejected, missing ones are left
+// empty. More than maxRows rows is an error.
+func DecodeCSV[T any](r io.Reader, maxRows int) ([]T, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the CSV file has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]int{}
+	rowType := reflect.TypeFor[T]()
+	for i := range rowType.NumField() {
+		field := rowType.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name != "" && name != "-" && field.Type.Kind() == reflect.String {
+			fields[name] = i
+		}
+	}
+
+	columns := make([]int, len(header))
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+
+		index, ok := fields[column]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+
+		seen[column] = true
+		columns[i] = index
+	}
+
+	rows := []T{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == maxRows {
+			return nil, fmt.Errorf("at most %d rows can be imported at once", maxRows)
+		}
+
+		var row T
+		value := reflect.ValueOf(&row).Elem()
+		for i, field := range record {
+			value.Field(columns[i]).SetString(strings.TrimSpace(field))
+		}
+
+		rows = append(rows, row)
+	}
+}
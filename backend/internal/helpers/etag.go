@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats the version of a resource as a strong entity tag.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// SetETag sends the version of the resource in the response.
+func SetETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// IfMatch returns the version a write of r is conditional on. It is nil when
+// the request has no If-Match header or one of "*", which any existing
+// resource matches. Only a single strong entity tag from ETag is accepted.
+func IfMatch(r *http.Request) (*int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	version, err := parseETag(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// NotModified reports whether the If-None-Match header of r already names the
+// current version, in which case a 304 Not Modified with the ETag is sent and
+// the caller is done.
+func (rh *ResponseHandler) NotModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	value := r.Header.Get("If-None-Match")
+	if value == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == ETag(version) {
+			SetETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			rh.logImpersonation(r, http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseETag(tag string) (int64, error) {
+	unquoted, ok := strings.CutPrefix(tag, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if !ok || err != nil {
+		return 0, errors.New("If-Match must be a single entity tag as returned in the ETag header")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type impersonationContextKey struct{}
+
+// Impersonation identifies both sides of a request made by an admin acting as
+// another user.
+type Impersonation struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+}
+
+func WithImpersonation(ctx context.Context, impersonation Impersonation) context.Context {
+	return context.WithValue(ctx, impersonationContextKey{}, impersonation)
+}
+
+func ImpersonationFromContext(ctx context.Context) (Impersonation, bool) {
+	impersonation, ok := ctx.Value(impersonationContextKey{}).(Impersonation)
+	return impersonation, ok
+}
+
+// logImpersonation records every response sent under impersonation together
+// with the admin behind it.
+func (rh *ResponseHandler) logImpersonation(r *http.Request, statusCode int) {
+	impersonation, ok := ImpersonationFromContext(r.Context())
+	if !ok {
+		return
+	}
+
+	rh.Log.Info("Impersonated request",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Int("status", statusCode),
+		zap.String("user_id", impersonation.UserID.String()),
+		zap.String("actor_id", impersonation.ActorID.String()))
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// ListSpec describes what a list endpoint accepts. Anything else in the query
+// is rejected, so sort fields and filters can be used to build SQL safely.
+// DefaultSort may be prefixed with "-" as well.
+type ListSpec struct {
+	SortFields []string
+	// SortTypes holds the database type of each sort field, cursor values
+	// that would not convert to it are rejected.
+	SortTypes    map[string]string
+	DefaultSort  string
+	Filters      []string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// ParseListParams reads limit, cursor, sort and the filters of spec from
+// query. Sort takes a field name, prefixed with "-" for descending order.
+func ParseListParams(query url.Values, spec ListSpec) (models.ListParams, error) {
+	params := models.ListParams{
+		Limit: spec.DefaultLimit,
+	}
+	params.Sort, params.Desc = strings.CutPrefix(spec.DefaultSort, "-")
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > spec.MaxLimit {
+			return models.ListParams{}, fmt.Errorf("limit must be a number between 1 and %d", spec.MaxLimit)
+		}
+		params.Limit = limit
+	}
+
+	if value := query.Get("sort"); value != "" {
+		field, desc := strings.CutPrefix(value, "-")
+		if !slices.Contains(spec.SortFields, field) {
+			return models.ListParams{}, fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(spec.SortFields, ", "))
+		}
+		params.Sort = field
+		params.Desc = desc
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := models.DecodeCursor(value)
+		if err != nil {
+			return models.ListParams{}, err
+		}
+
+		if cursor.Sort != params.SortKey() {
+			return models.ListParams{}, fmt.Errorf("cursor was issued for sort %q", cursor.Sort)
+		}
+
+		if err := checkCursorValue(spec.SortTypes[params.Sort], cursor.Value); err != nil {
+			return models.ListParams{}, err
+		}
+		params.Cursor = cursor
+	}
+
+	params.Filters = ParseListFilters(query, spec.Filters)
+
+	return params, nil
+}
+
+// floatValue matches the numbers a float8 column is written as.
+var floatValue = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?(e[-+]?[0-9]+)?$`)
+
+// checkCursorValue rejects a cursor value the database could not compare with
+// a column of sortType, so a tampered cursor is answered with 400 rather than
+// failing the query.
+func checkCursorValue(sortType, value string) error {
+	valid := utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+
+	switch sortType {
+	case "timestamptz":
+		_, err := time.Parse(time.RFC3339Nano, value)
+		valid = valid && err == nil
+	case "float8":
+		valid = valid && floatValue.MatchString(value)
+	}
+
+	if !valid {
+		return errors.New("malformed cursor")
+	}
+
+	return nil
+}
+
+// ParseListFilters reads the given filters from query, leaving out empty ones.
+func ParseListFilters(query url.Values, filters []string) map[string]string {
+	values := map[string]string{}
+
+	for _, filter := range filters {
+		if value := strings.TrimSpace(query.Get(filter)); value != "" {
+			values[filter] = value
+		}
+	}
+
+	return values
+}
+
+// ListMeta describes page for the meta block of the response.
+func ListMeta[T any](params models.ListParams, page models.Page[T]) models.ListMeta {
+	meta := models.ListMeta{
+		Limit: params.Limit,
+		Total: page.Total,
+	}
+
+	if page.NextCursor != nil {
+		meta.NextCursor = page.NextCursor.Encode()
+	}
+
+	return meta
+}
@@ -1,16 +1,29 @@
 package helpers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+	"github.com/otterly-id/otterly/backend/internal/dberrors"
 	"github.com/otterly-id/otterly/backend/internal/utils"
 	"go.uber.org/zap"
 )
 
+// StatusClientClosedRequest is the nginx convention for a request the client
+// gave up on before the response was ready.
+const StatusClientClosedRequest = 499
+
 type ResponseHandler struct {
 	Log *zap.Logger
 }
@@ -25,16 +38,46 @@ func (rh *ResponseHandler) Success(w http.ResponseWriter, r *http.Request, statu
 	rh.Log.Info(message,
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method))
+	rh.logImpersonation(r, statusCode)
 	utils.SuccessResponse(w, statusCode, message, data)
 }
 
+func (rh *ResponseHandler) SuccessWithMeta(w http.ResponseWriter, r *http.Request, statusCode int, message string, data any, meta any) {
+	rh.Log.Info(message,
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+	rh.logImpersonation(r, statusCode)
+	utils.SuccessResponseWithMeta(w, statusCode, message, data, meta)
+}
+
+// Stream writes a file download produced by write. Once write starts the
+// status is sent, so a failure halfway can only be logged.
+func (rh *ResponseHandler) Stream(w http.ResponseWriter, r *http.Request, contentType, filename string, write func(io.Writer) error) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	rh.logImpersonation(r, http.StatusOK)
+
+	if err := write(w); err != nil {
+		rh.Log.Error("Stream interrupted",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.Error(err))
+		return
+	}
+
+	rh.Log.Info("Stream completed",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+}
+
 func (rh *ResponseHandler) JSONDecodeError(w http.ResponseWriter, r *http.Request, err error) {
 	rh.Log.Error("JSON decode error",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.Error(err))
 
-	utils.FailureResponse(w, http.StatusBadRequest, "Failed to parse JSON body", "Invalid JSON format")
+	rh.failure(w, r, http.StatusBadRequest, "Failed to parse JSON body", "Invalid JSON format")
 }
 
 func (rh *ResponseHandler) ValidationError(w http.ResponseWriter, r *http.Request, err error) {
@@ -44,7 +87,54 @@ func (rh *ResponseHandler) ValidationError(w http.ResponseWriter, r *http.Reques
 		zap.Error(err))
 
 	validationErrors := ValidatorErrors(err)
-	utils.FailureResponse(w, http.StatusBadRequest, "Validation failed", validationErrors)
+	rh.failure(w, r, http.StatusBadRequest, "Validation failed", validationErrors)
+}
+
+func (rh *ResponseHandler) InvalidQueryError(w http.ResponseWriter, r *http.Request, err error) {
+	rh.Log.Warn("Invalid query parameters",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+
+	rh.failure(w, r, http.StatusBadRequest, "Invalid query parameters", err.Error())
+}
+
+func (rh *ResponseHandler) InvalidImportError(w http.ResponseWriter, r *http.Request, err error) {
+	rh.Log.Warn("Invalid import",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+
+	rh.failure(w, r, http.StatusBadRequest, "Failed to read import", err.Error())
+}
+
+func (rh *ResponseHandler) ImportRowsError(w http.ResponseWriter, r *http.Request, rowErrors any) {
+	rh.Log.Warn("Import rejected",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+
+	rh.failure(w, r, http.StatusBadRequest, "Import rejected, nothing was created", rowErrors)
+}
+
+func (rh *ResponseHandler) InvalidPreconditionError(w http.ResponseWriter, r *http.Request, err error) {
+	rh.Log.Warn("Invalid precondition",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+
+	rh.failure(w, r, http.StatusBadRequest, "Invalid precondition", err.Error())
+}
+
+func (rh *ResponseHandler) PreconditionFailedError(w http.ResponseWriter, r *http.Request, resource string) {
+	rh.Log.Info("Precondition failed",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("resource", resource))
+
+	message := fmt.Sprintf("%s was changed by someone else", resource)
+	errorDetail := fmt.Sprintf("The %s has been changed since you read it, fetch it again and reapply your changes", strings.ToLower(resource))
+
+	rh.failure(w, r, http.StatusPreconditionFailed, message, errorDetail)
 }
 
 func (rh *ResponseHandler) InvalidIDError(w http.ResponseWriter, r *http.Request, err error) {
@@ -54,10 +144,14 @@ func (rh *ResponseHandler) InvalidIDError(w http.ResponseWriter, r *http.Request
 		zap.String("id", chi.URLParam(r, "id")),
 		zap.Error(err))
 
-	utils.FailureResponse(w, http.StatusBadRequest, "Invalid ID format", "The provided ID is not in the correct format")
+	rh.failure(w, r, http.StatusBadRequest, "Invalid ID format", "The provided ID is not in the correct format")
 }
 
 func (rh *ResponseHandler) NotFoundError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
 	rh.Log.Info("Resource not found",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
@@ -67,20 +161,29 @@ func (rh *ResponseHandler) NotFoundError(w http.ResponseWriter, r *http.Request,
 	message := fmt.Sprintf("%s not found", resource)
 	errorDetail := fmt.Sprintf("The requested %s could not be found", strings.ToLower(resource))
 
-	utils.FailureResponse(w, http.StatusNotFound, message, errorDetail)
+	rh.failure(w, r, http.StatusNotFound, message, errorDetail)
 }
 
 func (rh *ResponseHandler) DuplicateKeyError(w http.ResponseWriter, r *http.Request, err error, resource string) {
-	rh.Log.Error("Duplicate key error",
+	rh.Log.Info("Duplicate key error",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.String("resource", resource),
 		zap.Error(err))
 
 	message := fmt.Sprintf("%s already exists", strings.Title(resource))
-	errorDetail := fmt.Sprintf("A %s with this information already exists", strings.ToLower(resource))
 
-	utils.FailureResponse(w, http.StatusConflict, message, errorDetail)
+	classified, ok := dberrors.As(err)
+	if !ok || classified.Field == "" {
+		errorDetail := fmt.Sprintf("A %s with this information already exists", strings.ToLower(resource))
+		rh.failure(w, r, http.StatusConflict, message, errorDetail)
+		return
+	}
+
+	rh.failure(w, r, http.StatusConflict, message, models.FieldError{
+		Field:   classified.Field,
+		Message: fmt.Sprintf("A %s with this %s already exists", strings.ToLower(resource), classified.Field),
+	})
 }
 
 func (rh *ResponseHandler) JWTError(w http.ResponseWriter, r *http.Request, err error) {
@@ -89,7 +192,7 @@ func (rh *ResponseHandler) JWTError(w http.ResponseWriter, r *http.Request, err
 		zap.String("method", r.Method),
 		zap.Error(err))
 
-	utils.FailureResponse(w, http.StatusUnauthorized, "Authentication failed", "Invalid or expired token")
+	rh.failure(w, r, http.StatusUnauthorized, "Authentication failed", "Invalid or expired token")
 }
 
 func (rh *ResponseHandler) HashPasswordError(w http.ResponseWriter, r *http.Request, err error) {
@@ -97,22 +200,135 @@ func (rh *ResponseHandler) HashPasswordError(w http.ResponseWriter, r *http.Requ
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.Error(err))
-	utils.FailureResponse(w, http.StatusInternalServerError, "Failed to hash password", "An error occurred while hashing the password")
+	rh.failure(w, r, http.StatusInternalServerError, "Failed to hash password", "An error occurred while hashing the password")
 }
 
 func (rh *ResponseHandler) AuthenticationRequiredError(w http.ResponseWriter, r *http.Request) {
 	rh.Log.Error("Authentication required",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method))
-	utils.FailureResponse(w, http.StatusUnauthorized, "Authentication required", "You must be authenticated to access this resource")
+	rh.failure(w, r, http.StatusUnauthorized, "Authentication required", "You must be authenticated to access this resource")
 }
 
 func (rh *ResponseHandler) AuthenticationFailedError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
 	rh.Log.Error("Authentication failed",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.Error(err))
-	utils.FailureResponse(w, http.StatusUnauthorized, "Authentication failed", "Invalid credentials provided")
+	rh.failure(w, r, http.StatusUnauthorized, "Authentication failed", "Invalid credentials provided")
+}
+
+// CredentialCheckError answers for a login whose credentials could not be
+// checked at all, e.g. because the database is down.
+func (rh *ResponseHandler) CredentialCheckError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Error("Failed to check credentials",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusInternalServerError, "Authentication failed", "An error occurred while checking your credentials")
+}
+
+func (rh *ResponseHandler) TooManyAttemptsError(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	rh.Log.Warn("Too many attempts",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Duration("retry_after", retryAfter))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	rh.failure(w, r, http.StatusTooManyRequests, "Too many attempts", "Too many failed attempts, please try again later")
+}
+
+func (rh *ResponseHandler) InvalidRefreshTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Warn("Invalid refresh token",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusUnauthorized, "Authentication failed", "Invalid or expired refresh token")
+}
+
+func (rh *ResponseHandler) SessionRevocationError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Error("Failed to revoke session",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusInternalServerError, "Failed to revoke session", "An error occurred while revoking the session")
+}
+
+func (rh *ResponseHandler) InvalidActionTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Warn("Invalid action token",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusBadRequest, "Invalid token", "The provided token is invalid or has expired")
+}
+
+func (rh *ResponseHandler) EmailNotVerifiedError(w http.ResponseWriter, r *http.Request) {
+	rh.Log.Warn("Email not verified",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+	rh.failure(w, r, http.StatusForbidden, "Email not verified", "Please verify your email address before logging in")
+}
+
+func (rh *ResponseHandler) InvalidMFACodeError(w http.ResponseWriter, r *http.Request, err error) {
+	rh.Log.Warn("Invalid two-factor code",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusUnauthorized, "Authentication failed", "Invalid two-factor authentication code")
+}
+
+func (rh *ResponseHandler) MFAStateError(w http.ResponseWriter, r *http.Request, detail string) {
+	rh.Log.Info("Two-factor state conflict",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("detail", detail))
+	rh.failure(w, r, http.StatusConflict, "Two-factor authentication conflict", detail)
+}
+
+func (rh *ResponseHandler) MFARequiredError(w http.ResponseWriter, r *http.Request) {
+	rh.Log.Warn("Two-factor authentication required",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+	rh.failure(w, r, http.StatusForbidden, "Two-factor authentication required", "Your role requires two-factor authentication, enroll at /api/auth/mfa/enroll to continue")
+}
+
+func (rh *ResponseHandler) MFAError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Error("Two-factor authentication error",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusInternalServerError, "Two-factor authentication failed", "An error occurred while processing two-factor authentication")
+}
+
+func (rh *ResponseHandler) IdentityProviderError(w http.ResponseWriter, r *http.Request, err error) {
+	rh.Log.Error("Identity provider error",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusBadGateway, "Identity provider unavailable", "The identity provider could not be reached, please try again later")
 }
 
 func (rh *ResponseHandler) TokenGenerationError(w http.ResponseWriter, r *http.Request, err error) {
@@ -120,19 +336,90 @@ func (rh *ResponseHandler) TokenGenerationError(w http.ResponseWriter, r *http.R
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.Error(err))
-	utils.FailureResponse(w, http.StatusInternalServerError, "Failed to generate token", "An error occurred while generating the authentication token")
+	rh.failure(w, r, http.StatusInternalServerError, "Failed to generate token", "An error occurred while generating the authentication token")
 }
 
 func (rh *ResponseHandler) InsufficientPermissionsError(w http.ResponseWriter, r *http.Request) {
 	rh.Log.Error("Insufficient permissions",
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method))
-	utils.FailureResponse(w, http.StatusForbidden, "Insufficient permissions", "You do not have permission to access this resource")
+	rh.failure(w, r, http.StatusForbidden, "Insufficient permissions", "You do not have permission to access this resource")
+}
+
+func (rh *ResponseHandler) ScopeNotGrantableError(w http.ResponseWriter, r *http.Request, scope string, role models.UserRole) {
+	rh.Log.Info("Scope not grantable",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("scope", scope),
+		zap.String("role", string(role)))
+	rh.failure(w, r, http.StatusForbidden, "Insufficient permissions", fmt.Sprintf("The scope %s cannot be granted to role %s", scope, role))
+}
+
+func (rh *ResponseHandler) RoleTransitionError(w http.ResponseWriter, r *http.Request, detail string) {
+	rh.Log.Info("Role transition rejected",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("detail", detail))
+	rh.failure(w, r, http.StatusConflict, "Role transition rejected", detail)
+}
+
+func (rh *ResponseHandler) LastAdminError(w http.ResponseWriter, r *http.Request) {
+	rh.Log.Info("Last admin change rejected",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+	rh.failure(w, r, http.StatusConflict, "Last admin", "The last admin cannot be demoted or deleted")
+}
+
+func (rh *ResponseHandler) ImpersonationForbiddenError(w http.ResponseWriter, r *http.Request) {
+	rh.Log.Warn("Action not allowed while impersonating",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method))
+	rh.failure(w, r, http.StatusForbidden, "Not allowed while impersonating", "Email addresses, passwords, two-factor authentication and tokens cannot be changed in an impersonated session")
+}
+
+func (rh *ResponseHandler) ImpersonationTargetError(w http.ResponseWriter, r *http.Request) {
+	rh.Log.Warn("Impersonation of a privileged user rejected",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("id", chi.URLParam(r, "id")))
+	rh.failure(w, r, http.StatusForbidden, "Cannot impersonate user", "Users who may impersonate others cannot be impersonated themselves")
+}
+
+func (rh *ResponseHandler) PermissionResolutionError(w http.ResponseWriter, r *http.Request, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Error("Failed to resolve permissions",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.Error(err))
+	rh.failure(w, r, http.StatusInternalServerError, "Failed to resolve permissions", "An error occurred while checking your permissions")
+}
+
+func (rh *ResponseHandler) ListItemsError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	rh.Log.Error("List error",
+		zap.String("url", r.URL.String()),
+		zap.String("method", r.Method),
+		zap.String("resource", resource),
+		zap.Error(err))
+
+	message := fmt.Sprintf("Failed to list %s", strings.ToLower(resource))
+	errorDetail := fmt.Sprintf("An error occurred while listing the %s", strings.ToLower(resource))
+
+	rh.failure(w, r, http.StatusInternalServerError, message, errorDetail)
 }
 
 func (rh *ResponseHandler) CreateItemError(w http.ResponseWriter, r *http.Request, err error, resource string) {
-	if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
-		rh.DuplicateKeyError(w, r, err, resource)
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	if rh.databaseError(w, r, err, resource) {
 		return
 	}
 
@@ -145,12 +432,15 @@ func (rh *ResponseHandler) CreateItemError(w http.ResponseWriter, r *http.Reques
 	message := fmt.Sprintf("Failed to create %s", strings.ToLower(resource))
 	errorDetail := fmt.Sprintf("An error occurred while creating the %s", strings.ToLower(resource))
 
-	utils.FailureResponse(w, http.StatusInternalServerError, message, errorDetail)
+	rh.failure(w, r, http.StatusInternalServerError, message, errorDetail)
 }
 
 func (rh *ResponseHandler) UpdateItemError(w http.ResponseWriter, r *http.Request, err error, resource string) {
-	if err == pgx.ErrNoRows {
-		rh.NotFoundError(w, r, err, resource)
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	if rh.databaseError(w, r, err, resource) {
 		return
 	}
 
@@ -163,12 +453,15 @@ func (rh *ResponseHandler) UpdateItemError(w http.ResponseWriter, r *http.Reques
 	message := fmt.Sprintf("Failed to update %s", strings.ToLower(resource))
 	errorDetail := fmt.Sprintf("An error occurred while updating the %s", strings.ToLower(resource))
 
-	utils.FailureResponse(w, http.StatusInternalServerError, message, errorDetail)
+	rh.failure(w, r, http.StatusInternalServerError, message, errorDetail)
 }
 
 func (rh *ResponseHandler) DeleteItemError(w http.ResponseWriter, r *http.Request, err error, resource string) {
-	if err == pgx.ErrNoRows {
-		rh.NotFoundError(w, r, err, resource)
+	if rh.contextError(w, r, err) {
+		return
+	}
+
+	if rh.databaseError(w, r, err, resource) {
 		return
 	}
 
@@ -181,14 +474,107 @@ func (rh *ResponseHandler) DeleteItemError(w http.ResponseWriter, r *http.Reques
 	message := fmt.Sprintf("Failed to delete %s", strings.ToLower(resource))
 	errorDetail := fmt.Sprintf("An error occurred while deleting the %s", strings.ToLower(resource))
 
-	utils.FailureResponse(w, http.StatusInternalServerError, message, errorDetail)
+	rh.failure(w, r, http.StatusInternalServerError, message, errorDetail)
 }
 
 func (rh *ResponseHandler) CustomError(w http.ResponseWriter, r *http.Request, statusCode int, message string, err error) {
+	if rh.contextError(w, r, err) {
+		return
+	}
+
 	rh.Log.Error(message,
 		zap.String("url", r.URL.String()),
 		zap.String("method", r.Method),
 		zap.Error(err))
 
-	utils.FailureResponse(w, statusCode, message, err)
-}
\ No newline at end of file
+	rh.failure(w, r, statusCode, message, err)
+}
+
+// contextError answers for a query that was abandoned because the client went
+// away or because it ran past its deadline, and reports whether it did.
+func (rh *ResponseHandler) contextError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	queryCanceled := errors.As(err, &pgErr) && pgErr.Code == "57014"
+
+	if errors.Is(r.Context().Err(), context.Canceled) && (errors.Is(err, context.Canceled) || queryCanceled) {
+		rh.Log.Info("Request cancelled by client",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.Error(err))
+		rh.failure(w, r, StatusClientClosedRequest, "Client closed request", "The request was cancelled before it completed")
+		return true
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) || queryCanceled {
+		rh.Log.Error("Query timed out",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.Error(err))
+		rh.failure(w, r, http.StatusGatewayTimeout, "Request timed out", "The database did not respond in time, please try again later")
+		return true
+	}
+
+	return false
+}
+
+// databaseError answers for an error the database classified, a missing row
+// or a violated constraint, and reports whether it did.
+func (rh *ResponseHandler) databaseError(w http.ResponseWriter, r *http.Request, err error, resource string) bool {
+	classified, ok := dberrors.As(err)
+	if !ok {
+		return false
+	}
+
+	switch classified.Kind {
+	case dberrors.ErrNotFound:
+		rh.NotFoundError(w, r, err, resource)
+	case dberrors.ErrUniqueViolation:
+		rh.DuplicateKeyError(w, r, err, resource)
+	case dberrors.ErrForeignKeyViolation:
+		rh.Log.Info("Foreign key violation",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.String("resource", resource),
+			zap.String("constraint", classified.Constraint),
+			zap.Error(err))
+		rh.failure(w, r, http.StatusConflict, fmt.Sprintf("%s is still referenced", strings.Title(resource)),
+			fmt.Sprintf("The %s refers to, or is referred to by, a record that does not allow the change", strings.ToLower(resource)))
+	case dberrors.ErrCheckViolation:
+		rh.Log.Info("Check violation",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.String("resource", resource),
+			zap.String("constraint", classified.Constraint),
+			zap.Error(err))
+		detail := fmt.Sprintf("The %s is not valid", strings.ToLower(resource))
+		if classified.Field == "" {
+			rh.failure(w, r, http.StatusBadRequest, "Validation failed", detail)
+			break
+		}
+		rh.failure(w, r, http.StatusBadRequest, "Validation failed", models.FieldError{
+			Field:   classified.Field,
+			Message: fmt.Sprintf("The %s is not valid", classified.Field),
+		})
+	case dberrors.ErrSerializationFailure:
+		rh.Log.Warn("Transaction conflict",
+			zap.String("url", r.URL.String()),
+			zap.String("method", r.Method),
+			zap.String("resource", resource),
+			zap.Error(err))
+		rh.failure(w, r, http.StatusConflict, "Concurrent update",
+			fmt.Sprintf("The %s was changed by a concurrent request, please try again", strings.ToLower(resource)))
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (rh *ResponseHandler) failure(w http.ResponseWriter, r *http.Request, statusCode int, message string, errors any) {
+	rh.logImpersonation(r, statusCode)
+	utils.FailureResponse(w, statusCode, message, errors)
+}
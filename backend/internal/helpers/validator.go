@@ -44,6 +44,12 @@ func ValidatorErrors(err error) []string {
 			message = fmt.Sprintf("%s must be a valid phone number (e.g., +1234567890)", field)
 		case "password_strength":
 			message = fmt.Sprintf("%s must be at least 8 characters long and contain at least 1 uppercase letter, 1 lowercase letter, and 1 number", field)
+		case "nefield":
+			message = fmt.Sprintf("%s must be different from %s", field, param)
+		case "scope":
+			message = fmt.Sprintf("%s must be a valid scope", field)
+		case "bcrypt":
+			message = fmt.Sprintf("%s must be a bcrypt hash", field)
 		default:
 			message = fmt.Sprintf("%s is invalid", field)
 		}
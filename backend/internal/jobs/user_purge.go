@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// userPurgeBatchSize caps the users removed per transaction, so a large
+// backlog does not hold locks for long.
+const userPurgeBatchSize = 500
+
+// UserPurgeStore removes soft deleted users.
+type UserPurgeStore interface {
+	PurgeDeletedUsers(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// UserPurge permanently removes users that have been soft deleted for longer
+// than the retention period.
+type UserPurge struct {
+	store     UserPurgeStore
+	retention time.Duration
+	interval  time.Duration
+	log       *zap.Logger
+}
+
+func NewUserPurge(store UserPurgeStore, retention, interval time.Duration, log *zap.Logger) *UserPurge {
+	return &UserPurge{
+		store:     store,
+		retention: retention,
+		interval:  interval,
+		log:       log,
+	}
+}
+
+// Start runs the purge right away and then every interval until ctx is done.
+func (p *UserPurge) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.run(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *UserPurge) run(ctx context.Context) {
+	purged, err := p.RunOnce(ctx, time.Now())
+	if err != nil {
+		p.log.Error("Failed to purge deleted users", zap.Int("purged", purged), zap.Error(err))
+		return
+	}
+
+	if purged > 0 {
+		p.log.Info("Purged deleted users", zap.Int("purged", purged))
+	}
+}
+
+// RunOnce removes every user deleted before now minus the retention period,
+// batch by batch, and returns how many were removed.
+func (p *UserPurge) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	before := now.Add(-p.retention)
+	total := 0
+
+	for {
+		purged, err := p.store.PurgeDeletedUsers(ctx, before, userPurgeBatchSize)
+		total += purged
+		if err != nil {
+			return total, err
+		}
+
+		if purged < userPurgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogMailer writes the recipient and subject of emails to the application log
+// instead of delivering them. Meant for local development, the bodies hold
+// links that sign in as the recipient, use FileMailer to read them.
+type LogMailer struct {
+	log *zap.Logger
+}
+
+func NewLogMailer(log *zap.Logger) *LogMailer {
+	return &LogMailer{
+		log: log,
+	}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	m.log.Info("Email sent",
+		zap.String("to", message.To),
+		zap.String("subject", message.Subject))
+	return nil
+}
@@ -0,0 +1,48 @@
+package mailer
+
+import "fmt"
+
+func VerificationMessage(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your Otterly email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm your email address by opening the link below:
+
+%s
+
+If you did not create an Otterly account you can ignore this email.
+`, name, link),
+	}
+}
+
+func PasswordResetMessage(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your Otterly password",
+		Body: fmt.Sprintf(`Hi %s,
+
+We received a request to reset your password. Open the link below to choose a new one:
+
+%s
+
+If you did not ask for a password reset you can ignore this email, your password will stay the same.
+`, name, link),
+	}
+}
+
+func InviteMessage(to, name, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "You have been invited to Otterly",
+		Body: fmt.Sprintf(`Hi %s,
+
+An Otterly account has been created for you. Open the link below to choose your password:
+
+%s
+
+If you were not expecting this invitation you can ignore this email.
+`, name, link),
+	}
+}
@@ -0,0 +1,179 @@
+package oidc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/otterly-id/otterly/backend/internal/oidc"
+	"github.com/otterly-id/otterly/backend/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://otterly.test/api/auth/oidc/google/callback"
+
+func newProvider(t *testing.T) (*oidctest.Server, *oidc.Provider) {
+	t.Helper()
+
+	server := oidctest.NewServer("otterly", "secret")
+	t.Cleanup(server.Close)
+
+	server.SetIdentity(oidctest.Identity{
+		Subject:       "1234567890",
+		Email:         "otter@example.com",
+		EmailVerified: true,
+		Name:          "Otter",
+	})
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  redirectURL,
+	}, server.Client())
+
+	return server, provider
+}
+
+func authorize(t *testing.T, server *oidctest.Server, provider *oidc.Provider, verifier string) (code, state string) {
+	t.Helper()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-value", "nonce-value", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	for _, param := range []string{"code_challenge=" + oidc.CodeChallenge(verifier), "code_challenge_method=S256", "nonce=nonce-value"} {
+		if !strings.Contains(authURL, param) {
+			t.Fatalf("authorization url %q is missing %q", authURL, param)
+		}
+	}
+
+	callback, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server, provider := newProvider(t)
+	ctx := context.Background()
+
+	code, state := authorize(t, server, provider, "verifier-value")
+	if state != "state-value" {
+		t.Fatalf("state = %q, want state-value", state)
+	}
+
+	token, err := provider.Exchange(ctx, code, "verifier-value")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, "nonce-value")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	if claims.Subject != "1234567890" || claims.Email != "otter@example.com" || !bool(claims.EmailVerified) {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	server, provider := newProvider(t)
+
+	code, _ := authorize(t, server, provider, "verifier-value")
+
+	if _, err := provider.Exchange(context.Background(), code, "another-verifier"); err == nil {
+		t.Fatal("Exchange accepted a code verifier that does not match the challenge")
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	server, provider := newProvider(t)
+	now := time.Now()
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   server.Issuer(),
+			"sub":   "1234567890",
+			"aud":   server.ClientID,
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce-value",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(jwt.MapClaims)
+		nonce  string
+	}{
+		{name: "nonce mismatch", mutate: func(jwt.MapClaims) {}, nonce: "other-nonce"},
+		{name: "wrong audience", mutate: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "wrong issuer", mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", mutate: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{name: "missing expiry", mutate: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "missing subject", mutate: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{name: "other authorized party", mutate: func(c jwt.MapClaims) {
+			c["aud"] = []string{server.ClientID, "someone-else"}
+			c["azp"] = "someone-else"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.mutate(claims)
+
+			nonce := tt.nonce
+			if nonce == "" {
+				nonce = "nonce-value"
+			}
+
+			if _, err := provider.VerifyIDToken(context.Background(), server.SignIDToken(claims), nonce); err == nil {
+				t.Fatal("VerifyIDToken accepted an invalid token")
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsUnsignedToken(t *testing.T) {
+	server, provider := newProvider(t)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss":   server.Issuer(),
+		"sub":   "1234567890",
+		"aud":   server.ClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "nonce-value",
+	})
+	token.Header["kid"] = oidctest.KeyID
+
+	signed, err := token.SignedString([]byte("guessed-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.VerifyIDToken(context.Background(), signed, "nonce-value"); err == nil {
+		t.Fatal("VerifyIDToken accepted an HMAC signed token")
+	}
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	server := oidctest.NewServer("otterly", "secret")
+	defer server.Close()
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:      server.Issuer() + "/",
+		ClientID:    server.ClientID,
+		RedirectURL: redirectURL,
+	}, server.Client())
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Fatal("AuthCodeURL accepted a discovery document for another issuer")
+	}
+}
@@ -0,0 +1,62 @@
+package policy
+
+import "github.com/google/uuid"
+
+// Principal is the authenticated caller a rule is evaluated for, usually the
+// middlewares.UserInfo of the request.
+type Principal interface {
+	UserID() uuid.UUID
+	HasPermission(permission string) bool
+}
+
+// Rule decides whether principal may act on a resource owned by ownerID.
+type Rule func(principal Principal, ownerID uuid.UUID) bool
+
+// Self allows the owner of the resource.
+func Self() Rule {
+	return func(principal Principal, ownerID uuid.UUID) bool {
+		return principal.UserID() == ownerID
+	}
+}
+
+// Permission allows anyone holding permission, whoever owns the resource.
+func Permission(permission string) Rule {
+	return func(principal Principal, _ uuid.UUID) bool {
+		return principal.HasPermission(permission)
+	}
+}
+
+// AnyOf allows the request when at least one rule does.
+func AnyOf(rules ...Rule) Rule {
+	return func(principal Principal, ownerID uuid.UUID) bool {
+		for _, rule := range rules {
+			if rule(principal, ownerID) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllOf allows the request only when every rule does.
+func AllOf(rules ...Rule) Rule {
+	return func(principal Principal, ownerID uuid.UUID) bool {
+		for _, rule := range rules {
+			if !rule(principal, ownerID) {
+				return false
+			}
+		}
+		return len(rules) > 0
+	}
+}
+
+// SelfOr allows the owner of the resource or anyone holding permission, e.g.
+// SelfOr(models.PermissionUsersWrite) for "self or ADMIN".
+func SelfOr(permission string) Rule {
+	return AnyOf(Self(), Permission(permission))
+}
+
+// Allowed evaluates rule for principal on a resource owned by ownerID.
+func Allowed(principal Principal, ownerID uuid.UUID, rule Rule) bool {
+	return principal != nil && rule(principal, ownerID)
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+// PermissionSource loads the permissions granted to every role.
+type PermissionSource interface {
+	GetRolePermissions(ctx context.Context) (map[models.UserRole][]string, error)
+}
+
+// RolePermissions resolves the permissions of a role from the
+// role_permissions table. The whole table is cached for ttl, so a change to
+// the policy reaches every token without reissuing it.
+type RolePermissions struct {
+	source PermissionSource
+	ttl    time.Duration
+
+	mu       sync.RWMutex
+	cache    map[models.UserRole][]string
+	loadedAt time.Time
+}
+
+func NewRolePermissions(source PermissionSource, ttl time.Duration) *RolePermissions {
+	return &RolePermissions{
+		source: source,
+		ttl:    ttl,
+	}
+}
+
+// Resolve returns the permissions granted to role.
+func (p *RolePermissions) Resolve(ctx context.Context, role models.UserRole) ([]string, error) {
+	p.mu.RLock()
+	if p.cache != nil && time.Since(p.loadedAt) < p.ttl {
+		permissions := p.cache[role]
+		p.mu.RUnlock()
+		return permissions, nil
+	}
+	p.mu.RUnlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cache == nil || time.Since(p.loadedAt) >= p.ttl {
+		cache, err := p.source.GetRolePermissions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.cache = cache
+		p.loadedAt = time.Now()
+	}
+
+	return p.cache[role], nil
+}
+
+// Grants reports whether role holds permission.
+func (p *RolePermissions) Grants(ctx context.Context, role models.UserRole, permission string) (bool, error) {
+	permissions, err := p.Resolve(ctx, role)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(permissions, permission), nil
+}
+
+// Covers reports whether actor holds every permission of target, so managing
+// users of the target role cannot hand out more than the actor already has.
+func (p *RolePermissions) Covers(ctx context.Context, actor, target models.UserRole) (bool, error) {
+	actorPermissions, err := p.Resolve(ctx, actor)
+	if err != nil {
+		return false, err
+	}
+
+	targetPermissions, err := p.Resolve(ctx, target)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range targetPermissions {
+		if !slices.Contains(actorPermissions, permission) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Invalidate drops the cache so the next lookup reloads the policy.
+func (p *RolePermissions) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cache = nil
+}
@@ -0,0 +1,16 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptStore counts failed attempts per key within a window and keeps
+// temporary locks, so repeated failures can be slowed down and then blocked.
+type AttemptStore interface {
+	Failures(ctx context.Context, key string) (int, error)
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, duration time.Duration) error
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type attemptCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string]attemptCounter
+	locks    map[string]time.Time
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{
+		failures: make(map[string]attemptCounter),
+		locks:    make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryAttemptStore) Failures(ctx context.Context, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.failures[key]
+	if !ok || counter.expiresAt.Before(time.Now()) {
+		return 0, nil
+	}
+
+	return counter.count, nil
+}
+
+func (s *MemoryAttemptStore) RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purgeExpired(now)
+
+	counter, ok := s.failures[key]
+	if !ok {
+		counter = attemptCounter{expiresAt: now.Add(window)}
+	}
+	counter.count++
+	s.failures[key] = counter
+
+	return counter.count, nil
+}
+
+func (s *MemoryAttemptStore) Lock(ctx context.Context, key string, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locks[key] = time.Now().Add(duration)
+	return nil
+}
+
+func (s *MemoryAttemptStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lockedUntil, ok := s.locks[key]
+	if !ok {
+		return 0, nil
+	}
+
+	return max(time.Until(lockedUntil), 0), nil
+}
+
+func (s *MemoryAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	delete(s.locks, key)
+	return nil
+}
+
+func (s *MemoryAttemptStore) purgeExpired(now time.Time) {
+	for key, counter := range s.failures {
+		if counter.expiresAt.Before(now) {
+			delete(s.failures, key)
+		}
+	}
+
+	for key, lockedUntil := range s.locks {
+		if lockedUntil.Before(now) {
+			delete(s.locks, key)
+		}
+	}
+}
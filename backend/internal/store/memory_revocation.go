@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type userRevocation struct {
+	revokedAt time.Time
+	expiresAt time.Time
+}
+
+type MemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[uuid.UUID]userRevocation
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uuid.UUID]userRevocation),
+	}
+}
+
+func (s *MemoryRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired(time.Now())
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expiresAt, ok := s.tokens[tokenID]
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (s *MemoryRevocationStore) RevokeUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpired(time.Now())
+	s.users[userID] = userRevocation{
+		revokedAt: revokedAt,
+		expiresAt: revokedAt.Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsUserRevoked(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revocation, ok := s.users[userID]
+	if !ok || revocation.expiresAt.Before(time.Now()) {
+		return false, nil
+	}
+
+	return issuedAt.Before(revocation.revokedAt), nil
+}
+
+func (s *MemoryRevocationStore) purgeExpired(now time.Time) {
+	for tokenID, expiresAt := range s.tokens {
+		if expiresAt.Before(now) {
+			delete(s.tokens, tokenID)
+		}
+	}
+
+	for userID, revocation := range s.users {
+		if revocation.expiresAt.Before(now) {
+			delete(s.users, userID)
+		}
+	}
+}
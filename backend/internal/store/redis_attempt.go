@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	attemptKeyPrefix = "otterly:attempts:"
+	lockKeyPrefix    = "otterly:lock:"
+)
+
+type RedisAttemptStore struct {
+	client *redis.Client
+}
+
+func NewRedisAttemptStore(client *redis.Client) *RedisAttemptStore {
+	return &RedisAttemptStore{
+		client: client,
+	}
+}
+
+func (s *RedisAttemptStore) Failures(ctx context.Context, key string) (int, error) {
+	count, err := s.client.Get(ctx, attemptKeyPrefix+key).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read failed attempts: %w", err)
+	}
+
+	return count, nil
+}
+
+func (s *RedisAttemptStore) RegisterFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var count *redis.IntCmd
+
+	// The window starts with the first failure and is not extended by later ones.
+	if _, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.Incr(ctx, attemptKeyPrefix+key)
+		pipe.ExpireNX(ctx, attemptKeyPrefix+key, window)
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("failed to register failed attempt: %w", err)
+	}
+
+	return int(count.Val()), nil
+}
+
+func (s *RedisAttemptStore) Lock(ctx context.Context, key string, duration time.Duration) error {
+	if err := s.client.Set(ctx, lockKeyPrefix+key, 1, duration).Err(); err != nil {
+		return fmt.Errorf("failed to lock: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisAttemptStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, lockKeyPrefix+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check lock: %w", err)
+	}
+
+	// PTTL reports negative values for missing keys and keys without expiry.
+	return max(ttl, 0), nil
+}
+
+func (s *RedisAttemptStore) Reset(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, attemptKeyPrefix+key, lockKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+
+	return nil
+}
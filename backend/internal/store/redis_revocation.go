@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	revokedTokenKeyPrefix = "otterly:revoked:token:"
+	revokedUserKeyPrefix  = "otterly:revoked:user:"
+)
+
+type RedisRevocationStore struct {
+	client *redis.Client
+}
+
+func NewRedisRevocationStore(client *redis.Client) *RedisRevocationStore {
+	return &RedisRevocationStore{
+		client: client,
+	}
+}
+
+func (s *RedisRevocationStore) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	if err := s.client.Set(ctx, revokedTokenKeyPrefix+tokenID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisRevocationStore) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := s.client.Exists(ctx, revokedTokenKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+func (s *RedisRevocationStore) RevokeUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error {
+	value := strconv.FormatInt(revokedAt.UnixNano(), 10)
+
+	if err := s.client.Set(ctx, revokedUserKeyPrefix+userID.String(), value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisRevocationStore) IsUserRevoked(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	value, err := s.client.Get(ctx, revokedUserKeyPrefix+userID.String()).Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check user revocation: %w", err)
+	}
+
+	return issuedAt.Before(time.Unix(0, value)), nil
+}
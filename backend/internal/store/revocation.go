@@ -0,0 +1,19 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevocationStore keeps track of access tokens that were invalidated before
+// their natural expiry, either one by one (by jti) or for every token a user
+// was issued before a point in time. Revocation times keep their full
+// precision, tokens issued after them stay valid.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	RevokeUser(ctx context.Context, userID uuid.UUID, revokedAt time.Time, ttl time.Duration) error
+	IsUserRevoked(ctx context.Context, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeMFALogin          = "mfa_login"
+	PurposeIdentityLink      = "identity_link"
+)
+
+// ActionClaims are carried by short-lived tokens that authorize a single kind
+// of action, like confirming an email address. The purpose doubles as the
+// audience so they can never be accepted as access tokens.
+type ActionClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (j *JWTManager) GenerateActionToken(userID uuid.UUID, email, purpose string, duration time.Duration) (string, error) {
+	now := time.Now()
+
+	claims := ActionClaims{
+		Purpose: purpose,
+		Email:   email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    j.issuer,
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{purpose},
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	tokenString, err := j.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+func (j *JWTManager) ValidateActionToken(tokenString, purpose string) (*ActionClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, j.keys.Keyfunc,
+		jwt.WithIssuer(j.issuer),
+		jwt.WithAudience(purpose),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
+
+	return claims, nil
+}
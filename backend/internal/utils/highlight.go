@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SearchTerms splits a search into lower cased words of letters and digits.
+func SearchTerms(search string) []string {
+	return strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight escapes text for HTML and wraps every case insensitive occurrence
+// of terms in <mark> tags. It reports false when no term occurs.
+func Highlight(text string, terms []string) (string, bool) {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lower casing changed byte offsets, matches could not be mapped back.
+		return html.EscapeString(text), false
+	}
+
+	marked := make([]bool, len(text))
+	found := false
+	for _, term := range terms {
+		for start := 0; term != ""; {
+			index := strings.Index(lower[start:], term)
+			if index < 0 {
+				break
+			}
+			for i := start + index; i < start+index+len(term); i++ {
+				marked[i] = true
+			}
+			found = true
+			start += index + len(term)
+		}
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(text); {
+		j := i
+		for j < len(text) && marked[j] == marked[i] {
+			j++
+		}
+
+		segment := html.EscapeString(text[i:j])
+		if marked[i] {
+			segment = "<mark>" + segment + "</mark>"
+		}
+		builder.WriteString(segment)
+		i = j
+	}
+
+	return builder.String(), found
+}
@@ -1,70 +1,133 @@
 package utils
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/otterly-id/otterly/backend/internal/api/models"
 )
 
+// Claims only carry the role of the user. Its permissions are resolved from
+// the role_permissions table when the token is used, so policy changes apply
+// without reissuing tokens.
 type Claims struct {
-	ID   string          `json:"id"`
-	Role models.UserRole `json:"role"`
+	ID    string          `json:"id"`
+	Role  models.UserRole `json:"role"`
+	Actor *ActorClaims    `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ActorClaims names the admin acting as the subject of an impersonation
+// token, following the act claim of RFC 8693.
+type ActorClaims struct {
+	Subject string `json:"sub"`
+}
+
 type JWTManager struct {
-	secretKey     []byte
-	issuer        string
-	audience      string
-	tokenDuration time.Duration
+	keys            *KeySet
+	issuer          string
+	audience        string
+	tokenDuration   time.Duration
+	refreshDuration time.Duration
 }
 
-func NewJWTManager(secretKey []byte, issuer, audience string, tokenDuration time.Duration) *JWTManager {
+func NewJWTManager(keys *KeySet, issuer, audience string, tokenDuration, refreshDuration time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey:     secretKey,
-		issuer:        issuer,
-		audience:      audience,
-		tokenDuration: tokenDuration,
+		keys:            keys,
+		issuer:          issuer,
+		audience:        audience,
+		tokenDuration:   tokenDuration,
+		refreshDuration: refreshDuration,
 	}
 }
 
 func (j *JWTManager) GenerateToken(userID, email string, role models.UserRole) (string, time.Duration, error) {
+	tokenString, err := j.keys.Sign(j.newClaims(userID, role, j.tokenDuration))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, j.tokenDuration, nil
+}
+
+// GenerateImpersonationToken issues an access token for userID carrying actorID
+// in the act claim. It lives for duration and comes without a refresh token.
+func (j *JWTManager) GenerateImpersonationToken(userID string, role models.UserRole, actorID string, duration time.Duration) (string, error) {
+	claims := j.newClaims(userID, role, duration)
+	claims.Actor = &ActorClaims{Subject: actorID}
+
+	tokenString, err := j.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return tokenString, nil
+}
+
+func (j *JWTManager) newClaims(userID string, role models.UserRole, duration time.Duration) Claims {
 	now := time.Now()
 
-	claims := Claims{
+	return Claims{
 		ID:   userID,
 		Role: role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A version 7 id records when the token was issued more
+			// precisely than iat, see IssuedAtTime.
+			ID:        uuid.Must(uuid.NewV7()).String(),
 			Issuer:    j.issuer,
 			Subject:   userID,
 			Audience:  jwt.ClaimStrings{j.audience},
-			ExpiresAt: jwt.NewNumericDate(now.Add(j.tokenDuration)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 			NotBefore: jwt.NewNumericDate(now),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
+}
+
+// IssuedAtTime returns when the token was issued. iat only has second
+// precision, the version 7 UUID in jti carries the time to a fraction of a
+// microsecond, so a token issued right after its user's sessions were revoked
+// is not mistaken for one of the revoked ones.
+func (c *Claims) IssuedAtTime() time.Time {
+	id, err := uuid.Parse(c.RegisteredClaims.ID)
+	if err != nil || id.Version() != 7 {
+		return c.IssuedAt.Time
+	}
+
+	// 48 bits of milliseconds since the epoch followed by the version and 12
+	// bits of the remaining nanoseconds divided by 256.
+	milli := int64(binary.BigEndian.Uint64(id[:8]) >> 16)
+	fraction := int64(binary.BigEndian.Uint16(id[6:8]) & 0x0fff)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return time.UnixMilli(milli).Add(time.Duration(fraction << 8))
+}
+
+func (j *JWTManager) JWKS() models.JWKS {
+	return j.keys.JWKS()
+}
 
-	tokenString, err := token.SignedString(j.secretKey)
+func (j *JWTManager) TokenDuration() time.Duration {
+	return j.tokenDuration
+}
+
+// GenerateRefreshToken returns an opaque refresh token together with the hash
+// that should be persisted and its expiry. The plain token is never stored.
+func (j *JWTManager) GenerateRefreshToken() (string, []byte, time.Time, error) {
+	token, err := GenerateOpaqueToken(32)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to sign token: %w", err)
+		return "", nil, time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	return tokenString, j.tokenDuration, nil
+	return token, HashToken(token), time.Now().Add(j.refreshDuration), nil
 }
 
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keys.Keyfunc)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -83,6 +146,14 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 }
 
 func (j *JWTManager) validateClaims(claims *Claims) error {
+	if claims.RegisteredClaims.ID == "" {
+		return errors.New("missing token id")
+	}
+
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return errors.New("missing token timestamps")
+	}
+
 	if claims.Issuer != j.issuer {
 		return errors.New("invalid issuer")
 	}
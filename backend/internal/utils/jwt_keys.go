@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/otterly-id/otterly/backend/internal/api/models"
+)
+
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey any
+	PublicKey  any
+}
+
+// KeySet holds the key used to sign new tokens and every key that is still
+// accepted for verification. Keeping the previous keys around after switching
+// the active one is what gives already issued tokens a rotation window.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewHMACKeySet(secret []byte) *KeySet {
+	key := &SigningKey{
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: secret,
+		PublicKey:  secret,
+	}
+
+	return &KeySet{
+		active: key,
+		keys:   map[string]*SigningKey{"": key},
+	}
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{
+		keys: make(map[string]*SigningKey),
+	}
+}
+
+// LoadDir reads every <kid>.pem file in dir. Private keys (PKCS#8 or PKCS#1,
+// RSA or Ed25519) can sign and verify, public keys (PKIX) are only kept to
+// verify tokens signed by a retired key.
+func (ks *KeySet) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("failed to list key files: %w", err)
+	}
+
+	if len(paths) == 0 {
+		return fmt.Errorf("no key files found in %s", dir)
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read key file %s: %w", path, err)
+		}
+
+		keyID := strings.TrimSuffix(filepath.Base(path), ".pem")
+		if err := ks.AddPEM(keyID, data); err != nil {
+			return fmt.Errorf("failed to load key %s: %w", keyID, err)
+		}
+	}
+
+	return nil
+}
+
+func (ks *KeySet) AddPEM(keyID string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("no PEM data found")
+	}
+
+	key := &SigningKey{ID: keyID}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse private key: %w", err)
+		}
+		key.PrivateKey = privateKey
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse private key: %w", err)
+		}
+		key.PrivateKey = privateKey
+	case "PUBLIC KEY":
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse public key: %w", err)
+		}
+		key.PublicKey = publicKey
+	default:
+		return fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+
+	switch privateKey := key.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		key.PublicKey = &privateKey.PublicKey
+	case ed25519.PrivateKey:
+		key.PublicKey = privateKey.Public()
+	case nil:
+	default:
+		return fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported public key type %T", key.PublicKey)
+	}
+
+	ks.keys[keyID] = key
+	return nil
+}
+
+// AddHMAC keeps a shared secret around for verification only, so tokens that
+// were signed before switching to asymmetric keys stay valid until they expire.
+func (ks *KeySet) AddHMAC(secret []byte) {
+	ks.keys[""] = &SigningKey{
+		Method:    jwt.SigningMethodHS256,
+		PublicKey: secret,
+	}
+}
+
+func (ks *KeySet) Activate(keyID string) error {
+	key, ok := ks.keys[keyID]
+	if !ok {
+		return fmt.Errorf("active key %q not found", keyID)
+	}
+
+	if key.PrivateKey == nil {
+		return fmt.Errorf("active key %q has no private key", keyID)
+	}
+
+	ks.active = key
+	return nil
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+
+	return token.SignedString(ks.active.PrivateKey)
+}
+
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	keyID, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.PublicKey, nil
+}
+
+// JWKS lists the public half of every asymmetric key. Shared secrets are never
+// published.
+func (ks *KeySet) JWKS() models.JWKS {
+	keyIDs := make([]string, 0, len(ks.keys))
+	for keyID := range ks.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	jwks := models.JWKS{Keys: []models.JWK{}}
+
+	for _, keyID := range keyIDs {
+		key := ks.keys[keyID]
+
+		switch publicKey := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Kid: key.ID,
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "OKP",
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Kid: key.ID,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return jwks
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -16,3 +18,14 @@ func ComparePassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// dummyPasswordHash is compared against when an account does not exist, so a
+// login for an unknown email takes as long as one with a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("otterly-dummy-password")
+	return hash
+})
+
+func SimulatePasswordCompare(password string) {
+	ComparePassword(password, dummyPasswordHash())
+}
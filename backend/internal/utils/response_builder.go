@@ -10,6 +10,7 @@ type Envelope[T any] struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 	Data    T      `json:"data,omitempty"`
+	Meta    any    `json:"meta,omitempty"`
 	Errors  any    `json:"errors,omitempty"`
 }
 
@@ -31,6 +32,16 @@ func SuccessResponse[T any](w http.ResponseWriter, statusCode int, message strin
 	writeJSON(w, statusCode, response)
 }
 
+func SuccessResponseWithMeta[T any](w http.ResponseWriter, statusCode int, message string, data T, meta any) {
+	response := Envelope[T]{
+		Success: true,
+		Message: message,
+		Data:    data,
+		Meta:    meta,
+	}
+	writeJSON(w, statusCode, response)
+}
+
 func FailureResponse(w http.ResponseWriter, statusCode int, message string, errors any) {
 	response := Envelope[any]{
 		Success: false,
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+func GenerateOpaqueToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func HashToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+const APITokenPrefix = "otl_"
+
+// GenerateAPIToken returns a personal access token, the short prefix shown to
+// users to tell their tokens apart, and the hash that is persisted.
+func GenerateAPIToken() (string, string, []byte, error) {
+	secret, err := GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	token := APITokenPrefix + secret
+	return token, token[:len(APITokenPrefix)+8], HashToken(token), nil
+}